@@ -16,9 +16,18 @@ limitations under the License.
 package vineyard
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	vineyard "github.com/v6d-io/v6d/go/vineyard/pkg/client/ds"
 	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
@@ -26,218 +35,808 @@ import (
 
 type Signature = uint64
 
+// ErrDisconnected is returned if the connection to vineyard server is lost and
+// cannot be re-established by the reconnect policy.
+var ErrDisconnected = errors.New("vineyard client is disconnected")
+
+// ErrIncompatibleServer is returned by Connect if the version of vineyard
+// server is older than common.MIN_SERVER_VERSION.
+var ErrIncompatibleServer = errors.New("incompatible vineyard server")
+
+// connError is a failure of the connection, the request hasn't been received
+// by vineyard server if it fails to be sent.
+type connError struct {
+	sent bool
+	err  error
+}
+
+func (e *connError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrDisconnected, e.err)
+}
+
+func (e *connError) Unwrap() error {
+	return e.err
+}
+
+func (e *connError) Is(target error) bool {
+	return target == ErrDisconnected
+}
+
+// Option configures the client on Connect.
+type Option func(c *ClientBase)
+
+// WithReconnectPolicy sets the policy to connect to vineyard server, the
+// same policy applies when reconnecting after the connection is lost.
+func WithReconnectPolicy(policy ReconnectPolicy) Option {
+	return func(c *ClientBase) {
+		c.policy = policy
+	}
+}
+
+// WithBlobFinalizer releases the blobs returned by GetBlobs of the IPC client
+// once they are garbage collected, the blob owns its payload, which must not
+// be accessed after the blob becomes unreachable (see runtime.KeepAlive), and
+// such blobs must not be released explicitly. The buffers of the metadata
+// fetched by GetMetaData and GetObject are not owned by any object, and are
+// always released explicitly.
+func WithBlobFinalizer() Option {
+	return func(c *ClientBase) {
+		c.blobFinalizer = true
+	}
+}
+
+// WithStoreType chooses the bulk store of the session to register to, i.e.,
+// common.NormalStore (the default) or common.PlasmaStore, the registration
+// fails if the session uses the other one.
+func WithStoreType(storeType string) Option {
+	return func(c *ClientBase) {
+		c.storeType = storeType
+	}
+}
+
+// WithCredentials sets the username and password to register to vineyard
+// server that requires authentication.
+func WithCredentials(username, password string) Option {
+	return func(c *ClientBase) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// WithCompression compresses the payloads of remote buffers transferred by
+// the rpc client, the payloads are transferred uncompressed if the server
+// doesn't support common.FeatureRPCCompression.
+func WithCompression() Option {
+	return func(c *ClientBase) {
+		c.compression = true
+	}
+}
+
+// WithoutReconnect disables reconnecting after the connection is lost, the
+// requests fail with ErrDisconnected afterwards.
+func WithoutReconnect() Option {
+	return func(c *ClientBase) {
+		c.noReconnect = true
+	}
+}
+
 type ClientBase struct {
 	// TODO: fix unify connection conn
-	conn       net.Conn
-	connected  bool
+	conn      net.Conn
+	connected bool
+	// instanceID is the instance registered to, accessed atomically as it
+	// changes on reconnecting.
 	instanceID common.InstanceID
+	// mutex serializes the requests on conn, as the replies carry no request
+	// id and must be read in the same order as requests are sent.
+	mutex sync.Mutex
+
+	options       []Option
+	policy        ReconnectPolicy
+	noReconnect   bool
+	blobFinalizer bool
+	storeType     string
+	username      string
+	password      string
+	compression   bool
+	// register connects and registers to vineyard server with the mutex held,
+	// it is set by Connect of IPCClient and RPCClient to reconnect on the same
+	// socket (or endpoint) once the connection is lost.
+	register func(ctx context.Context) error
+	// serverVersion is the common.Version of the registered server, which is
+	// read without the mutex and may change after reconnecting.
+	serverVersion atomic.Value
+	// sessionID is the session registered to, accessed atomically.
+	sessionID common.SessionID
+	// trailingChunk is set after compressed payloads are received, as
+	// vineyardd may end the stream with an empty chunk that is left before the
+	// next reply.
+	trailingChunk bool
+}
+
+func (c *ClientBase) InstanceID() common.InstanceID {
+	return atomic.LoadUint64(&c.instanceID)
+}
+
+// ServerVersion returns the version of vineyard server, which is
+// common.DEFAULT_SERVER_VERSION if the server doesn't report it.
+func (c *ClientBase) ServerVersion() common.Version {
+	if version, ok := c.serverVersion.Load().(common.Version); ok {
+		return version
+	}
+	return common.MustParseVersion(common.DEFAULT_SERVER_VERSION)
+}
+
+// Supports returns whether the connected vineyard server supports the
+// feature, the requests that rely on unsupported features fail with
+// common.ErrNotImplemented.
+func (c *ClientBase) Supports(feature common.Feature) bool {
+	return common.SupportsFeature(c.ServerVersion(), feature)
+}
+
+// SessionID returns the session of vineyard server the client registered to.
+func (c *ClientBase) SessionID() common.SessionID {
+	return atomic.LoadInt64(&c.sessionID)
+}
+
+// checkFeature returns an error if the server doesn't support the feature.
+func (c *ClientBase) checkFeature(feature common.Feature, request string) error {
+	if c.Supports(feature) {
+		return nil
+	}
+	since, _ := common.FeatureVersion(feature)
+	return &common.Status{
+		Code: common.KNotImplemented,
+		Message: fmt.Sprintf("%s requires vineyard server >= %s, but got %s",
+			feature, since, c.ServerVersion()),
+		Request: request,
+	}
+}
+
+// writeRegisterRequest writes the register request with the options of the
+// client, the session registered before is kept on reconnecting.
+func (c *ClientBase) writeRegisterRequest(messageOut *string) error {
+	return common.WriteRegisterRequest(c.storeType, c.SessionID(), c.username, c.password, messageOut)
+}
+
+// checkRegisterReply records the version and session replied on register,
+// and fails if the server is too old to work with, or the store type of the
+// session mismatches. Servers that don't report their version are accepted
+// but are considered to support no optional features.
+func (c *ClientBase) checkRegisterReply(reply *common.RegisterReply) error {
+	version := common.MustParseVersion(common.DEFAULT_SERVER_VERSION)
+	if reply.Version != "" {
+		var err error
+		if version, err = common.ParseVersion(reply.Version); err != nil {
+			return fmt.Errorf("%w: %v", ErrIncompatibleServer, err)
+		}
+		if version.Less(common.MustParseVersion(common.MIN_SERVER_VERSION)) {
+			return fmt.Errorf("%w: the version of server is %s, but at least %s is required",
+				ErrIncompatibleServer, version, common.MIN_SERVER_VERSION)
+		}
+	}
+	if common.SupportsFeature(version, common.FeatureStoreType) && !reply.StoreMatch {
+		return &common.Status{
+			Code:    common.KInvalid,
+			Message: fmt.Sprintf("mismatched store type, the client requires '%s'", c.storeType),
+			Request: common.REGISTER_REQUEST,
+		}
+	}
+	c.serverVersion.Store(version)
+	atomic.StoreInt64(&c.sessionID, reply.SessionID)
+	return nil
+}
+
+func (c *ClientBase) applyOptions(options []Option) {
+	c.policy = DefaultReconnectPolicy()
+	c.noReconnect = false
+	c.blobFinalizer = false
+	c.storeType = common.NormalStore
+	c.username, c.password = "", ""
+	c.compression = false
+	c.options = options
+	atomic.StoreInt64(&c.sessionID, common.RootSessionID())
+	for _, option := range options {
+		option(c)
+	}
+}
+
+// closeConn closes the connection, the client could be reconnected by the
+// next request.
+func (c *ClientBase) closeConn() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.connected = false
 }
 
 func (c *ClientBase) DoWrite(msgOut string) error {
 	err := SendMessage(c.conn, msgOut)
 	if err != nil {
-		c.connected = false
+		c.closeConn()
+		return &connError{sent: false, err: err}
 	}
 	return nil
 }
 
 func (c *ClientBase) DoRead(msg *string) error {
-	return RecvMessage(c.conn, msg)
+	err := RecvMessage(c.conn, msg)
+	if err == nil && *msg == "" && c.trailingChunk {
+		// replies are never empty, it is the end of the compressed stream
+		err = RecvMessage(c.conn, msg)
+	}
+	c.trailingChunk = false
+	if err != nil {
+		c.closeConn()
+		return &connError{sent: true, err: err}
+	}
+	return nil
 }
 
-func (c *ClientBase) Disconnect() error {
+// reconnect re-establishes the connection that has been lost, the caller must
+// hold the mutex.
+func (c *ClientBase) reconnect(ctx context.Context) error {
+	if c.register == nil || c.noReconnect {
+		return ErrDisconnected
+	}
+	if err := c.register(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: failed to reconnect: %v", ErrDisconnected, err)
+	}
+	return nil
+}
+
+// roundTrip runs fn with the exclusive access to the connection, fn is
+// expected to send a request and consume the whole reply. The connection is
+// re-established if it has been lost, and fn is retried once if the request
+// fails to be sent, as it hasn't been received by vineyard server.
+func (c *ClientBase) roundTrip(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	if !c.connected {
-		return nil
+		if err := c.reconnect(ctx); err != nil {
+			return err
+		}
 	}
-	var messageOut string
-	common.WriteExitRequest(&messageOut)
-	if err := c.DoWrite(messageOut); err != nil {
+	err := c.withContext(ctx, fn)
+	var connErr *connError
+	if errors.As(err, &connErr) && !connErr.sent && c.register != nil && !c.noReconnect {
+		if err := c.reconnect(ctx); err != nil {
+			return err
+		}
+		return c.withContext(ctx, fn)
+	}
+	return err
+}
+
+// withContext applies the deadline of ctx to the connection while running
+// fn, and interrupts fn once ctx is done. The connection is closed if fn is
+// interrupted, as the pending reply cannot be consumed anymore. The caller
+// must hold the mutex.
+func (c *ClientBase) withContext(ctx context.Context, fn func() error) error {
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
 		return err
 	}
-	c.conn.Close()
-	c.connected = false
-	return nil
+	if ctx.Done() != nil {
+		stop, done := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(done)
+			select {
+			case <-ctx.Done():
+				_ = c.conn.SetDeadline(time.Now())
+			case <-stop:
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-done
+		}()
+	}
+	err := fn()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		c.closeConn()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return context.DeadlineExceeded
+	}
+	return err
 }
 
-func (c *ClientBase) Persist(id common.ObjectID) error {
-	var messageOut string
-	common.WritePersistRequest(id, &messageOut)
+// request sends the request and decodes the reply into reply (if not nil),
+// the reply is checked against the expected reply type and error code. The
+// caller must hold the mutex.
+func (c *ClientBase) request(messageOut string, replyType string, reply interface{}) error {
 	if err := c.DoWrite(messageOut); err != nil {
 		return err
 	}
+	return c.readReply(messageOut, replyType, reply)
+}
+
+// readReply reads the reply of the request that has been sent, the caller
+// must hold the mutex.
+func (c *ClientBase) readReply(messageOut string, replyType string, reply interface{}) error {
 	var messageIn string
 	if err := c.DoRead(&messageIn); err != nil {
 		return err
 	}
-
-	var persistReply common.PersisReply
-	err := json.Unmarshal([]byte(messageIn), &persistReply)
-	if err != nil {
+	var header common.ReplyHeader
+	if err := common.DecodeMsg(messageIn, &header); err != nil {
 		return err
 	}
+	if header.Code != common.KOK || header.Type != replyType {
+		return common.CheckReply(header, requestType(messageOut), replyType)
+	}
+	if reply == nil {
+		return nil
+	}
+	return common.DecodeMsg(messageIn, reply)
+}
+
+// requestType returns the type of the encoded request, it is only used to
+// report errors as decoding the request is not free.
+func requestType(messageOut string) string {
+	var header struct {
+		Type string `json:"type"`
+	}
+	_ = json.Unmarshal([]byte(messageOut), &header)
+	return header.Type
+}
 
-	if persistReply.Code != 0 || persistReply.Type != common.PERSIST_REQUEST {
-		return errors.New("get persist response from vineyard failed")
+// doRequest is the request that respects ctx and is safe for concurrent use.
+func (c *ClientBase) doRequest(ctx context.Context, messageOut string, replyType string, reply interface{}) error {
+	return c.roundTrip(ctx, func() error {
+		return c.request(messageOut, replyType, reply)
+	})
+}
+
+// Disconnect closes the connection, and the client won't reconnect until
+// Connect is called again.
+func (c *ClientBase) Disconnect(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.register = nil
+	if !c.connected {
+		return nil
 	}
-	return nil
+	return c.withContext(ctx, func() error {
+		var messageOut string
+		if err := common.WriteExitRequest(&messageOut); err != nil {
+			return err
+		}
+		if err := c.DoWrite(messageOut); err != nil {
+			return err
+		}
+		c.closeConn()
+		return nil
+	})
 }
 
-func (c *ClientBase) PutName(id common.ObjectID, name string) error {
+// DeleteSession deletes the session that the client is connected to, and
+// disconnects the client. The root session cannot be deleted.
+func (c *ClientBase) DeleteSession(ctx context.Context) error {
+	if c.SessionID() == common.RootSessionID() {
+		return errors.New("the root session cannot be deleted")
+	}
 	var messageOut string
-	common.WritePutNameRequest(id, name, &messageOut)
-	if err := c.DoWrite(messageOut); err != nil {
+	if err := common.WriteDeleteSessionRequest(&messageOut); err != nil {
 		return err
 	}
-	var messageIn string
-	if err := c.DoRead(&messageIn); err != nil {
+	if err := c.doRequest(ctx, messageOut, common.DELETE_SESSION_REPLY, nil); err != nil {
 		return err
 	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.register = nil
+	c.closeConn()
+	return nil
+}
 
-	var putNameReply common.PutNameReply
-	err := json.Unmarshal([]byte(messageIn), &putNameReply)
-	if err != nil {
+func (c *ClientBase) Persist(ctx context.Context, id common.ObjectID) error {
+	var messageOut string
+	if err := common.WritePersistRequest(id, &messageOut); err != nil {
 		return err
 	}
+	return c.doRequest(ctx, messageOut, common.PERSIST_REPLY, nil)
+}
 
-	if putNameReply.Code != 0 || putNameReply.Type != common.PUT_NAME_REPLY {
-		return &common.ReplyError{Code: putNameReply.Code, Type: putNameReply.Type, Err: err}
+// IfPersist returns whether the object has been persisted, i.e., visible to
+// other vineyard instances.
+func (c *ClientBase) IfPersist(ctx context.Context, id common.ObjectID) (bool, error) {
+	var messageOut string
+	if err := common.WriteIfPersistRequest(id, &messageOut); err != nil {
+		return false, err
 	}
-	return nil
+	var ifPersistReply common.IfPersistReply
+	if err := c.doRequest(ctx, messageOut, common.IF_PERSIST_REPLY, &ifPersistReply); err != nil {
+		return false, err
+	}
+	return ifPersistReply.Persist, nil
 }
 
-func (c *ClientBase) GetName(name string, wait bool, id *common.ObjectID) error {
+// InstanceStatus returns the status of the vineyard instance the client
+// connects to, e.g., the usage of shared memory.
+func (c *ClientBase) InstanceStatus(ctx context.Context) (*common.InstanceStatus, error) {
 	var messageOut string
-	common.WriteGetNameRequest(name, wait, &messageOut)
-	if err := c.DoWrite(messageOut); err != nil {
-		return err
+	if err := common.WriteInstanceStatusRequest(&messageOut); err != nil {
+		return nil, err
 	}
-	var messageIn string
-	if err := c.DoRead(&messageIn); err != nil {
-		return err
+	var instanceStatusReply common.InstanceStatusReply
+	if err := c.doRequest(ctx, messageOut, common.INSTANCE_STATUS_REPLY, &instanceStatusReply); err != nil {
+		return nil, err
 	}
+	return &instanceStatusReply.Meta, nil
+}
 
-	var getNameReply common.GetNameReply
-	err := json.Unmarshal([]byte(messageIn), &getNameReply)
+// InstanceInfo describes a vineyard instance in the cluster.
+type InstanceInfo struct {
+	InstanceID common.InstanceID
+	Hostname   string
+	// Nodename is the name of the kubernetes node in kubernetes deployments,
+	// and the same as Hostname otherwise.
+	Nodename    string
+	IPCSocket   string
+	RPCEndpoint string
+}
+
+// ClusterInfo returns the instances in the cluster of the vineyard instance
+// the client connects to, keyed by the instance id.
+func (c *ClientBase) ClusterInfo(ctx context.Context) (map[common.InstanceID]InstanceInfo, error) {
+	meta, err := c.clusterMeta(ctx)
 	if err != nil {
+		return nil, err
+	}
+	cluster := make(map[common.InstanceID]InstanceInfo, len(meta))
+	for instanceID, status := range meta {
+		info := InstanceInfo{InstanceID: instanceID}
+		info.Hostname, _ = status["hostname"].(string)
+		info.Nodename, _ = status["nodename"].(string)
+		info.IPCSocket, _ = status["ipc_socket"].(string)
+		info.RPCEndpoint, _ = status["rpc_endpoint"].(string)
+		cluster[instanceID] = info
+	}
+	return cluster, nil
+}
+
+// clusterMeta returns the status of instances in the cluster, e.g., the
+// "rpc_endpoint" and "ipc_socket" of each instance.
+func (c *ClientBase) clusterMeta(ctx context.Context) (map[common.InstanceID]map[string]interface{}, error) {
+	var messageOut string
+	if err := common.WriteClusterMetaRequest(&messageOut); err != nil {
+		return nil, err
+	}
+	var clusterMetaReply common.ClusterMetaReply
+	if err := c.doRequest(ctx, messageOut, common.CLUSTER_META_REPLY, &clusterMetaReply); err != nil {
+		return nil, err
+	}
+	meta := make(map[common.InstanceID]map[string]interface{}, len(clusterMetaReply.Meta))
+	for key, value := range clusterMetaReply.Meta {
+		instanceID, err := strconv.ParseUint(strings.TrimPrefix(key, "i"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid instance in cluster meta: '%s'", key)
+		}
+		meta[instanceID] = value
+	}
+	return meta, nil
+}
+
+func (c *ClientBase) PutName(ctx context.Context, id common.ObjectID, name string) error {
+	var messageOut string
+	if err := common.WritePutNameRequest(id, name, &messageOut); err != nil {
 		return err
 	}
+	return c.doRequest(ctx, messageOut, common.PUT_NAME_REPLY, nil)
+}
 
-	if getNameReply.Code != 0 || getNameReply.Type != common.GET_NAME_REPLY {
-		return &common.ReplyError{Code: getNameReply.Code, Type: getNameReply.Type, Err: err}
+// GetName returns the object id of the name, if wait is true it blocks until
+// the name is put, or ctx is done.
+func (c *ClientBase) GetName(ctx context.Context, name string, wait bool, id *common.ObjectID) error {
+	var messageOut string
+	if err := common.WriteGetNameRequest(name, wait, &messageOut); err != nil {
+		return err
+	}
+	var getNameReply common.GetNameReply
+	if err := c.doRequest(ctx, messageOut, common.GET_NAME_REPLY, &getNameReply); err != nil {
+		return err
 	}
 	*id = getNameReply.RepObjectID
 	return nil
 }
 
-func (c *ClientBase) DropName(name string) error {
+func (c *ClientBase) DropName(ctx context.Context, name string) error {
 	var messageOut string
-	common.WriteDropNameRequest(name, &messageOut)
-	if err := c.DoWrite(messageOut); err != nil {
+	if err := common.WriteDropNameRequest(name, &messageOut); err != nil {
 		return err
 	}
-	var messageIn string
-	if err := c.DoRead(&messageIn); err != nil {
+	return c.doRequest(ctx, messageOut, common.DROP_NAME_REPLY, nil)
+}
+
+// ListNames lists at most limit names that match the pattern, which is a glob
+// pattern, or a regular expression if regex is true.
+func (c *ClientBase) ListNames(ctx context.Context, pattern string, regex bool, limit int) (map[string]common.ObjectID, error) {
+	var messageOut string
+	if err := common.WriteListNameRequest(pattern, regex, limit, &messageOut); err != nil {
+		return nil, err
+	}
+	var listNameReply common.ListNameReply
+	if err := c.doRequest(ctx, messageOut, common.LIST_NAME_REPLY, &listNameReply); err != nil {
+		return nil, err
+	}
+	if listNameReply.Names == nil {
+		return map[string]common.ObjectID{}, nil
+	}
+	return listNameReply.Names, nil
+}
+
+// LabelSelector selects the objects that have all of its labels.
+type LabelSelector map[string]string
+
+func (s LabelSelector) matches(meta *vineyard.ObjectMeta) bool {
+	labels := meta.GetLabels()
+	for key, value := range s {
+		if actual, ok := labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// maxListedObjects bounds the objects listed by ListObjects to select from,
+// it is a variable to be lowered by tests.
+var maxListedObjects = 1 << 20
+
+// ListObjects lists the metadata of at most limit objects whose typename
+// matches the pattern, which is a glob pattern, e.g., "vineyard::Tensor<*>",
+// or a regular expression if regex is true. The metadata are ordered by id.
+// If label selectors are given, only the objects that match all of them are
+// returned, and more objects are listed until limit objects are selected.
+// As vineyard server doesn't select by labels, at most maxListedObjects
+// objects are listed to select from, thus fewer than limit objects may be
+// returned even if more of them match.
+func (c *ClientBase) ListObjects(ctx context.Context, pattern string, regex bool, limit int, selectors ...LabelSelector) ([]*vineyard.ObjectMeta, error) {
+	// the server applies the limit before the selection, which is retried
+	// with a doubled limit until the listed objects are exhausted
+	listed := limit
+	for {
+		metas, count, err := c.listObjects(ctx, pattern, regex, listed, selectors)
+		if err != nil {
+			return nil, err
+		}
+		if len(selectors) == 0 || limit <= 0 || len(metas) >= limit || count < listed ||
+			listed >= maxListedObjects {
+			sort.Slice(metas, func(i, j int) bool {
+				return metas[i].GetId() < metas[j].GetId()
+			})
+			if limit > 0 && len(metas) > limit {
+				metas = metas[:limit]
+			}
+			return metas, nil
+		}
+		listed *= 2
+		if listed > maxListedObjects {
+			listed = maxListedObjects
+		}
+	}
+}
+
+// listObjects returns the objects that match the selectors, and the number
+// of listed objects.
+func (c *ClientBase) listObjects(ctx context.Context, pattern string, regex bool, limit int, selectors []LabelSelector) ([]*vineyard.ObjectMeta, int, error) {
+	var messageOut string
+	if err := common.WriteListDataRequest(pattern, regex, limit, &messageOut); err != nil {
+		return nil, 0, err
+	}
+	var getDataReply common.GetDataReply
+	if err := c.doRequest(ctx, messageOut, common.GET_DATA_REPLY, &getDataReply); err != nil {
+		return nil, 0, err
+	}
+	metas := make([]*vineyard.ObjectMeta, 0, len(getDataReply.Content))
+	for _, tree := range getDataReply.Content {
+		var meta vineyard.ObjectMeta
+		if err := meta.SetMetaData(c, tree); err != nil {
+			return nil, 0, err
+		}
+		if selectedByLabels(&meta, selectors) {
+			metas = append(metas, &meta)
+		}
+	}
+	return metas, len(getDataReply.Content), nil
+}
+
+func selectedByLabels(meta *vineyard.ObjectMeta, selectors []LabelSelector) bool {
+	for _, selector := range selectors {
+		if !selector.matches(meta) {
+			return false
+		}
+	}
+	return true
+}
+
+// Label attaches the labels to the object, existing labels with the same
+// keys are overwritten.
+func (c *ClientBase) Label(ctx context.Context, id common.ObjectID, labels map[string]string) error {
+	var messageOut string
+	if err := common.WriteLabelRequest(id, labels, &messageOut); err != nil {
 		return err
 	}
+	return c.doRequest(ctx, messageOut, common.LABEL_REPLY, nil)
+}
 
-	var dropNameReply common.DropNameReply
-	err := json.Unmarshal([]byte(messageIn), &dropNameReply)
-	if err != nil {
+// Exists returns whether the object exists in vineyard server.
+func (c *ClientBase) Exists(ctx context.Context, id common.ObjectID) (bool, error) {
+	var messageOut string
+	if err := common.WriteExistsRequest(id, &messageOut); err != nil {
+		return false, err
+	}
+	var existsReply common.ExistsReply
+	if err := c.doRequest(ctx, messageOut, common.EXISTS_REPLY, &existsReply); err != nil {
+		return false, err
+	}
+	return existsReply.Exists, nil
+}
+
+// DeleteOptions controls how objects are deleted, the zero value deletes
+// only the given objects if they are not referenced by other objects.
+type DeleteOptions struct {
+	// Force deletes the objects even if they are referenced by other
+	// objects, and the referencing objects are deleted as well.
+	Force bool
+	// Deep deletes the members of the objects as well if they are not
+	// referenced by other objects.
+	Deep bool
+	// MemoryTrim releases the freed memory of blobs back to the os.
+	MemoryTrim bool
+	// FastPath deletes the blobs without checking their dependencies, the
+	// ids are expected to be blobs only.
+	FastPath bool
+}
+
+func (c *ClientBase) Delete(ctx context.Context, ids []common.ObjectID, opts DeleteOptions) error {
+	var messageOut string
+	if err := common.WriteDelDataRequest(ids, opts.Force, opts.Deep, opts.MemoryTrim, opts.FastPath, &messageOut); err != nil {
 		return err
 	}
+	return c.doRequest(ctx, messageOut, common.DEL_DATA_REPLY, nil)
+}
 
-	if dropNameReply.Code != 0 || dropNameReply.Type != common.DROP_NAME_REPLY {
-		return &common.ReplyError{Code: dropNameReply.Code, Type: dropNameReply.Type, Err: err}
+// DeleteWithFeedback is like Delete but returns the ids of objects that are
+// actually deleted, including the members and referencing objects.
+func (c *ClientBase) DeleteWithFeedback(ctx context.Context, ids []common.ObjectID, opts DeleteOptions) ([]common.ObjectID, error) {
+	var messageOut string
+	if err := common.WriteDelDataWithFeedbacksRequest(ids, opts.Force, opts.Deep, opts.MemoryTrim, opts.FastPath, &messageOut); err != nil {
+		return nil, err
 	}
-	return nil
+	var reply common.DelDataWithFeedbacksReply
+	if err := c.doRequest(ctx, messageOut, common.DEL_DATA_WITH_FEEDBACKS_REPLY, &reply); err != nil {
+		return nil, err
+	}
+	return reply.DeletedIDs, nil
 }
 
-func (c *ClientBase) GetData(id common.ObjectID, getDataReply *common.GetDataReply, syncRemote, wait bool) error {
-	if !c.connected {
-		return nil
+// ShallowCopy creates a new object that shares the members (and payloads)
+// of the object, the extra metadata are added to the copied metadata.
+func (c *ClientBase) ShallowCopy(ctx context.Context, id common.ObjectID, extraMeta map[string]interface{}) (common.ObjectID, error) {
+	var messageOut string
+	if err := common.WriteShallowCopyRequest(id, extraMeta, &messageOut); err != nil {
+		return common.InvalidObjectID(), err
+	}
+	var reply common.ShallowCopyReply
+	if err := c.doRequest(ctx, messageOut, common.SHALLOW_COPY_REPLY, &reply); err != nil {
+		return common.InvalidObjectID(), err
 	}
+	return reply.TargetID, nil
+}
+
+// IncreaseReferenceCount adds references of this client to the blobs, which
+// prevents them from being deleted or spilled until they are released.
+func (c *ClientBase) IncreaseReferenceCount(ctx context.Context, ids ...common.ObjectID) error {
 	var messageOut string
-	common.WriteGetDataRequest(id, syncRemote, wait, &messageOut)
-	if err := c.DoWrite(messageOut); err != nil {
+	if err := common.WriteIncreaseReferenceCountRequest(ids, &messageOut); err != nil {
 		return err
 	}
-	var messageIn string
-	if err := c.DoRead(&messageIn); err != nil {
+	return c.doRequest(ctx, messageOut, common.INCREASE_REFERENCE_COUNT_REPLY, nil)
+}
+
+// Release drops the reference of this client to the blob, the references
+// are added when the blob is fetched or by IncreaseReferenceCount, and all of
+// them are dropped by vineyard server once the client disconnects.
+func (c *ClientBase) Release(ctx context.Context, id common.ObjectID) error {
+	var messageOut string
+	if err := common.WriteReleaseRequest(id, &messageOut); err != nil {
 		return err
 	}
-	err := json.Unmarshal([]byte(messageIn), getDataReply)
-	if err != nil {
+	return c.doRequest(ctx, messageOut, common.RELEASE_REPLY, nil)
+}
+
+func (c *ClientBase) GetData(ctx context.Context, id common.ObjectID, getDataReply *common.GetDataReply, syncRemote, wait bool) error {
+	var messageOut string
+	if err := common.WriteGetDataRequest(id, syncRemote, wait, &messageOut); err != nil {
 		return err
 	}
-	return nil
+	return c.doRequest(ctx, messageOut, common.GET_DATA_REPLY, getDataReply)
 }
 
-func (c *ClientBase) SyncMetaData() error {
+func (c *ClientBase) SyncMetaData(ctx context.Context) error {
 	var getDataReply common.GetDataReply
-	return c.GetData(common.InvalidObjectID(), &getDataReply, true, false)
+	return c.GetData(ctx, common.InvalidObjectID(), &getDataReply, true, false)
 }
 
-func (c *ClientBase) CreateData(tree interface{}, id *common.ObjectID, signature *Signature, instanceID *common.InstanceID) error {
-	if !c.connected {
-		return nil
-	}
+func (c *ClientBase) CreateData(ctx context.Context, tree interface{}, id *common.ObjectID, signature *Signature, instanceID *common.InstanceID) error {
 	var messageOut string
-	common.WriteCreateDataRequest(id, &messageOut)
-	if err := c.DoWrite(messageOut); err != nil {
-		return err
-	}
-	var messageIn string
-	if err := c.DoRead(&messageIn); err != nil {
+	if err := common.WriteCreateDataRequest(tree, &messageOut); err != nil {
 		return err
 	}
 	var createDataReply common.CreateDataReply
-	err := json.Unmarshal([]byte(messageIn), &createDataReply)
-	if err != nil {
+	if err := c.doRequest(ctx, messageOut, common.CREATE_DATA_REPLY, &createDataReply); err != nil {
 		return err
 	}
-	id = &createDataReply.ID
-	signature = &createDataReply.Signature
-	instanceID = &createDataReply.InstanceID
+	*id = createDataReply.ID
+	*signature = createDataReply.Signature
+	*instanceID = createDataReply.InstanceID
 	return nil
 }
 
-func (c *ClientBase) GetMetaData(id common.ObjectID, meta *vineyard.ObjectMeta, syncRemote bool) {
-	if !c.connected {
-		return
-	}
+func (c *ClientBase) GetMetaData(ctx context.Context, id common.ObjectID, meta *vineyard.ObjectMeta, syncRemote bool) error {
 	var getDataReply common.GetDataReply
-	err := c.GetData(id, &getDataReply, syncRemote, false)
-	if err != nil {
-		return
+	if err := c.GetData(ctx, id, &getDataReply, syncRemote, false); err != nil {
+		return err
+	}
+	tree, ok := getDataReply.Content[common.ObjectIDToString(id)]
+	if !ok || len(getDataReply.Content) != 1 {
+		return &common.Status{
+			Code:    common.KObjectNotExists,
+			Message: fmt.Sprintf("failed to read get_data reply for object %s", common.ObjectIDToString(id)),
+			Request: common.GET_DATA_REQUEST,
+		}
 	}
 	meta.Reset()
-	//meta.SetMetaData(*i, getDataReply) // TODO: how to set
-	// TODO
-	return
+	return meta.SetMetaData(c, tree)
 }
 
-func (c *ClientBase) CreateMetaData(metaData *vineyard.ObjectMeta, id common.ObjectID) {
-	var instanceID common.InstanceID = c.instanceID
-	metaData.Init()
+// CreateMetaData creates the metadata in vineyard server and fills the assigned
+// id, signature and instance id back to the metadata. If the metadata has
+// members that are only referenced by id, it will be replaced by the complete
+// metadata fetched from the server.
+func (c *ClientBase) CreateMetaData(ctx context.Context, metaData *vineyard.ObjectMeta, id *common.ObjectID) error {
+	var instanceID common.InstanceID = c.InstanceID()
 	metaData.SetInstanceId(instanceID)
+	metaData.AddKeyValue("transient", true)
+	// add the key from env to the metadata for k8s environment.
+	for _, label := range []string{"JOB_NAME", "POD_NAME", "POD_NAMESPACE"} {
+		if value := os.Getenv(label); value != "" {
+			metaData.AddKeyValue(label, value)
+		}
+	}
 	if !metaData.HasKey("nbytes") {
 		metaData.SetNBytes(0)
 	}
 	if metaData.InComplete() {
-		c.SyncMetaData() // TODO: check correctness
+		// the error is ignored, as the sync of remote metadata is best-effort
+		_ = c.SyncMetaData(ctx)
 	}
 	var signature Signature
-	err := c.CreateData(metaData.MetaData(), &id, &signature, &instanceID)
-	if err != nil {
-		metaData.SetId(id)
-		metaData.SetSignature(signature)
-		// metaData.SetClient(c) // TODO: fix type not match
-		metaData.SetInstanceId(instanceID)
-		if metaData.InComplete() {
-			var resultMeta vineyard.ObjectMeta
-			c.GetMetaData(id, &resultMeta, false)
-			metaData = &resultMeta
+	if err := c.CreateData(ctx, metaData.MetaData(), id, &signature, &instanceID); err != nil {
+		return err
+	}
+	metaData.SetId(*id)
+	metaData.SetSignature(signature)
+	metaData.SetClient(c)
+	metaData.SetInstanceId(instanceID)
+	if metaData.InComplete() {
+		var resultMeta vineyard.ObjectMeta
+		if err := c.GetMetaData(ctx, *id, &resultMeta, false); err != nil {
+			return err
 		}
+		*metaData = resultMeta
 	}
-	return
+	return nil
 }
@@ -0,0 +1,469 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vineyard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	vineyard "github.com/v6d-io/v6d/go/vineyard/pkg/client/ds"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/client/vineyardtest"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+// newTestServer starts an in-memory vineyard server that is closed once the
+// test finishes.
+func newTestServer(t *testing.T, options ...vineyardtest.Option) *vineyardtest.Server {
+	server, err := vineyardtest.NewServer(options...)
+	if err != nil {
+		t.Fatal("start vineyard server failed", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// newTestClient returns an IPC client connected to the server, which is
+// disconnected once the test finishes.
+func newTestClient(t *testing.T, server *vineyardtest.Server, options ...Option) *IPCClient {
+	var client IPCClient
+	if err := client.Connect(context.Background(), server.IPCSocket(), options...); err != nil {
+		t.Fatal("connect failed", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+	return &client
+}
+
+// newTestBlob creates a sealed blob of the content.
+func newTestBlob(t *testing.T, client *IPCClient, content string) common.ObjectID {
+	var writer vineyard.BlobWriter
+	if err := client.CreateBlob(context.Background(), len(content), &writer); err != nil {
+		t.Fatal("create blob failed", err)
+	}
+	copy(writer.Buf(), content)
+	if _, err := writer.Seal(context.Background()); err != nil {
+		t.Fatal("seal blob failed", err)
+	}
+	return writer.ID
+}
+
+// newTestScalar creates the metadata of a scalar of the value.
+func newTestScalar(t *testing.T, client *IPCClient, value int) common.ObjectID {
+	var meta vineyard.ObjectMeta
+	meta.Init()
+	meta.SetTypeName("vineyard::Scalar<int>")
+	meta.AddKeyValue("value_", value)
+	var id common.ObjectID
+	if err := client.CreateMetaData(context.Background(), &meta, &id); err != nil {
+		t.Fatal("create metadata failed", err)
+	}
+	return id
+}
+
+func TestClientBase_CreateMetaData(t *testing.T) {
+	server := newTestServer(t, vineyardtest.WithInstanceID(1))
+	client := newTestClient(t, server)
+	ctx := context.Background()
+
+	var meta vineyard.ObjectMeta
+	meta.Init()
+	meta.SetTypeName("vineyard::Scalar<int>")
+	meta.AddKeyValue("value_", 1)
+	var id common.ObjectID
+	if err := client.CreateMetaData(ctx, &meta, &id); err != nil {
+		t.Fatal("create metadata failed", err)
+	}
+	if id == common.InvalidObjectID() || meta.GetId() != id {
+		t.Error("the assigned object id is not returned", id, meta.GetId())
+	}
+	if meta.GetSignature() == common.InvalidSignature() {
+		t.Error("the assigned signature is not returned", meta.GetSignature())
+	}
+	if meta.GetInstanceId() != 1 || !meta.IsLocal() {
+		t.Error("the instance id is not returned", meta.GetInstanceId())
+	}
+
+	var created vineyard.ObjectMeta
+	if err := client.GetMetaData(ctx, id, &created, false); err != nil {
+		t.Fatal("get metadata failed", err)
+	}
+	content := created.MetaData().(map[string]interface{})
+	if created.GetTypeName() != "vineyard::Scalar<int>" || content["transient"] != true {
+		t.Error("unexpected metadata created in server", content)
+	}
+	if !created.HasKey("nbytes") || created.GetSignature() != meta.GetSignature() {
+		t.Error("nbytes or signature is missing in the metadata created in server", content)
+	}
+}
+
+func TestClientBase_CreateIncompleteMetaData(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+	blob := newTestBlob(t, client, "first")
+
+	var meta vineyard.ObjectMeta
+	meta.Init()
+	meta.SetTypeName("vineyard::Pair")
+	if err := meta.AddMemberId("first_", blob); err != nil {
+		t.Fatal("add member failed", err)
+	}
+	if !meta.InComplete() {
+		t.Fatal("the metadata should be incomplete")
+	}
+	var id common.ObjectID
+	if err := client.CreateMetaData(context.Background(), &meta, &id); err != nil {
+		t.Fatal("create metadata failed", err)
+	}
+	if meta.InComplete() {
+		t.Error("the metadata should be completed")
+	}
+	member, err := meta.GetMember("first_")
+	if err != nil || member.GetTypeName() != "vineyard::Blob" || member.GetNBytes() != 5 {
+		t.Error("the member is not completed", err)
+	}
+	if !meta.GetBufferSet().Contains(blob) {
+		t.Error("the blob is not in the buffer set")
+	}
+}
+
+func TestClientBase_CreateDataError(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+	server.Inject(common.CREAT_DATA_REQUEST, vineyardtest.Fault{
+		Code: common.KMetaTreeInvalid, Message: "invalid metadata", Times: 1,
+	})
+	var meta vineyard.ObjectMeta
+	meta.Init()
+	var id common.ObjectID = common.InvalidObjectID()
+	err := client.CreateMetaData(context.Background(), &meta, &id)
+	var status *common.Status
+	if !errors.As(err, &status) || !errors.Is(err, common.ErrMetaTreeInvalid) {
+		t.Fatal("expect a status error", err)
+	}
+	if status.Message != "invalid metadata" || status.Request != common.CREAT_DATA_REQUEST {
+		t.Error("the message and request should be carried by the status", status)
+	}
+	if id != common.InvalidObjectID() {
+		t.Error("the id shouldn't be changed on failure")
+	}
+}
+
+func TestClientBase_ListObjects(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+	ctx := context.Background()
+
+	var ids []common.ObjectID
+	for index := 0; index < 2; index++ {
+		meta, err := vineyard.BuildTensor(ctx, client, []int32{int32(index)}, []int64{1})
+		if err != nil {
+			t.Fatal("build tensor failed", err)
+		}
+		ids = append(ids, meta.GetId())
+	}
+	scalar := newTestScalar(t, client, 1)
+
+	metas, err := client.ListObjects(ctx, "vineyard::Tensor<*>", false, 10)
+	if err != nil || len(metas) != 2 {
+		t.Fatal("list objects failed", err, len(metas))
+	}
+	if metas[0].GetId() != ids[0] || metas[1].GetId() != ids[1] || metas[0].GetTypeName() != "vineyard::Tensor<int>" {
+		t.Error("unexpected metadata", metas[0].GetId(), metas[1].GetId(), metas[0].GetTypeName())
+	}
+
+	if err := client.PutName(ctx, ids[0], "tensor"); err != nil {
+		t.Fatal("put name failed", err)
+	}
+	names, err := client.ListNames(ctx, "tensor*", false, 10)
+	if err != nil || len(names) != 1 || names["tensor"] != ids[0] {
+		t.Error("unexpected names", names, err)
+	}
+
+	for id, expected := range map[common.ObjectID]bool{ids[1]: true, scalar: true, 0x10: false} {
+		if exists, err := client.Exists(ctx, id); err != nil || exists != expected {
+			t.Error("unexpected existence of object", id, exists, err)
+		}
+	}
+}
+
+func TestClientBase_Delete(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+	ctx := context.Background()
+
+	tensor, err := vineyard.BuildTensor(ctx, client, []int64{1, 2}, []int64{2})
+	if err != nil {
+		t.Fatal("build tensor failed", err)
+	}
+	buffer, err := tensor.GetMember("buffer_")
+	if err != nil {
+		t.Fatal("get buffer of tensor failed", err)
+	}
+	copied, err := client.ShallowCopy(ctx, tensor.GetId(), map[string]interface{}{"owner": "test"})
+	if err != nil || copied == tensor.GetId() {
+		t.Fatal("shallow copy failed", copied, err)
+	}
+	var meta vineyard.ObjectMeta
+	if err := client.GetMetaData(ctx, copied, &meta, false); err != nil {
+		t.Fatal("get metadata failed", err)
+	}
+	if owner := meta.MetaData().(map[string]interface{})["owner"]; owner != "test" {
+		t.Error("the extra metadata is not copied", owner)
+	}
+
+	// the members that are still used by other objects are kept
+	if err := client.Delete(ctx, []common.ObjectID{copied}, DeleteOptions{Deep: true}); err != nil {
+		t.Error("delete failed", err)
+	}
+	for id, expected := range map[common.ObjectID]bool{copied: false, tensor.GetId(): true, buffer.GetId(): true} {
+		if exists, err := client.Exists(ctx, id); err != nil || exists != expected {
+			t.Error("unexpected existence of object", common.ObjectIDToString(id), exists, err)
+		}
+	}
+	deleted, err := client.DeleteWithFeedback(ctx, []common.ObjectID{tensor.GetId()}, DeleteOptions{Deep: true})
+	if err != nil || fmt.Sprint(deleted) != fmt.Sprint([]common.ObjectID{tensor.GetId(), buffer.GetId()}) {
+		t.Error("unexpected deleted objects", deleted, err)
+	}
+}
+
+func TestIPCClient_Seal(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+	ctx := context.Background()
+	var id common.ObjectID
+	var payload vineyard.Payload
+	var buffer []byte
+	if err := client.CreateBuffer(ctx, 8, &id, &payload, &buffer); err != nil {
+		t.Fatal("create buffer failed", err)
+	}
+	if _, err := client.GetBlob(ctx, id); !errors.Is(err, common.ErrObjectNotSealed) {
+		t.Error("the blob that hasn't been sealed should not be got", err)
+	}
+	if err := client.Seal(ctx, id); err != nil {
+		t.Fatal("seal failed", err)
+	}
+	if _, err := client.GetBlob(ctx, id); err != nil {
+		t.Error("get the sealed blob failed", err)
+	}
+	if err := client.Seal(ctx, id); !errors.Is(err, common.ErrObjectSealed) {
+		t.Error("seal a sealed blob should fail", err)
+	}
+}
+
+func TestClientBase_ConcurrentRequests(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+	ctx := context.Background()
+	ids := make([]common.ObjectID, 64)
+	for index := range ids {
+		ids[index] = newTestScalar(t, client, index)
+		if err := client.PutName(ctx, ids[index], strconv.Itoa(index)); err != nil {
+			t.Fatal("put name failed", err)
+		}
+	}
+	var wg sync.WaitGroup
+	for index := range ids {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			var id common.ObjectID
+			if err := client.GetName(ctx, strconv.Itoa(index), false, &id); err != nil {
+				t.Error("get name failed", err)
+			} else if id != ids[index] {
+				t.Error("the reply of another request is received", id, ids[index])
+			}
+		}(index)
+	}
+	wg.Wait()
+}
+
+func TestClientBase_CancelRequest(t *testing.T) {
+	server := newTestServer(t)
+	// the name is never put
+	client := newTestClient(t, server, WithoutReconnect())
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	var id common.ObjectID
+	if err := client.GetName(ctx, "pending", true, &id); !errors.Is(err, context.Canceled) {
+		t.Fatal("get name should be canceled by the context", err)
+	}
+	// the pending reply cannot be consumed anymore
+	if err := client.DropName(context.Background(), "pending"); err == nil {
+		t.Error("the connection should be closed after cancellation")
+	}
+
+	client = newTestClient(t, server, WithoutReconnect())
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.GetName(ctx, "pending", true, &id); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("get name should fail once the context is done", err)
+	}
+}
+
+func TestClientBase_InstanceStatus(t *testing.T) {
+	server := newTestServer(t, vineyardtest.WithInstanceID(2), vineyardtest.WithMemory(1<<20))
+	ctx := context.Background()
+	var client IPCClient
+	if err := client.Connect(ctx, server.IPCSocket()); err != nil {
+		t.Fatal("connect failed", err)
+	}
+	defer client.Disconnect(ctx)
+	var rpcClient RPCClient
+	if err := rpcClient.Connect(ctx, server.RPCEndpoint()); err != nil {
+		t.Fatal("connect to rpc server failed", err)
+	}
+	defer rpcClient.Disconnect(ctx)
+
+	var blob vineyard.BlobWriter
+	if err := client.CreateBlob(ctx, 100, &blob); err != nil {
+		t.Fatal("create blob failed", err)
+	}
+	// the name is put after the status is checked
+	waiting := make(chan error, 1)
+	go func() {
+		var id common.ObjectID
+		waiting <- rpcClient.GetName(ctx, "pending", true, &id)
+	}()
+	var status *common.InstanceStatus
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		var err error
+		if status, err = client.InstanceStatus(ctx); err != nil {
+			t.Fatal("get instance status failed", err)
+		}
+		if status.DeferredRequests == 1 {
+			break
+		}
+	}
+	if status.InstanceID != 2 || status.DeferredRequests != 1 {
+		t.Error("unexpected instance status", status)
+	}
+	if status.MemoryUsage != 128 || status.MemoryLimit != 1<<20 {
+		t.Error("unexpected memory usage", status.MemoryUsage, status.MemoryLimit)
+	}
+	if status.IPCConnections != 1 || status.RPCConnections != 1 {
+		t.Error("unexpected connections", status.IPCConnections, status.RPCConnections)
+	}
+	var meta vineyard.ObjectMeta
+	meta.Init()
+	meta.SetTypeName("vineyard::Scalar<int>")
+	meta.AddKeyValue("value_", 1)
+	var id common.ObjectID
+	if err := client.CreateMetaData(ctx, &meta, &id); err != nil {
+		t.Fatal("create metadata failed", err)
+	}
+	if err := client.PutName(ctx, id, "pending"); err != nil {
+		t.Fatal("put name failed", err)
+	}
+	if err := <-waiting; err != nil {
+		t.Error("get name failed", err)
+	}
+
+	cluster, err := rpcClient.ClusterInfo(ctx)
+	if err != nil {
+		t.Fatal("get cluster info failed", err)
+	}
+	info, ok := cluster[2]
+	if len(cluster) != 1 || !ok || info.InstanceID != 2 {
+		t.Fatal("unexpected cluster info", cluster)
+	}
+	if info.IPCSocket != server.IPCSocket() || info.RPCEndpoint != server.RPCEndpoint() || info.Hostname == "" {
+		t.Error("unexpected instance info", info)
+	}
+}
+
+func TestClientBase_Label(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	var client IPCClient
+	if err := client.Connect(ctx, server.IPCSocket()); err != nil {
+		t.Fatal("connect failed", err)
+	}
+	defer client.Disconnect(ctx)
+
+	ids := make([]common.ObjectID, 3)
+	for index := range ids {
+		var meta vineyard.ObjectMeta
+		meta.Init()
+		meta.SetTypeName("vineyard::Scalar<int>")
+		meta.AddKeyValue("value_", index)
+		// the labels could be attached before the object is created
+		meta.SetLabel("k8s.v6d.io/job", "producer")
+		if err := client.CreateMetaData(ctx, &meta, &ids[index]); err != nil {
+			t.Fatal("create metadata failed", err)
+		}
+	}
+	if err := client.Label(ctx, ids[1], map[string]string{"run": "1", "k8s.v6d.io/job": "consumer"}); err != nil {
+		t.Fatal("label failed", err)
+	}
+	if err := client.Label(ctx, ids[2], map[string]string{"run": "1"}); err != nil {
+		t.Fatal("label failed", err)
+	}
+	if err := client.Label(ctx, 0x10, map[string]string{"run": "1"}); !errors.Is(err, common.ErrObjectNotExists) {
+		t.Error("label a missing object should fail", err)
+	}
+
+	var meta vineyard.ObjectMeta
+	if err := client.GetMetaData(ctx, ids[1], &meta, false); err != nil {
+		t.Fatal("get metadata failed", err)
+	}
+	if labels := meta.GetLabels(); fmt.Sprint(labels) != "map[k8s.v6d.io/job:consumer run:1]" {
+		t.Error("unexpected labels", labels)
+	}
+
+	for _, c := range []struct {
+		selectors []LabelSelector
+		expected  []common.ObjectID
+	}{
+		{nil, ids},
+		{[]LabelSelector{{"run": "1"}}, ids[1:]},
+		{[]LabelSelector{{"run": "1", "k8s.v6d.io/job": "producer"}}, ids[2:]},
+		{[]LabelSelector{{"run": "1"}, {"k8s.v6d.io/job": "consumer"}}, ids[1:2]},
+		{[]LabelSelector{{"run": "2"}}, nil},
+	} {
+		metas, err := client.ListObjects(ctx, "vineyard::Scalar<*>", false, 10, c.selectors...)
+		if err != nil {
+			t.Fatal("list objects failed", err)
+		}
+		selected := make([]common.ObjectID, 0, len(metas))
+		for _, meta := range metas {
+			selected = append(selected, meta.GetId())
+		}
+		if fmt.Sprint(selected) != fmt.Sprint(c.expected) {
+			t.Error("unexpected objects selected by", c.selectors, selected, c.expected)
+		}
+	}
+
+	// the objects that don't match are not counted in the limit
+	metas, err := client.ListObjects(ctx, "vineyard::Scalar<*>", false, 1, LabelSelector{"run": "1"})
+	if err != nil || len(metas) != 1 || metas[0].GetId() != ids[1] {
+		t.Error("unexpected objects selected with limit", metas, err)
+	}
+
+	// the selection is partial once the bounded objects have been listed
+	defer func(max int) { maxListedObjects = max }(maxListedObjects)
+	maxListedObjects = 2
+	selector := LabelSelector{"run": "1", "k8s.v6d.io/job": "producer"}
+	if metas, err := client.ListObjects(ctx, "vineyard::Scalar<*>", false, 1, selector); err != nil || len(metas) != 0 {
+		t.Error("unexpected objects selected from the bounded objects", metas, err)
+	}
+}
@@ -0,0 +1,323 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vineyard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/client/ds"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+// ClusterClient connects to every vineyard instance in a cluster via rpc, and
+// routes the requests of objects to the instances they live in, thus a
+// global object whose members are spread over the cluster could be read by
+// a single client.
+type ClusterClient struct {
+	mutex   sync.Mutex
+	options []Option
+	// seed is the client that the instances are discovered from
+	seed      *RPCClient
+	instances map[common.InstanceID]InstanceInfo
+	clients   map[common.InstanceID]*RPCClient
+}
+
+// Connect connects to the rpc endpoint of any instance in the cluster, e.g.,
+// the kubernetes service of vineyardd, and discovers the other instances
+// from its cluster info. The connections to other instances are established
+// with the same options once they are used.
+func (c *ClusterClient) Connect(ctx context.Context, rpcEndpoint string, options ...Option) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.seed != nil {
+		return fmt.Errorf("the client has already connected to %s", c.seed.rpcEndpoint)
+	}
+	seed := &RPCClient{}
+	if err := seed.Connect(ctx, rpcEndpoint, options...); err != nil {
+		return err
+	}
+	c.seed = seed
+	c.options = options
+	c.clients = map[common.InstanceID]*RPCClient{seed.RemoteInstanceID(): seed}
+	if err := c.refresh(ctx); err != nil {
+		_ = seed.Disconnect(ctx)
+		c.seed = nil
+		c.clients = nil
+		return err
+	}
+	return nil
+}
+
+// Refresh discovers the instances in the cluster again, e.g., once vineyardd
+// is scaled, the connections to the instances that have left are closed.
+func (c *ClusterClient) Refresh(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.seed == nil {
+		return ErrDisconnected
+	}
+	return c.refresh(ctx)
+}
+
+// refresh updates the instances from the cluster info, the caller must hold
+// the mutex.
+func (c *ClusterClient) refresh(ctx context.Context) error {
+	instances, err := c.seed.ClusterInfo(ctx)
+	if err != nil {
+		return err
+	}
+	for instanceID, client := range c.clients {
+		if _, ok := instances[instanceID]; !ok && client != c.seed {
+			_ = client.Disconnect(ctx)
+			delete(c.clients, instanceID)
+		}
+	}
+	c.instances = instances
+	return nil
+}
+
+// Instances returns the ids of the instances in the cluster in ascending
+// order.
+func (c *ClusterClient) Instances() []common.InstanceID {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	instances := make([]common.InstanceID, 0, len(c.instances))
+	for instanceID := range c.instances {
+		instances = append(instances, instanceID)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i] < instances[j]
+	})
+	return instances
+}
+
+// Client returns the client connected to the instance, the connection is
+// established on the first call.
+func (c *ClusterClient) Client(ctx context.Context, instanceID common.InstanceID) (*RPCClient, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.seed == nil {
+		return nil, ErrDisconnected
+	}
+	if client, ok := c.clients[instanceID]; ok {
+		return client, nil
+	}
+	info, ok := c.instances[instanceID]
+	if !ok || info.RPCEndpoint == "" {
+		return nil, fmt.Errorf("the instance %d is not found in the cluster", instanceID)
+	}
+	client := &RPCClient{}
+	if err := client.Connect(ctx, info.RPCEndpoint, c.options...); err != nil {
+		return nil, err
+	}
+	c.clients[instanceID] = client
+	return client, nil
+}
+
+func (c *ClusterClient) seedClient() (*RPCClient, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.seed == nil {
+		return nil, ErrDisconnected
+	}
+	return c.seed, nil
+}
+
+// fanOut calls fn with the client of each instance concurrently, and returns
+// the first error in the order of instances.
+func (c *ClusterClient) fanOut(ctx context.Context, instances []common.InstanceID,
+	fn func(instanceID common.InstanceID, client *RPCClient) error,
+) error {
+	errs := make([]error, len(instances))
+	var wg sync.WaitGroup
+	for index, instanceID := range instances {
+		wg.Add(1)
+		go func(index int, instanceID common.InstanceID) {
+			defer wg.Done()
+			client, err := c.Client(ctx, instanceID)
+			if err == nil {
+				err = fn(instanceID, client)
+			}
+			errs[index] = err
+		}(index, instanceID)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchBlobs gets the blobs from the instances they live in.
+func (c *ClusterClient) fetchBlobs(ctx context.Context, owners map[common.InstanceID][]common.ObjectID) (map[common.ObjectID]*ds.Blob, error) {
+	instances := make([]common.InstanceID, 0, len(owners))
+	for instanceID := range owners {
+		instances = append(instances, instanceID)
+	}
+	var mutex sync.Mutex
+	result := make(map[common.ObjectID]*ds.Blob)
+	err := c.fanOut(ctx, instances, func(instanceID common.InstanceID, client *RPCClient) error {
+		blobs, err := client.GetRemoteBlobs(ctx, owners[instanceID]...)
+		if err != nil {
+			return err
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, blob := range blobs {
+			result[blob.ID()] = blob
+		}
+		return nil
+	})
+	return result, err
+}
+
+// GetMetaData gets the metadata of the object, and the payload of blobs that
+// the object consists of are fetched from the instances they live in, thus
+// the object could be resolved without further requests.
+func (c *ClusterClient) GetMetaData(ctx context.Context, id common.ObjectID, meta *ds.ObjectMeta) error {
+	seed, err := c.seedClient()
+	if err != nil {
+		return err
+	}
+	if err := seed.GetMetaData(ctx, id, meta, true); err != nil {
+		return err
+	}
+	owners := make(map[common.InstanceID][]common.ObjectID)
+	for blobID, instanceID := range meta.Blobs() {
+		if blobID != common.EmptyBlobID() {
+			owners[instanceID] = append(owners[instanceID], blobID)
+		}
+	}
+	blobs, err := c.fetchBlobs(ctx, owners)
+	if err != nil {
+		return err
+	}
+	for _, blob := range blobs {
+		buffer, err := blob.Buffer()
+		if err != nil {
+			return err
+		}
+		meta.GetBufferSet().EmplaceBuffer(blob.ID())
+		if err := meta.GetBufferSet().SetBuffer(blob.ID(), buffer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetObject gets the metadata and buffers of the object from the instances
+// they live in, and resolves it by the resolver registered for its typename,
+// see ds.RegisterResolver.
+func (c *ClusterClient) GetObject(ctx context.Context, id common.ObjectID) (ds.Object, error) {
+	var meta ds.ObjectMeta
+	if err := c.GetMetaData(ctx, id, &meta); err != nil {
+		return nil, err
+	}
+	return ds.ResolveObject(&meta)
+}
+
+// GetBlobs returns the blobs in the same order of ids, which are fetched from
+// the instances they live in. The blobs of other instances than the one the
+// client is connected to should be persisted to be found.
+func (c *ClusterClient) GetBlobs(ctx context.Context, ids ...common.ObjectID) ([]*ds.Blob, error) {
+	seed, err := c.seedClient()
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[common.InstanceID][]common.ObjectID)
+	visited := make(map[common.ObjectID]bool)
+	for _, id := range ids {
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		instanceID := seed.RemoteInstanceID()
+		if id != common.EmptyBlobID() {
+			var meta ds.ObjectMeta
+			if err := seed.GetMetaData(ctx, id, &meta, true); err != nil {
+				return nil, err
+			}
+			instanceID = meta.GetInstanceId()
+		}
+		owners[instanceID] = append(owners[instanceID], id)
+	}
+	blobs, err := c.fetchBlobs(ctx, owners)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*ds.Blob, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, blobs[id])
+	}
+	return result, nil
+}
+
+// ListObjects lists the objects that match the pattern and label selectors
+// in every instance, as the transient objects are only visible in the
+// instance they live in. At most limit objects are returned in the order of
+// ids.
+func (c *ClusterClient) ListObjects(ctx context.Context, pattern string, regex bool, limit int, selectors ...LabelSelector) ([]*ds.ObjectMeta, error) {
+	var mutex sync.Mutex
+	objects := make(map[common.ObjectID]*ds.ObjectMeta)
+	err := c.fanOut(ctx, c.Instances(), func(instanceID common.InstanceID, client *RPCClient) error {
+		metas, err := client.ListObjects(ctx, pattern, regex, limit, selectors...)
+		if err != nil {
+			return err
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, meta := range metas {
+			objects[meta.GetId()] = meta
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	metas := make([]*ds.ObjectMeta, 0, len(objects))
+	for _, meta := range objects {
+		metas = append(metas, meta)
+	}
+	sort.Slice(metas, func(i, j int) bool {
+		return metas[i].GetId() < metas[j].GetId()
+	})
+	if limit > 0 && len(metas) > limit {
+		metas = metas[:limit]
+	}
+	return metas, nil
+}
+
+// Disconnect closes the connections to all instances, and returns the first
+// error.
+func (c *ClusterClient) Disconnect(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var result error
+	for _, client := range c.clients {
+		if err := client.Disconnect(ctx); err != nil && result == nil {
+			result = err
+		}
+	}
+	c.seed = nil
+	c.instances = nil
+	c.clients = nil
+	return result
+}
@@ -0,0 +1,126 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vineyard
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	vineyard "github.com/v6d-io/v6d/go/vineyard/pkg/client/ds"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/client/vineyardtest"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+func TestClusterClient_GlobalObject(t *testing.T) {
+	servers, err := vineyardtest.NewCluster(3)
+	if err != nil {
+		t.Fatal("start vineyard cluster failed", err)
+	}
+	for _, server := range servers {
+		server := server
+		t.Cleanup(func() { server.Close() })
+	}
+	ctx := context.Background()
+
+	// the partitions live in instance 1 and 2
+	var builder vineyard.GlobalObjectBuilder
+	var blobs []common.ObjectID
+	for index, server := range servers[1:] {
+		var client IPCClient
+		if err := client.Connect(ctx, server.IPCSocket()); err != nil {
+			t.Fatal("connect failed", err)
+		}
+		defer client.Disconnect(ctx)
+		values := []int64{int64(index), int64(index) + 10}
+		meta, err := vineyard.BuildTensor(ctx, &client, values, []int64{2})
+		if err != nil {
+			t.Fatal("build tensor failed", err)
+		}
+		if err := client.Persist(ctx, meta.GetId()); err != nil {
+			t.Fatal("persist tensor failed", err)
+		}
+		for id := range meta.Blobs() {
+			blobs = append(blobs, id)
+		}
+		builder.Init(&client, "")
+		builder.AddMember(meta)
+	}
+	if err := builder.Seal(ctx); err != nil {
+		t.Fatal("seal global object failed", err)
+	}
+
+	var client ClusterClient
+	if err := client.Connect(ctx, servers[0].RPCEndpoint()); err != nil {
+		t.Fatal("connect to cluster failed", err)
+	}
+	defer client.Disconnect(ctx)
+	if fmt.Sprint(client.Instances()) != "[0 1 2]" {
+		t.Fatal("unexpected instances", client.Instances())
+	}
+
+	object, err := client.GetObject(ctx, builder.Id())
+	if err != nil {
+		t.Fatal("get global object failed", err)
+	}
+	global, ok := object.(*vineyard.GlobalObject)
+	if !ok || global.Size() != 2 {
+		t.Fatal("unexpected global object", object)
+	}
+	for index, member := range global.Members() {
+		if member.GetInstanceId() != common.InstanceID(index+1) {
+			t.Error("unexpected instance of member", index, member.GetInstanceId())
+		}
+		tensor, err := vineyard.ResolveTensor(member)
+		if err != nil {
+			t.Fatal("resolve member failed", err)
+		}
+		values, err := vineyard.TensorValues[int64](tensor)
+		if expected := fmt.Sprint([]int64{int64(index), int64(index) + 10}); err != nil || fmt.Sprint(values) != expected {
+			t.Error("unexpected values of member", index, values, err)
+		}
+	}
+
+	result, err := client.GetBlobs(ctx, blobs...)
+	if err != nil || len(result) != len(blobs) {
+		t.Fatal("get blobs failed", result, err)
+	}
+	for index, blob := range result {
+		if blob.ID() != blobs[index] || blob.Size() != 16 {
+			t.Error("unexpected blob", blob.ID(), blob.Size())
+		}
+	}
+
+	metas, err := client.ListObjects(ctx, "vineyard::Tensor<*>", false, 10)
+	if err != nil || len(metas) != 2 {
+		t.Fatal("list objects failed", metas, err)
+	}
+	if metas, _ := client.ListObjects(ctx, "vineyard::Tensor<*>", false, 1); len(metas) != 1 {
+		t.Error("the limit should apply to all instances", metas)
+	}
+
+	// instance 2 leaves the cluster
+	servers[2].Close()
+	if err := client.Refresh(ctx); err != nil {
+		t.Fatal("refresh failed", err)
+	}
+	if fmt.Sprint(client.Instances()) != "[0 1]" {
+		t.Error("unexpected instances after refresh", client.Instances())
+	}
+	if _, err := client.Client(ctx, 2); err == nil {
+		t.Error("the instance that has left should not be found")
+	}
+}
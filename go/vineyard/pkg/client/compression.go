@@ -0,0 +1,154 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vineyard
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The compressed payloads of a request (or a reply) are a single zstd stream,
+// which is flushed after each payload but the frame never ends. The stream is
+// sent in chunks, each chunk is prefixed by its size as a 64-bit
+// little-endian integer, and the receiver decompresses exactly the size of
+// each payload from the stream.
+//
+// vineyardd sends every output of its compressor as a chunk, including the
+// empty ones: an empty chunk precedes the data of each payload, and an empty
+// chunk follows the payload during which the compressor is flushed for having
+// accumulated 64MB, i.e., src/server/util/compressor.cc. The empty chunks
+// before the data are skipped by the reader, and the one after the last
+// payload of a reply is skipped on reading the next reply.
+const compressionChunkSize = 128 << 10
+
+// chunkWriter sends the written bytes as chunks prefixed by their size.
+type chunkWriter struct {
+	conn net.Conn
+}
+
+func (w *chunkWriter) Write(data []byte) (int, error) {
+	written := 0
+	header := make([]byte, 8)
+	for written < len(data) {
+		chunk := data[written:]
+		if len(chunk) > compressionChunkSize {
+			chunk = chunk[:compressionChunkSize]
+		}
+		binary.LittleEndian.PutUint64(header, uint64(len(chunk)))
+		if err := SendBytes(w.conn, header, len(header)); err != nil {
+			return written, err
+		}
+		if err := SendBytes(w.conn, chunk, len(chunk)); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+	}
+	return written, nil
+}
+
+// chunkReader reads the chunks on demand, thus nothing beyond the bytes that
+// the decoder asks for is consumed from the connection.
+type chunkReader struct {
+	conn      net.Conn
+	remaining int
+}
+
+func (r *chunkReader) Read(data []byte) (int, error) {
+	header := make([]byte, 8)
+	for r.remaining == 0 {
+		if err := RecvBytes(r.conn, header, len(header)); err != nil {
+			return 0, err
+		}
+		r.remaining = int(binary.LittleEndian.Uint64(header))
+	}
+	if len(data) > r.remaining {
+		data = data[:r.remaining]
+	}
+	n, err := r.conn.Read(data)
+	r.remaining -= n
+	return n, err
+}
+
+// compressor compresses the payloads of a request into a single stream.
+type compressor struct {
+	encoder *zstd.Encoder
+}
+
+func newCompressor(conn net.Conn) (*compressor, error) {
+	encoder, err := zstd.NewWriter(&chunkWriter{conn: conn},
+		zstd.WithEncoderConcurrency(1), zstd.WithEncoderCRC(false))
+	if err != nil {
+		return nil, err
+	}
+	return &compressor{encoder: encoder}, nil
+}
+
+// send compresses size bytes read from reader, and flushes them to the
+// connection.
+func (c *compressor) send(reader io.Reader, size int) error {
+	if _, err := io.CopyN(c.encoder, reader, int64(size)); err != nil {
+		return err
+	}
+	return c.encoder.Flush()
+}
+
+// close releases the encoder once the stream is done, the end of the frame
+// is discarded rather than sent, as vineyardd expects no more chunks after
+// the payloads.
+func (c *compressor) close() {
+	c.encoder.Reset(io.Discard)
+	_ = c.encoder.Close()
+}
+
+// decompressor decompresses the payloads of a reply from a single stream, the
+// stream is started on the first payload as the decoder reads the frame
+// header and the first block eagerly.
+type decompressor struct {
+	conn    net.Conn
+	decoder *zstd.Decoder
+}
+
+// recv decompresses exactly len(data) bytes, the decoder doesn't read the
+// next block before the bytes of previous blocks are consumed, thus the
+// payloads that haven't been sent aren't waited for.
+func (d *decompressor) recv(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if d.decoder == nil {
+		decoder, err := zstd.NewReader(&chunkReader{conn: d.conn}, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return err
+		}
+		d.decoder = decoder
+	}
+	_, err := io.ReadFull(d.decoder, data)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// close stops the stream, which is required to release the decoder.
+func (d *decompressor) close() {
+	if d.decoder != nil {
+		d.decoder.Close()
+		d.decoder = nil
+	}
+}
@@ -1,6 +1,7 @@
 package ds
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/apache/arrow/go/arrow/array"
@@ -19,50 +20,48 @@ func (o *ObjectBuilder) SetSeal(seal bool) {
 	o.sealed = seal
 }
 
+// ArrayBuilder builds the vineyard array of an arrow array, see BuildArray
+// for the supported data types.
 type ArrayBuilder struct {
 	ArrayBaseBuilder
 
-	Array      *array.FixedSizeList
-	Client     IIPCClient
-	blobWriter BlobWriter
-
-	length    int
-	nullCount int
-	offset    int
-	buffer    []byte
-	//nullBitmap []byte
+	Array  array.Interface
+	Client IIPCClient
+	meta   *ObjectMeta
 }
 
-func (a *ArrayBuilder) Init(client IIPCClient, array *array.FixedSizeList) {
+func (a *ArrayBuilder) Init(client IIPCClient, array array.Interface) {
 	a.Client = client
 	a.Array = array
 }
 
-func (a *ArrayBuilder) Seal() {
+func (a *ArrayBuilder) Seal(ctx context.Context) error {
 	if a.sealed {
-		fmt.Println("The builder has been already been sealed")
-		return
+		return fmt.Errorf("the builder has already been sealed")
 	}
-	if err := a.Build(); err != nil {
-		fmt.Println("ArrayBuilder Build Failed: ", err)
+	if err := a.Build(ctx); err != nil {
+		return err
 	}
-
 	a.SetSeal(true)
+	return nil
 }
 
-func (a *ArrayBuilder) Build() error {
-
-	//blobWriter
-	a.Client.CreateBlob(a.Array.Data().Len(), &a.blobWriter)
-
-	a.length = a.Array.Len()
-	a.nullCount = a.Array.NullN()
-	a.offset = a.Array.Offset()
-	a.buffer = a.blobWriter.Buf()
-	// TODO: build null bit map
+func (a *ArrayBuilder) Build(ctx context.Context) error {
+	meta, err := BuildArray(ctx, a.Client, a.Array)
+	if err != nil {
+		return err
+	}
+	a.meta = meta
 	return nil
 }
 
 func (a *ArrayBuilder) Id() common.ObjectID {
-	return a.blobWriter.ID
+	if a.meta == nil {
+		return common.InvalidObjectID()
+	}
+	return a.meta.GetId()
+}
+
+func (a *ArrayBuilder) Meta() *ObjectMeta {
+	return a.meta
 }
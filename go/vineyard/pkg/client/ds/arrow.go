@@ -0,0 +1,549 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+// the value types of vineyard::NumericArray<T>, named after the C++ types
+var numericTypeNames = map[arrow.Type]string{
+	arrow.INT8:    "int8",
+	arrow.INT16:   "int16",
+	arrow.INT32:   "int32",
+	arrow.INT64:   "int64",
+	arrow.UINT8:   "uint8",
+	arrow.UINT16:  "uint16",
+	arrow.UINT32:  "uint32",
+	arrow.UINT64:  "uint64",
+	arrow.FLOAT32: "float",
+	arrow.FLOAT64: "double",
+}
+
+var numericTypes = map[string]arrow.DataType{
+	"int8":    arrow.PrimitiveTypes.Int8,
+	"int16":   arrow.PrimitiveTypes.Int16,
+	"int32":   arrow.PrimitiveTypes.Int32,
+	"int64":   arrow.PrimitiveTypes.Int64,
+	"uint8":   arrow.PrimitiveTypes.Uint8,
+	"uint16":  arrow.PrimitiveTypes.Uint16,
+	"uint32":  arrow.PrimitiveTypes.Uint32,
+	"uint64":  arrow.PrimitiveTypes.Uint64,
+	"float":   arrow.PrimitiveTypes.Float32,
+	"float32": arrow.PrimitiveTypes.Float32,
+	"double":  arrow.PrimitiveTypes.Float64,
+	"float64": arrow.PrimitiveTypes.Float64,
+}
+
+const (
+	largeStringArrayTypeName = "vineyard::BaseBinaryArray<arrow::LargeStringArray>"
+	largeBinaryArrayTypeName = "vineyard::BaseBinaryArray<arrow::LargeBinaryArray>"
+)
+
+// buildBuffer creates a blob with a copy of data, the empty blob is used if
+// data is empty.
+func buildBuffer(ctx context.Context, client IIPCClient, data []byte) (*ObjectMeta, error) {
+	if len(data) == 0 {
+		return newBlobMeta(client, common.EmptyBlobID(), nil), nil
+	}
+	var writer BlobWriter
+	if err := client.CreateBlob(ctx, len(data), &writer); err != nil {
+		return nil, err
+	}
+	copy(writer.Buf(), data)
+	blob, err := writer.Seal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newBlobMeta(client, blob.ID(), blob.buffer), nil
+}
+
+// newBlobMeta returns the metadata of a sealed blob in the same way as the
+// C++ client, which could be added as a member of other objects.
+func newBlobMeta(client IClient, id common.ObjectID, buffer []byte) *ObjectMeta {
+	var meta ObjectMeta
+	meta.Init()
+	meta.SetId(id)
+	meta.SetSignature(common.Signature(id))
+	meta.SetTypeName("vineyard::Blob")
+	meta.AddKeyValue("length", len(buffer))
+	meta.SetNBytes(len(buffer))
+	meta.SetClient(client)
+	meta.SetInstanceId(client.InstanceID())
+	meta.AddKeyValue("transient", true)
+	meta.bufferSet.EmplaceBuffer(id)
+	_ = meta.bufferSet.SetBuffer(id, memory.NewBufferBytes(buffer))
+	return &meta
+}
+
+func bufferBytes(buffer *memory.Buffer) []byte {
+	if buffer == nil {
+		return nil
+	}
+	return buffer.Bytes()
+}
+
+func arrayNBytes(arr array.Interface) int {
+	nbytes := 0
+	for _, buffer := range arr.Data().Buffers() {
+		nbytes += len(bufferBytes(buffer))
+	}
+	if list, ok := arr.(*array.FixedSizeList); ok {
+		nbytes += arrayNBytes(list.ListValues())
+	}
+	return nbytes
+}
+
+// toLargeOffsets converts the 32-bit offsets of arrow-go to the 64-bit
+// offsets that vineyard uses.
+func toLargeOffsets(offsets []byte) []byte {
+	large := make([]byte, len(offsets)/4*8)
+	for index := 0; index < len(offsets)/4; index++ {
+		offset := int32(binary.LittleEndian.Uint32(offsets[index*4:]))
+		binary.LittleEndian.PutUint64(large[index*8:], uint64(offset))
+	}
+	return large
+}
+
+func fromLargeOffsets(large []byte) ([]byte, error) {
+	offsets := make([]byte, len(large)/8*4)
+	for index := 0; index < len(large)/8; index++ {
+		offset := int64(binary.LittleEndian.Uint64(large[index*8:]))
+		if offset > math.MaxInt32 {
+			return nil, fmt.Errorf("the offset %d exceeds the limit of arrow-go's binary array", offset)
+		}
+		binary.LittleEndian.PutUint32(offsets[index*4:], uint32(offset))
+	}
+	return offsets, nil
+}
+
+// BuildArray creates the vineyard array (e.g., vineyard::NumericArray<T>)
+// from an arrow array, buffers are copied to blobs in vineyard server.
+func BuildArray(ctx context.Context, client IIPCClient, arr array.Interface) (*ObjectMeta, error) {
+	data := arr.Data()
+	var meta ObjectMeta
+	meta.Init()
+	meta.AddKeyValue("length_", arr.Len())
+
+	buffers := data.Buffers()
+	members := map[string][]byte{}
+	dtype := arr.DataType()
+	switch dtype.ID() {
+	case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64,
+		arrow.UINT8, arrow.UINT16, arrow.UINT32, arrow.UINT64, arrow.FLOAT32, arrow.FLOAT64:
+		meta.SetTypeName(fmt.Sprintf("vineyard::NumericArray<%s>", numericTypeNames[dtype.ID()]))
+		members["null_bitmap_"] = bufferBytes(buffers[0])
+		members["buffer_"] = bufferBytes(buffers[1])
+	case arrow.BOOL:
+		meta.SetTypeName("vineyard::BooleanArray")
+		members["null_bitmap_"] = bufferBytes(buffers[0])
+		members["buffer_"] = bufferBytes(buffers[1])
+	case arrow.STRING, arrow.BINARY:
+		if dtype.ID() == arrow.STRING {
+			meta.SetTypeName(largeStringArrayTypeName)
+		} else {
+			meta.SetTypeName(largeBinaryArrayTypeName)
+		}
+		members["null_bitmap_"] = bufferBytes(buffers[0])
+		members["buffer_offsets_"] = toLargeOffsets(bufferBytes(buffers[1]))
+		members["buffer_data_"] = bufferBytes(buffers[2])
+	case arrow.FIXED_SIZE_BINARY:
+		meta.SetTypeName("vineyard::FixedSizeBinaryArray")
+		meta.AddKeyValue("byte_width_", dtype.(*arrow.FixedSizeBinaryType).ByteWidth)
+		members["null_bitmap_"] = bufferBytes(buffers[0])
+		members["buffer_"] = bufferBytes(buffers[1])
+	case arrow.NULL:
+		meta.SetTypeName("vineyard::NullArray")
+	case arrow.FIXED_SIZE_LIST:
+		// the vineyard::FixedSizeListArray doesn't have a null bitmap
+		listSize := int64(dtype.(*arrow.FixedSizeListType).Len())
+		meta.SetTypeName("vineyard::FixedSizeListArray")
+		meta.AddKeyValue("list_size_", listSize)
+		values := array.NewSlice(arr.(*array.FixedSizeList).ListValues(),
+			int64(data.Offset())*listSize, int64(data.Offset()+arr.Len())*listSize)
+		defer values.Release()
+		valuesMeta, err := BuildArray(ctx, client, values)
+		if err != nil {
+			return nil, err
+		}
+		if err := meta.AddMember("values_", valuesMeta); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported arrow data type: %s", dtype)
+	}
+
+	if _, ok := members["null_bitmap_"]; ok {
+		meta.AddKeyValue("null_count_", arr.NullN())
+		meta.AddKeyValue("offset_", data.Offset())
+	}
+	for _, name := range []string{"null_bitmap_", "buffer_", "buffer_offsets_", "buffer_data_"} {
+		buffer, ok := members[name]
+		if !ok {
+			continue
+		}
+		blobMeta, err := buildBuffer(ctx, client, buffer)
+		if err != nil {
+			return nil, err
+		}
+		if err := meta.AddMember(name, blobMeta); err != nil {
+			return nil, err
+		}
+	}
+	meta.SetNBytes(arrayNBytes(arr))
+	var id common.ObjectID
+	if err := client.CreateMetaData(ctx, &meta, &id); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func memberBuffer(meta *ObjectMeta, name string) (*memory.Buffer, error) {
+	member, err := meta.GetMember(name)
+	if err != nil {
+		return nil, err
+	}
+	return meta.GetBuffer(member.GetId())
+}
+
+// nullBitmap returns nil for the empty null bitmap, as arrow-go requires.
+func nullBitmap(meta *ObjectMeta) (*memory.Buffer, error) {
+	buffer, err := memberBuffer(meta, "null_bitmap_")
+	if err != nil || buffer.Len() == 0 {
+		return nil, err
+	}
+	return buffer, nil
+}
+
+// ResolveArray resolves the arrow array from the metadata of a vineyard
+// array, the buffers are the shared memory of vineyard server and no copy
+// happens, except that the 64-bit offsets of binary arrays are converted to
+// 32-bit ones as arrow-go doesn't support large binaries.
+func ResolveArray(meta *ObjectMeta) (array.Interface, error) {
+	typeName := meta.GetTypeName()
+	length, err := GetKeyValue[int](meta, "length_")
+	if err != nil {
+		return nil, err
+	}
+	if typeName == "vineyard::NullArray" {
+		return array.NewNull(length), nil
+	}
+	if typeName == "vineyard::FixedSizeListArray" {
+		return resolveFixedSizeListArray(meta, length)
+	}
+	nullCount, err := GetKeyValue[int](meta, "null_count_")
+	if err != nil {
+		return nil, err
+	}
+	offset, err := GetKeyValue[int](meta, "offset_")
+	if err != nil {
+		return nil, err
+	}
+	bitmap, err := nullBitmap(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	var dtype arrow.DataType
+	var buffers []*memory.Buffer
+	switch {
+	case strings.HasPrefix(typeName, "vineyard::NumericArray<"):
+		valueType := strings.TrimSuffix(strings.TrimPrefix(typeName, "vineyard::NumericArray<"), ">")
+		if dtype = numericTypes[valueType]; dtype == nil {
+			return nil, fmt.Errorf("unsupported value type of numeric array: %s", valueType)
+		}
+		fallthrough
+	case typeName == "vineyard::BooleanArray":
+		if dtype == nil {
+			dtype = arrow.FixedWidthTypes.Boolean
+		}
+		buffer, err := memberBuffer(meta, "buffer_")
+		if err != nil {
+			return nil, err
+		}
+		buffers = []*memory.Buffer{bitmap, buffer}
+	case typeName == "vineyard::FixedSizeBinaryArray":
+		byteWidth, err := GetKeyValue[int](meta, "byte_width_")
+		if err != nil {
+			return nil, err
+		}
+		dtype = &arrow.FixedSizeBinaryType{ByteWidth: byteWidth}
+		buffer, err := memberBuffer(meta, "buffer_")
+		if err != nil {
+			return nil, err
+		}
+		buffers = []*memory.Buffer{bitmap, buffer}
+	case typeName == largeStringArrayTypeName, typeName == largeBinaryArrayTypeName:
+		dtype = arrow.BinaryTypes.String
+		if typeName == largeBinaryArrayTypeName {
+			dtype = arrow.BinaryTypes.Binary
+		}
+		largeOffsets, err := memberBuffer(meta, "buffer_offsets_")
+		if err != nil {
+			return nil, err
+		}
+		offsets, err := fromLargeOffsets(largeOffsets.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		data, err := memberBuffer(meta, "buffer_data_")
+		if err != nil {
+			return nil, err
+		}
+		buffers = []*memory.Buffer{bitmap, memory.NewBufferBytes(offsets), data}
+	default:
+		return nil, fmt.Errorf("unsupported vineyard array type: %s", typeName)
+	}
+	data := array.NewData(dtype, length, buffers, nil, nullCount, offset)
+	defer data.Release()
+	return array.MakeFromData(data), nil
+}
+
+func resolveFixedSizeListArray(meta *ObjectMeta, length int) (array.Interface, error) {
+	listSize, err := GetKeyValue[int32](meta, "list_size_")
+	if err != nil {
+		return nil, err
+	}
+	valuesMeta, err := meta.GetMember("values_")
+	if err != nil {
+		return nil, err
+	}
+	values, err := ResolveArray(valuesMeta)
+	if err != nil {
+		return nil, err
+	}
+	defer values.Release()
+	dtype := arrow.FixedSizeListOf(listSize, values.DataType())
+	data := array.NewData(dtype, length, []*memory.Buffer{nil}, []*array.Data{values.Data()}, 0, 0)
+	defer data.Release()
+	return array.MakeFromData(data), nil
+}
+
+// BuildRecordBatch creates a vineyard::RecordBatch from the arrow record.
+func BuildRecordBatch(ctx context.Context, client IIPCClient, record array.Record) (*ObjectMeta, error) {
+	var meta ObjectMeta
+	meta.Init()
+	meta.SetTypeName("vineyard::RecordBatch")
+	meta.AddKeyValue("row_num_", record.NumRows())
+	meta.AddKeyValue("column_num_", record.NumCols())
+	meta.AddKeyValue("__columns_-size", record.NumCols())
+
+	schemaMeta, err := buildSchema(ctx, client, record.Schema())
+	if err != nil {
+		return nil, err
+	}
+	if err := meta.AddMember("schema_", schemaMeta); err != nil {
+		return nil, err
+	}
+	nbytes := 0
+	for index, column := range record.Columns() {
+		columnMeta, err := BuildArray(ctx, client, column)
+		if err != nil {
+			return nil, err
+		}
+		if err := meta.AddMember("__columns_-"+strconv.Itoa(index), columnMeta); err != nil {
+			return nil, err
+		}
+		nbytes += columnMeta.GetNBytes()
+	}
+	meta.SetNBytes(nbytes)
+	var id common.ObjectID
+	if err := client.CreateMetaData(ctx, &meta, &id); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// ResolveRecordBatch resolves the arrow record from a vineyard::RecordBatch
+// without copying the payload.
+func ResolveRecordBatch(meta *ObjectMeta) (array.Record, error) {
+	if meta.GetTypeName() != "vineyard::RecordBatch" {
+		return nil, fmt.Errorf("expect typename 'vineyard::RecordBatch', but got '%s'", meta.GetTypeName())
+	}
+	schemaMeta, err := meta.GetMember("schema_")
+	if err != nil {
+		return nil, err
+	}
+	schema, err := resolveSchema(schemaMeta)
+	if err != nil {
+		return nil, err
+	}
+	numRows, err := GetKeyValue[int64](meta, "row_num_")
+	if err != nil {
+		return nil, err
+	}
+	numColumns, err := GetKeyValue[int](meta, "__columns_-size")
+	if err != nil {
+		return nil, err
+	}
+	if numColumns != len(schema.Fields()) {
+		return nil, fmt.Errorf("the number of columns doesn't match the schema: %d vs. %d",
+			numColumns, len(schema.Fields()))
+	}
+	columns := make([]array.Interface, 0, numColumns)
+	defer func() {
+		for _, column := range columns {
+			column.Release()
+		}
+	}()
+	for index := 0; index < numColumns; index++ {
+		columnMeta, err := meta.GetMember("__columns_-" + strconv.Itoa(index))
+		if err != nil {
+			return nil, err
+		}
+		column, err := ResolveArray(columnMeta)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return array.NewRecord(schema, columns, numRows), nil
+}
+
+// BuildTable creates a vineyard::Table from the arrow table, each chunk of
+// the table becomes a vineyard::RecordBatch.
+func BuildTable(ctx context.Context, client IIPCClient, table array.Table) (*ObjectMeta, error) {
+	var meta ObjectMeta
+	meta.Init()
+	meta.SetTypeName("vineyard::Table")
+	meta.AddKeyValue("num_rows_", table.NumRows())
+	meta.AddKeyValue("num_columns_", table.NumCols())
+
+	schemaMeta, err := buildSchema(ctx, client, table.Schema())
+	if err != nil {
+		return nil, err
+	}
+	if err := meta.AddMember("schema_", schemaMeta); err != nil {
+		return nil, err
+	}
+	batchNum, nbytes := 0, 0
+	if table.NumRows() > 0 {
+		reader := array.NewTableReader(table, table.NumRows())
+		defer reader.Release()
+		for reader.Next() {
+			batchMeta, err := BuildRecordBatch(ctx, client, reader.Record())
+			if err != nil {
+				return nil, err
+			}
+			if err := meta.AddMember("partitions_-"+strconv.Itoa(batchNum), batchMeta); err != nil {
+				return nil, err
+			}
+			batchNum++
+			nbytes += batchMeta.GetNBytes()
+		}
+	}
+	meta.AddKeyValue("batch_num_", batchNum)
+	meta.AddKeyValue("partitions_-size", batchNum)
+	meta.SetNBytes(nbytes)
+	var id common.ObjectID
+	if err := client.CreateMetaData(ctx, &meta, &id); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// ResolveTable resolves the arrow table from a vineyard::Table without
+// copying the payload, all batches of the table are expected to be local.
+func ResolveTable(meta *ObjectMeta) (array.Table, error) {
+	if meta.GetTypeName() != "vineyard::Table" {
+		return nil, fmt.Errorf("expect typename 'vineyard::Table', but got '%s'", meta.GetTypeName())
+	}
+	schemaMeta, err := meta.GetMember("schema_")
+	if err != nil {
+		return nil, err
+	}
+	schema, err := resolveSchema(schemaMeta)
+	if err != nil {
+		return nil, err
+	}
+	batchNum, err := GetKeyValue[int](meta, "partitions_-size")
+	if err != nil {
+		return nil, err
+	}
+	records := make([]array.Record, 0, batchNum)
+	defer func() {
+		for _, record := range records {
+			record.Release()
+		}
+	}()
+	for index := 0; index < batchNum; index++ {
+		batchMeta, err := meta.GetMember("partitions_-" + strconv.Itoa(index))
+		if err != nil {
+			return nil, err
+		}
+		record, err := ResolveRecordBatch(batchMeta)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return array.NewTableFromRecords(schema, records), nil
+}
+
+// ArrowArray is a vineyard array that resolved as an arrow array.
+type ArrowArray struct {
+	ObjectBase
+
+	Array array.Interface
+}
+
+func ResolveArrowArray(meta *ObjectMeta) (*ArrowArray, error) {
+	arr, err := ResolveArray(meta)
+	if err != nil {
+		return nil, err
+	}
+	return &ArrowArray{ObjectBase: ObjectBase{meta: meta}, Array: arr}, nil
+}
+
+// ArrowRecordBatch is a vineyard::RecordBatch that resolved as an arrow record.
+type ArrowRecordBatch struct {
+	ObjectBase
+
+	Record array.Record
+}
+
+func ResolveArrowRecordBatch(meta *ObjectMeta) (*ArrowRecordBatch, error) {
+	record, err := ResolveRecordBatch(meta)
+	if err != nil {
+		return nil, err
+	}
+	return &ArrowRecordBatch{ObjectBase: ObjectBase{meta: meta}, Record: record}, nil
+}
+
+// ArrowTable is a vineyard::Table that resolved as an arrow table.
+type ArrowTable struct {
+	ObjectBase
+
+	Table array.Table
+}
+
+func ResolveArrowTable(meta *ObjectMeta) (*ArrowTable, error) {
+	table, err := ResolveTable(meta)
+	if err != nil {
+		return nil, err
+	}
+	return &ArrowTable{ObjectBase: ObjectBase{meta: meta}, Table: table}, nil
+}
@@ -0,0 +1,322 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+// Vineyard stores strings, binaries and lists with 64-bit offsets (i.e., as
+// arrow's large types), while arrow-go cannot (de)serialize schemas of these
+// types. The "Type" union of arrow's flatbuffer schema uses empty tables for
+// both the regular and the large variants, thus the schema is converted by
+// rewriting the union tag of fields in place.
+const (
+	fbTypeBinary      = 4
+	fbTypeUtf8        = 5
+	fbTypeList        = 12
+	fbTypeLargeBinary = 19
+	fbTypeLargeUtf8   = 20
+	fbTypeLargeList   = 21
+
+	fbMessageHeaderSchema = 1
+)
+
+var (
+	toLargeTypes   = map[byte]byte{fbTypeBinary: fbTypeLargeBinary, fbTypeUtf8: fbTypeLargeUtf8, fbTypeList: fbTypeLargeList}
+	fromLargeTypes = map[byte]byte{fbTypeLargeBinary: fbTypeBinary, fbTypeLargeUtf8: fbTypeUtf8, fbTypeLargeList: fbTypeList}
+)
+
+// serializeSchema serializes the schema as an arrow IPC message, in which
+// strings and binaries are marked as large types.
+func serializeSchema(schema *arrow.Schema) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := ipc.NewWriter(&buffer, ipc.WithSchema(schema), ipc.WithAllocator(memory.NewGoAllocator()))
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	message := buffer.Bytes()
+	// drop the end-of-stream marker
+	eos := []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}
+	message = bytes.TrimSuffix(message, eos)
+	if err := rewriteSchemaTypes(message, toLargeTypes); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// deserializeSchema deserializes the arrow IPC message of the schema, large
+// types are read as the regular ones.
+func deserializeSchema(message []byte) (*arrow.Schema, error) {
+	message = append([]byte(nil), message...)
+	if err := rewriteSchemaTypes(message, fromLargeTypes); err != nil {
+		return nil, err
+	}
+	reader, err := ipc.NewReader(bytes.NewReader(message))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Release()
+	return reader.Schema(), nil
+}
+
+func rewriteSchemaTypes(message []byte, types map[byte]byte) error {
+	fb, err := flatbufferOfMessage(message)
+	if err != nil {
+		return err
+	}
+	root, err := fb.root()
+	if err != nil {
+		return err
+	}
+	// Message: version, header_type, header, bodyLength, custom_metadata
+	headerType, ok := fb.field(root, 1)
+	if !ok || fb.buf[headerType] != fbMessageHeaderSchema {
+		return errors.New("the message is not an arrow schema")
+	}
+	header, ok := fb.reference(root, 2)
+	if !ok {
+		return errors.New("the message is not an arrow schema")
+	}
+	// Schema: endianness, fields, custom_metadata, features
+	fields, ok := fb.reference(header, 1)
+	if !ok {
+		return nil
+	}
+	return fb.rewriteFields(fields, types)
+}
+
+type flatbuffer struct {
+	buf []byte
+}
+
+func flatbufferOfMessage(message []byte) (*flatbuffer, error) {
+	if len(message) < 8 {
+		return nil, errors.New("invalid arrow schema message")
+	}
+	offset := 4
+	if binary.LittleEndian.Uint32(message) == 0xffffffff {
+		// continuation marker
+		offset = 8
+	}
+	length := int(int32(binary.LittleEndian.Uint32(message[offset-4:])))
+	if length <= 0 || offset+length > len(message) {
+		return nil, errors.New("invalid arrow schema message")
+	}
+	return &flatbuffer{buf: message[offset : offset+length]}, nil
+}
+
+func (fb *flatbuffer) check(pos, size int) error {
+	if pos < 0 || pos+size > len(fb.buf) {
+		return errors.New("invalid flatbuffer: out of bounds")
+	}
+	return nil
+}
+
+func (fb *flatbuffer) root() (int, error) {
+	if err := fb.check(0, 4); err != nil {
+		return 0, err
+	}
+	root := int(binary.LittleEndian.Uint32(fb.buf))
+	return root, fb.check(root, 4)
+}
+
+// field returns the position of the slot-th field of the table.
+func (fb *flatbuffer) field(table int, slot int) (int, bool) {
+	vtable := table - int(int32(binary.LittleEndian.Uint32(fb.buf[table:])))
+	if fb.check(vtable, 4) != nil {
+		return 0, false
+	}
+	vtableSize := int(binary.LittleEndian.Uint16(fb.buf[vtable:]))
+	entry := 4 + 2*slot
+	if entry+2 > vtableSize || fb.check(vtable+entry, 2) != nil {
+		return 0, false
+	}
+	offset := int(binary.LittleEndian.Uint16(fb.buf[vtable+entry:]))
+	if offset == 0 || fb.check(table+offset, 1) != nil {
+		return 0, false
+	}
+	return table + offset, true
+}
+
+// reference returns the position of the table or vector referenced by the
+// slot-th field of the table.
+func (fb *flatbuffer) reference(table int, slot int) (int, bool) {
+	pos, ok := fb.field(table, slot)
+	if !ok || fb.check(pos, 4) != nil {
+		return 0, false
+	}
+	target := pos + int(binary.LittleEndian.Uint32(fb.buf[pos:]))
+	if fb.check(target, 4) != nil {
+		return 0, false
+	}
+	return target, true
+}
+
+func (fb *flatbuffer) rewriteFields(vector int, types map[byte]byte) error {
+	length := int(binary.LittleEndian.Uint32(fb.buf[vector:]))
+	if err := fb.check(vector+4, 4*length); err != nil {
+		return err
+	}
+	for index := 0; index < length; index++ {
+		pos := vector + 4 + 4*index
+		field := pos + int(binary.LittleEndian.Uint32(fb.buf[pos:]))
+		if err := fb.check(field, 4); err != nil {
+			return err
+		}
+		// Field: name, nullable, type_type, type, dictionary, children, custom_metadata
+		if typeType, ok := fb.field(field, 2); ok {
+			if target, ok := types[fb.buf[typeType]]; ok {
+				fb.buf[typeType] = target
+			}
+		}
+		if children, ok := fb.reference(field, 5); ok {
+			if err := fb.rewriteFields(children, types); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// schemaToJSON returns the textual representation of the schema, which is
+// the same as the "schema_textual_" generated by the C++ SchemaProxy.
+func schemaToJSON(schema *arrow.Schema) (string, error) {
+	fields := make([]interface{}, 0, len(schema.Fields()))
+	for _, field := range schema.Fields() {
+		object, err := fieldToJSON(field)
+		if err != nil {
+			return "", err
+		}
+		fields = append(fields, object)
+	}
+	metadata := make(map[string]string)
+	for index, key := range schema.Metadata().Keys() {
+		metadata[key] = schema.Metadata().Values()[index]
+	}
+	textual, err := json.Marshal(map[string]interface{}{"fields": fields, "metadata": metadata})
+	return string(textual), err
+}
+
+func fieldToJSON(field arrow.Field) (map[string]interface{}, error) {
+	dtype, err := dataTypeToJSON(field.Type)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"name": field.Name, "type": dtype, "nullable": field.Nullable}, nil
+}
+
+func dataTypeToJSON(dtype arrow.DataType) (map[string]interface{}, error) {
+	integer := func(bitWidth int, signed bool) map[string]interface{} {
+		return map[string]interface{}{"name": "int", "bit_width": bitWidth, "signed": signed}
+	}
+	switch dtype.ID() {
+	case arrow.NULL:
+		return map[string]interface{}{"name": "null"}, nil
+	case arrow.BOOL:
+		return map[string]interface{}{"name": "bool"}, nil
+	case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64:
+		return integer(dtype.(arrow.FixedWidthDataType).BitWidth(), true), nil
+	case arrow.UINT8, arrow.UINT16, arrow.UINT32, arrow.UINT64:
+		return integer(dtype.(arrow.FixedWidthDataType).BitWidth(), false), nil
+	case arrow.FLOAT32:
+		return map[string]interface{}{"name": "float", "precision": "single"}, nil
+	case arrow.FLOAT64:
+		return map[string]interface{}{"name": "float", "precision": "double"}, nil
+	case arrow.STRING:
+		return map[string]interface{}{"name": "large_utf8"}, nil
+	case arrow.BINARY:
+		return map[string]interface{}{"name": "large_binary"}, nil
+	case arrow.FIXED_SIZE_BINARY:
+		return map[string]interface{}{
+			"name": "fixed_size_binary", "byte_width": dtype.(*arrow.FixedSizeBinaryType).ByteWidth,
+		}, nil
+	case arrow.FIXED_SIZE_LIST:
+		listType := dtype.(*arrow.FixedSizeListType)
+		valueType, err := dataTypeToJSON(listType.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"name": "fixed_size_list", "value_type": valueType, "list_size": listType.Len(),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported arrow data type: %s", dtype)
+}
+
+// buildSchema creates the vineyard::SchemaProxy of the given schema.
+func buildSchema(ctx context.Context, client IIPCClient, schema *arrow.Schema) (*ObjectMeta, error) {
+	message, err := serializeSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	textual, err := schemaToJSON(schema)
+	if err != nil {
+		return nil, err
+	}
+	// the json encoding of binary values in nlohmann/json
+	binaryValue, err := json.Marshal(map[string]interface{}{"bytes": bytesToInts(message), "subtype": nil})
+	if err != nil {
+		return nil, err
+	}
+	var meta ObjectMeta
+	meta.Init()
+	meta.SetTypeName("vineyard::SchemaProxy")
+	meta.AddKeyValue("schema_textual_", textual)
+	meta.AddKeyValue("schema_binary_", string(binaryValue))
+	meta.SetNBytes(len(message))
+	var id common.ObjectID
+	if err := client.CreateMetaData(ctx, &meta, &id); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// resolveSchema resolves the schema from a vineyard::SchemaProxy.
+func resolveSchema(meta *ObjectMeta) (*arrow.Schema, error) {
+	if meta.GetTypeName() != "vineyard::SchemaProxy" {
+		return nil, fmt.Errorf("expect typename 'vineyard::SchemaProxy', but got '%s'", meta.GetTypeName())
+	}
+	binaryValue, err := GetKeyValue[struct {
+		Bytes []int `json:"bytes"`
+	}](meta, "schema_binary_")
+	if err != nil {
+		return nil, err
+	}
+	message := make([]byte, len(binaryValue.Bytes))
+	for index, value := range binaryValue.Bytes {
+		message[index] = byte(value)
+	}
+	return deserializeSchema(message)
+}
+
+func bytesToInts(data []byte) []int {
+	values := make([]int, len(data))
+	for index, value := range data {
+		values[index] = int(value)
+	}
+	return values
+}
@@ -0,0 +1,229 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+	"gotest.tools/v3/assert"
+)
+
+// fakeIPCClient keeps blobs and metadata in memory.
+type fakeIPCClient struct {
+	fakeClient
+	nextID    common.ObjectID
+	blobs     map[common.ObjectID][]byte
+	persisted map[common.ObjectID]bool
+}
+
+func newFakeIPCClient() *fakeIPCClient {
+	return &fakeIPCClient{
+		fakeClient: fakeClient{instanceID: 1},
+		nextID:     0x10,
+		blobs:      make(map[common.ObjectID][]byte),
+	}
+}
+
+func (f *fakeIPCClient) CreateBlob(ctx context.Context, size int, blob *BlobWriter) error {
+	f.nextID++
+	id := f.nextID | 0x8000000000000000
+	f.blobs[id] = make([]byte, size)
+	blob.Reset(f, id, Payload{ID: id, DataSize: size}, f.blobs[id])
+	return nil
+}
+
+func (f *fakeIPCClient) DropBuffer(ctx context.Context, id common.ObjectID) error {
+	delete(f.blobs, id)
+	return nil
+}
+
+func (f *fakeIPCClient) CreateMetaData(ctx context.Context, meta *ObjectMeta, id *common.ObjectID) error {
+	f.nextID++
+	*id = f.nextID
+	meta.SetId(*id)
+	meta.SetSignature(common.Signature(*id))
+	meta.SetInstanceId(f.instanceID)
+	meta.SetClient(f)
+	return nil
+}
+
+func (f *fakeIPCClient) Seal(ctx context.Context, id common.ObjectID) error {
+	return nil
+}
+
+func (f *fakeIPCClient) Persist(ctx context.Context, id common.ObjectID) error {
+	if f.persisted == nil {
+		f.persisted = make(map[common.ObjectID]bool)
+	}
+	f.persisted[id] = true
+	return nil
+}
+
+// getMetaData mimics the metadata that is fetched from vineyard server.
+func (f *fakeIPCClient) getMetaData(t *testing.T, meta *ObjectMeta) *ObjectMeta {
+	content, err := json.Marshal(meta.MetaData())
+	assert.NilError(t, err)
+	var tree map[string]interface{}
+	assert.NilError(t, common.DecodeMsg(string(content), &tree))
+	var result ObjectMeta
+	assert.NilError(t, result.SetMetaData(f, tree))
+	for _, id := range result.GetBufferSet().AllBufferIds() {
+		if buffer, ok := f.blobs[id]; ok {
+			assert.NilError(t, result.GetBufferSet().SetBuffer(id, memory.NewBufferBytes(buffer)))
+		}
+	}
+	return &result
+}
+
+func TestArrow_NumericArray(t *testing.T) {
+	client := newFakeIPCClient()
+	builder := array.NewInt64Builder(memory.NewGoAllocator())
+	defer builder.Release()
+	builder.AppendValues([]int64{1, 2, 0, 4}, []bool{true, true, false, true})
+	arr := builder.NewArray()
+	defer arr.Release()
+
+	meta, err := BuildArray(context.Background(), client, arr)
+	assert.NilError(t, err)
+	assert.Equal(t, meta.GetTypeName(), "vineyard::NumericArray<int64>")
+	assert.Equal(t, meta.GetNBytes(), arrayNBytes(arr))
+
+	resolved, err := ResolveArray(client.getMetaData(t, meta))
+	assert.NilError(t, err)
+	defer resolved.Release()
+	assert.Assert(t, array.ArrayEqual(arr, resolved))
+}
+
+func TestArrow_StringArray(t *testing.T) {
+	client := newFakeIPCClient()
+	builder := array.NewStringBuilder(memory.NewGoAllocator())
+	defer builder.Release()
+	builder.AppendValues([]string{"hello", "", "vineyard"}, []bool{true, false, true})
+	arr := builder.NewArray()
+	defer arr.Release()
+	slice := array.NewSlice(arr, 1, 3)
+	defer slice.Release()
+
+	meta, err := BuildArray(context.Background(), client, slice)
+	assert.NilError(t, err)
+	assert.Equal(t, meta.GetTypeName(), largeStringArrayTypeName)
+
+	resolved, err := ResolveArray(client.getMetaData(t, meta))
+	assert.NilError(t, err)
+	defer resolved.Release()
+	assert.Assert(t, array.ArrayEqual(slice, resolved))
+}
+
+func TestArrow_FixedSizeListArray(t *testing.T) {
+	client := newFakeIPCClient()
+	builder := array.NewFixedSizeListBuilder(memory.NewGoAllocator(), 2, arrow.PrimitiveTypes.Float64)
+	defer builder.Release()
+	values := builder.ValueBuilder().(*array.Float64Builder)
+	for index := 0; index < 3; index++ {
+		builder.Append(true)
+		values.AppendValues([]float64{float64(index), float64(index) + 0.5}, nil)
+	}
+	arr := builder.NewArray()
+	defer arr.Release()
+
+	var arrayBuilder ArrayBuilder
+	arrayBuilder.Init(client, arr)
+	assert.NilError(t, arrayBuilder.Seal(context.Background()))
+	assert.Assert(t, arrayBuilder.Seal(context.Background()) != nil)
+	assert.Equal(t, arrayBuilder.Meta().GetTypeName(), "vineyard::FixedSizeListArray")
+
+	resolved, err := ResolveArray(client.getMetaData(t, arrayBuilder.Meta()))
+	assert.NilError(t, err)
+	defer resolved.Release()
+	assert.Assert(t, array.ArrayEqual(arr, resolved))
+}
+
+func TestArrow_Schema(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int32},
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "payload", Type: arrow.BinaryTypes.Binary},
+	}, nil)
+	message, err := serializeSchema(schema)
+	assert.NilError(t, err)
+
+	// the schema message is the same as what pyarrow/C++ produces for large types
+	fb, err := flatbufferOfMessage(message)
+	assert.NilError(t, err)
+	root, err := fb.root()
+	assert.NilError(t, err)
+	header, _ := fb.reference(root, 2)
+	fields, _ := fb.reference(header, 1)
+	var types []byte
+	for index := 0; index < 3; index++ {
+		pos := fields + 4 + 4*index
+		field := pos + int(binary.LittleEndian.Uint32(fb.buf[pos:]))
+		typeType, ok := fb.field(field, 2)
+		assert.Assert(t, ok)
+		types = append(types, fb.buf[typeType])
+	}
+	assert.DeepEqual(t, types, []byte{2, fbTypeLargeUtf8, fbTypeLargeBinary})
+
+	resolved, err := deserializeSchema(message)
+	assert.NilError(t, err)
+	assert.Assert(t, resolved.Equal(schema))
+}
+
+func TestArrow_Table(t *testing.T) {
+	client := newFakeIPCClient()
+	pool := memory.NewGoAllocator()
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "a", Type: arrow.PrimitiveTypes.Int32},
+		{Name: "b", Type: arrow.BinaryTypes.String},
+	}, nil)
+	builder := array.NewRecordBuilder(pool, schema)
+	defer builder.Release()
+	var records []array.Record
+	for batch := 0; batch < 2; batch++ {
+		builder.Field(0).(*array.Int32Builder).AppendValues([]int32{int32(batch), 1, 2}, nil)
+		builder.Field(1).(*array.StringBuilder).AppendValues([]string{"x", "y", "z"}, nil)
+		records = append(records, builder.NewRecord())
+	}
+	table := array.NewTableFromRecords(schema, records)
+	defer table.Release()
+	for _, record := range records {
+		record.Release()
+	}
+
+	meta, err := BuildTable(context.Background(), client, table)
+	assert.NilError(t, err)
+	assert.Equal(t, meta.GetTypeName(), "vineyard::Table")
+
+	resolved, err := ResolveTable(client.getMetaData(t, meta))
+	assert.NilError(t, err)
+	defer resolved.Release()
+	assert.Equal(t, resolved.NumRows(), int64(6))
+	assert.Assert(t, resolved.Schema().Equal(schema))
+	for index := 0; index < int(resolved.NumCols()); index++ {
+		expected, actual := table.Column(index).Data().Chunks(), resolved.Column(index).Data().Chunks()
+		assert.Equal(t, len(actual), len(expected))
+		for chunk := range expected {
+			assert.Assert(t, array.ArrayEqual(expected[chunk], actual[chunk]))
+		}
+	}
+}
@@ -16,50 +16,219 @@ limitations under the License.
 package ds
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/apache/arrow/go/arrow/memory"
 	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
 )
 
 type Blob struct {
-	id     int
+	id     common.ObjectID
 	size   int
 	buffer []byte
+	meta   *ObjectMeta
+}
+
+// NewBlob creates a blob over the given buffer without copying, the buffer
+// is nil if the payload of blob is not locally available.
+func NewBlob(id common.ObjectID, size int, buffer []byte) *Blob {
+	return &Blob{id: id, size: size, buffer: buffer}
+}
+
+func (b *Blob) ID() common.ObjectID {
+	return b.id
 }
 
 func (b *Blob) Size() int {
 	return b.size
 }
 
+// Meta returns the metadata of the blob, which is nil if the blob is not
+// obtained via its metadata, e.g., by GetBlobs.
+func (b *Blob) Meta() *ObjectMeta {
+	return b.meta
+}
+
+func (b *Blob) NBytes() int {
+	return b.size
+}
+
+func (b *Blob) IsLocal() bool {
+	return b.meta == nil || b.meta.IsLocal()
+}
+
 func (b *Blob) Data() ([]byte, error) {
 	if b.size > 0 && len(b.buffer) == 0 {
 		return nil, fmt.Errorf("The object might be a (partially) remote object "+
-			"and the payload data is not locally available: %d", b.id)
+			"and the payload data is not locally available: %s", common.ObjectIDToString(b.id))
 	}
 	return b.buffer, nil
 }
 
+// Buffer wraps the payload of blob as an arrow buffer without copying.
+func (b *Blob) Buffer() (*memory.Buffer, error) {
+	data, err := b.Data()
+	if err != nil {
+		return nil, err
+	}
+	return memory.NewBufferBytes(data), nil
+}
+
+func resolveBlob(meta *ObjectMeta) (*Blob, error) {
+	if meta.GetTypeName() != "vineyard::Blob" {
+		return nil, fmt.Errorf("expect typename 'vineyard::Blob', but got '%s'", meta.GetTypeName())
+	}
+	size, err := GetKeyValue[int](meta, "length")
+	if err != nil {
+		return nil, err
+	}
+	blob := &Blob{id: meta.GetId(), size: size, meta: meta}
+	if buffer, err := meta.GetBuffer(meta.GetId()); err == nil {
+		blob.buffer = buffer.Bytes()
+	}
+	return blob, nil
+}
+
+// BufferSet records the blobs that an object (and its members) consists of,
+// the buffer of a blob is nil until it has been fetched from vineyard server.
 type BufferSet struct {
-	//buffer arrow.
+	buffers map[common.ObjectID]*memory.Buffer
 }
 
 func (b *BufferSet) EmplaceBuffer(id common.ObjectID) {
+	if b.buffers == nil {
+		b.buffers = make(map[common.ObjectID]*memory.Buffer)
+	}
+	if _, ok := b.buffers[id]; !ok {
+		b.buffers[id] = nil
+	}
+}
 
+func (b *BufferSet) SetBuffer(id common.ObjectID, buffer *memory.Buffer) error {
+	if _, ok := b.buffers[id]; !ok {
+		return fmt.Errorf("the blob %s is not in the buffer set", common.ObjectIDToString(id))
+	}
+	b.buffers[id] = buffer
+	return nil
 }
 
-func (b *BufferSet) Reset() {
+func (b *BufferSet) Contains(id common.ObjectID) bool {
+	_, ok := b.buffers[id]
+	return ok
+}
+
+func (b *BufferSet) Get(id common.ObjectID) (*memory.Buffer, bool) {
+	buffer, ok := b.buffers[id]
+	return buffer, ok
+}
+
+func (b *BufferSet) AllBufferIds() []common.ObjectID {
+	ids := make([]common.ObjectID, 0, len(b.buffers))
+	for id := range b.buffers {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (b *BufferSet) Extend(other *BufferSet) {
+	for id, buffer := range other.buffers {
+		b.EmplaceBuffer(id)
+		if buffer != nil {
+			b.buffers[id] = buffer
+		}
+	}
+}
 
+func (b *BufferSet) Reset() {
+	b.buffers = make(map[common.ObjectID]*memory.Buffer)
 }
 
+// BlobWriter is a blob that has been allocated in vineyard server but not
+// sealed yet, the payload is the shared memory of vineyard server and could
+// be filled either directly via Buf() or as an io.Writer.
 type BlobWriter struct {
 	ID common.ObjectID
 	Payload
-	memory.Buffer
+
+	client IIPCClient
+	buffer []byte
+	offset int
+	sealed bool
 }
 
-func (b *BlobWriter) Reset(id common.ObjectID, payload Payload, buffer memory.Buffer) {
+func (b *BlobWriter) Reset(client IIPCClient, id common.ObjectID, payload Payload, buffer []byte) {
 	b.ID = id
 	b.Payload = payload
-	b.Buffer = buffer
+	b.client = client
+	b.buffer = buffer
+	b.offset = 0
+	b.sealed = false
+}
+
+// Buf returns the writable shared memory of the blob.
+func (b *BlobWriter) Buf() []byte {
+	return b.buffer
+}
+
+// Buffer wraps the shared memory of the blob as an arrow buffer without copying.
+func (b *BlobWriter) Buffer() *memory.Buffer {
+	return memory.NewBufferBytes(b.buffer)
+}
+
+func (b *BlobWriter) Size() int {
+	return len(b.buffer)
+}
+
+func (b *BlobWriter) IsSealed() bool {
+	return b.sealed
+}
+
+// Write appends p to the content that has been written via Write, it
+// returns io.ErrShortWrite if the blob doesn't have enough space left.
+func (b *BlobWriter) Write(p []byte) (int, error) {
+	if b.sealed {
+		return 0, fmt.Errorf("the blob %s has already been sealed", common.ObjectIDToString(b.ID))
+	}
+	n := copy(b.buffer[b.offset:], p)
+	b.offset += n
+	if n < len(p) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+// Seal seals the blob in vineyard server, the blob becomes immutable and
+// visible to other clients afterwards.
+func (b *BlobWriter) Seal(ctx context.Context) (*Blob, error) {
+	if b.sealed {
+		return nil, fmt.Errorf("the blob %s has already been sealed", common.ObjectIDToString(b.ID))
+	}
+	if b.client == nil {
+		return nil, errors.New("the blob writer is not created by a vineyard client")
+	}
+	if err := b.client.Seal(ctx, b.ID); err != nil {
+		return nil, err
+	}
+	b.sealed = true
+	return NewBlob(b.ID, len(b.buffer), b.buffer), nil
+}
+
+// Abort drops the blob from vineyard server, the shared memory must not be
+// used afterwards.
+func (b *BlobWriter) Abort(ctx context.Context) error {
+	if b.sealed {
+		return fmt.Errorf("the blob %s has already been sealed", common.ObjectIDToString(b.ID))
+	}
+	if b.client == nil {
+		return errors.New("the blob writer is not created by a vineyard client")
+	}
+	if err := b.client.DropBuffer(ctx, b.ID); err != nil {
+		return err
+	}
+	b.buffer = nil
+	b.offset = 0
+	return nil
 }
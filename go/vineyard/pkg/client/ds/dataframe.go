@@ -0,0 +1,270 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+// columnName returns the name of column, non-string names (e.g., the integer
+// column names of pandas) are represented by their json encoding, which is
+// the same as the C++ DataFrame::AsBatch.
+func columnName(value interface{}) string {
+	if name, ok := value.(string); ok {
+		return name
+	}
+	return toJSON(value)
+}
+
+// DataFrame is a vineyard::DataFrame, which is the vineyard representation
+// of pandas.DataFrame, every column is a 1-dimensional tensor.
+type DataFrame struct {
+	ObjectBase
+
+	columns []string
+	values  []*Tensor
+	index   *Tensor
+
+	partitionIndexRow    int64
+	partitionIndexColumn int64
+	rowBatchIndex        int64
+}
+
+// ResolveDataFrame resolves the data frame from the metadata, the columns are
+// the shared memory of vineyard server and no copy happens.
+func ResolveDataFrame(meta *ObjectMeta) (*DataFrame, error) {
+	if meta.GetTypeName() != "vineyard::DataFrame" {
+		return nil, fmt.Errorf("expect typename 'vineyard::DataFrame', but got '%s'", meta.GetTypeName())
+	}
+	columns, err := GetKeyValue[[]interface{}](meta, "columns_")
+	if err != nil {
+		return nil, err
+	}
+	df := &DataFrame{ObjectBase: ObjectBase{meta: meta}, partitionIndexRow: -1, partitionIndexColumn: -1}
+	for index := range columns {
+		key, err := GetKeyValue[string](meta, "__values_-key-"+strconv.Itoa(index))
+		if err != nil {
+			return nil, err
+		}
+		var name interface{}
+		if err := json.Unmarshal([]byte(key), &name); err != nil {
+			return nil, fmt.Errorf("invalid name of column %d: %v", index, err)
+		}
+		member, err := meta.GetMember("__values_-value-" + strconv.Itoa(index))
+		if err != nil {
+			return nil, err
+		}
+		column, err := ResolveTensor(member)
+		if err != nil {
+			return nil, err
+		}
+		df.columns = append(df.columns, columnName(name))
+		df.values = append(df.values, column)
+	}
+	if meta.HasKey("index_") {
+		if df.index, err = resolveIndex(meta); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range map[string]*int64{
+		"partition_index_row_":    &df.partitionIndexRow,
+		"partition_index_column_": &df.partitionIndexColumn,
+		"row_batch_index_":        &df.rowBatchIndex,
+	} {
+		if meta.HasKey(key) {
+			if *value, err = GetKeyValue[int64](meta, key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return df, nil
+}
+
+// resolveIndex resolves the index, which is either a tensor or a
+// vineyard::Index (i.e., pandas.Index) of a tensor.
+func resolveIndex(meta *ObjectMeta) (*Tensor, error) {
+	index, err := meta.GetMember("index_")
+	if err != nil {
+		return nil, err
+	}
+	if index.GetTypeName() == "vineyard::Index" {
+		if index, err = index.GetMember("value_"); err != nil {
+			return nil, err
+		}
+	}
+	return ResolveTensor(index)
+}
+
+func (d *DataFrame) Columns() []string {
+	return d.columns
+}
+
+// Column returns the column with the given name, or nil if not exists.
+func (d *DataFrame) Column(name string) *Tensor {
+	for index, column := range d.columns {
+		if column == name {
+			return d.values[index]
+		}
+	}
+	return nil
+}
+
+func (d *DataFrame) ColumnAt(index int) *Tensor {
+	return d.values[index]
+}
+
+// Index returns the index of the data frame, or nil if it is a range index.
+func (d *DataFrame) Index() *Tensor {
+	return d.index
+}
+
+// Shape returns the number of rows and columns.
+func (d *DataFrame) Shape() (int64, int64) {
+	if len(d.values) == 0 || len(d.values[0].Shape()) == 0 {
+		return 0, int64(len(d.columns))
+	}
+	return d.values[0].Shape()[0], int64(len(d.columns))
+}
+
+// PartitionIndex returns the index of this partition (in rows and columns)
+// in the global data frame, -1 means the data frame is not partitioned.
+func (d *DataFrame) PartitionIndex() (int64, int64) {
+	return d.partitionIndexRow, d.partitionIndexColumn
+}
+
+func (d *DataFrame) RowBatchIndex() int64 {
+	return d.rowBatchIndex
+}
+
+// DataFrameBuilder builds a vineyard::DataFrame from tensors.
+type DataFrameBuilder struct {
+	ObjectBuilder
+
+	Client  IIPCClient
+	columns []string
+	values  []*ObjectMeta
+	index   *ObjectMeta
+	meta    *ObjectMeta
+
+	partitionIndexRow    int64
+	partitionIndexColumn int64
+	rowBatchIndex        int64
+}
+
+func (d *DataFrameBuilder) Init(client IIPCClient) {
+	d.Client = client
+	d.partitionIndexRow = -1
+	d.partitionIndexColumn = -1
+}
+
+// AddColumn adds a column, the column must be a 1-dimensional tensor and
+// has the same length with other columns.
+func (d *DataFrameBuilder) AddColumn(name string, column *ObjectMeta) error {
+	shape, err := GetKeyValue[[]int64](column, "shape_")
+	if err != nil {
+		return err
+	}
+	if len(shape) != 1 {
+		return fmt.Errorf("the column '%s' should be a 1-dimensional tensor, but got shape %v", name, shape)
+	}
+	if len(d.values) > 0 {
+		rows, _ := GetKeyValue[[]int64](d.values[0], "shape_")
+		if rows[0] != shape[0] {
+			return fmt.Errorf("the length of column '%s' is %d, but expect %d", name, shape[0], rows[0])
+		}
+	}
+	for _, column := range d.columns {
+		if column == name {
+			return fmt.Errorf("the column '%s' already exists", name)
+		}
+	}
+	d.columns = append(d.columns, name)
+	d.values = append(d.values, column)
+	return nil
+}
+
+// SetIndex sets the index of data frame, the index must be a tensor or a
+// vineyard::Index, a range index is used if not set.
+func (d *DataFrameBuilder) SetIndex(index *ObjectMeta) {
+	d.index = index
+}
+
+func (d *DataFrameBuilder) SetPartitionIndex(row, column int64) {
+	d.partitionIndexRow = row
+	d.partitionIndexColumn = column
+}
+
+func (d *DataFrameBuilder) SetRowBatchIndex(index int64) {
+	d.rowBatchIndex = index
+}
+
+func (d *DataFrameBuilder) Seal(ctx context.Context) error {
+	if d.sealed {
+		return fmt.Errorf("the builder has already been sealed")
+	}
+	if err := d.Build(ctx); err != nil {
+		return err
+	}
+	d.SetSeal(true)
+	return nil
+}
+
+func (d *DataFrameBuilder) Build(ctx context.Context) error {
+	var meta ObjectMeta
+	meta.Init()
+	meta.SetTypeName("vineyard::DataFrame")
+	meta.AddKeyValue("columns_", toJSON(d.columns))
+	if d.index != nil {
+		if err := meta.AddMember("index_", d.index); err != nil {
+			return err
+		}
+	}
+	nbytes := 0
+	for index, column := range d.values {
+		meta.AddKeyValue("__values_-key-"+strconv.Itoa(index), toJSON(d.columns[index]))
+		if err := meta.AddMember("__values_-value-"+strconv.Itoa(index), column); err != nil {
+			return err
+		}
+		nbytes += column.GetNBytes()
+	}
+	meta.AddKeyValue("__values_-size", len(d.values))
+	meta.AddKeyValue("partition_index_row_", d.partitionIndexRow)
+	meta.AddKeyValue("partition_index_column_", d.partitionIndexColumn)
+	meta.AddKeyValue("row_batch_index_", d.rowBatchIndex)
+	meta.SetNBytes(nbytes)
+	var id common.ObjectID
+	if err := d.Client.CreateMetaData(ctx, &meta, &id); err != nil {
+		return err
+	}
+	d.meta = &meta
+	return nil
+}
+
+func (d *DataFrameBuilder) Id() common.ObjectID {
+	if d.meta == nil {
+		return common.InvalidObjectID()
+	}
+	return d.meta.GetId()
+}
+
+func (d *DataFrameBuilder) Meta() *ObjectMeta {
+	return d.meta
+}
@@ -0,0 +1,63 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDataFrame_BuildAndResolve(t *testing.T) {
+	client := newFakeIPCClient()
+	a, err := BuildTensor(context.Background(), client, []int64{1, 2, 3}, []int64{3})
+	assert.NilError(t, err)
+	b, err := BuildTensor(context.Background(), client, []float32{0.5, 1.5, 2.5}, []int64{3})
+	assert.NilError(t, err)
+	index, err := BuildTensor(context.Background(), client, []int64{10, 20, 30}, []int64{3})
+	assert.NilError(t, err)
+	short, err := BuildTensor(context.Background(), client, []int64{1}, []int64{1})
+	assert.NilError(t, err)
+
+	var builder DataFrameBuilder
+	builder.Init(client)
+	assert.NilError(t, builder.AddColumn("a", a))
+	assert.NilError(t, builder.AddColumn("b", b))
+	assert.Assert(t, builder.AddColumn("a", a) != nil)
+	assert.Assert(t, builder.AddColumn("c", short) != nil)
+	builder.SetIndex(index)
+	builder.SetPartitionIndex(1, 0)
+	assert.NilError(t, builder.Seal(context.Background()))
+	assert.Equal(t, builder.Meta().GetNBytes(), 36)
+
+	df, err := ResolveDataFrame(client.getMetaData(t, builder.Meta()))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, df.Columns(), []string{"a", "b"})
+	rows, columns := df.Shape()
+	assert.Equal(t, rows, int64(3))
+	assert.Equal(t, columns, int64(2))
+	row, column := df.PartitionIndex()
+	assert.Equal(t, row, int64(1))
+	assert.Equal(t, column, int64(0))
+	values, err := TensorValues[float32](df.Column("b"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, values, []float32{0.5, 1.5, 2.5})
+	assert.Assert(t, df.Column("c") == nil)
+	indexValues, err := TensorValues[int64](df.Index())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, indexValues, []int64{10, 20, 30})
+}
@@ -0,0 +1,173 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+const objectSetTypeName = "vineyard::ObjectSet"
+
+// GlobalObject is a global object whose members are persisted objects on
+// (possibly) several vineyard instances, i.e., a vineyard::ObjectSet, or a
+// collection like vineyard::GlobalTensor and vineyard::GlobalDataFrame whose
+// members are the partitions.
+type GlobalObject struct {
+	ObjectBase
+
+	members []*ObjectMeta
+}
+
+func ResolveGlobalObject(meta *ObjectMeta) (*GlobalObject, error) {
+	if !meta.IsGlobal() {
+		return nil, fmt.Errorf("the object %s of typename '%s' is not global",
+			common.ObjectIDToString(meta.GetId()), meta.GetTypeName())
+	}
+	prefix, sizeKey := globalMemberKeys(meta.GetTypeName())
+	size, err := GetKeyValue[int](meta, sizeKey)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]*ObjectMeta, 0, size)
+	for index := 0; index < size; index++ {
+		member, err := meta.GetMember(prefix + strconv.Itoa(index))
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return &GlobalObject{ObjectBase: ObjectBase{meta: meta}, members: members}, nil
+}
+
+// globalMemberKeys returns the prefix of member names and the key of the
+// number of members, which differ between vineyard::ObjectSet and collections.
+func globalMemberKeys(typeName string) (string, string) {
+	if typeName == objectSetTypeName {
+		return "object_", "num_of_objects"
+	}
+	return "partitions_-", "partitions_-size"
+}
+
+func (g *GlobalObject) Size() int {
+	return len(g.members)
+}
+
+// Members returns the metadata of members, which could be resolved by
+// ResolveObject if the member is local.
+func (g *GlobalObject) Members() []*ObjectMeta {
+	return g.members
+}
+
+// LocalMembers returns the metadata of members that live in the vineyard
+// instance the client connects to.
+func (g *GlobalObject) LocalMembers() []*ObjectMeta {
+	var members []*ObjectMeta
+	for _, member := range g.members {
+		if member.IsLocal() {
+			members = append(members, member)
+		}
+	}
+	return members
+}
+
+// GlobalObjectBuilder builds a global object out of persisted objects on
+// several vineyard instances, the global object is persisted once sealed.
+type GlobalObjectBuilder struct {
+	ObjectBuilder
+
+	Client   IIPCClient
+	typeName string
+	members  []*ObjectMeta
+	meta     *ObjectMeta
+}
+
+// Init initializes the builder of a global object of the typename, which is
+// vineyard::ObjectSet if empty.
+func (g *GlobalObjectBuilder) Init(client IIPCClient, typeName string) {
+	g.Client = client
+	g.typeName = typeName
+	if g.typeName == "" {
+		g.typeName = objectSetTypeName
+	}
+}
+
+// AddMember adds a persisted object as the member, the metadata should be
+// fetched with syncRemote if the object lives in another instance.
+func (g *GlobalObjectBuilder) AddMember(member *ObjectMeta) {
+	g.members = append(g.members, member)
+}
+
+func (g *GlobalObjectBuilder) Size() int {
+	return len(g.members)
+}
+
+func (g *GlobalObjectBuilder) Seal(ctx context.Context) error {
+	if g.sealed {
+		return fmt.Errorf("the builder has already been sealed")
+	}
+	if err := g.Build(ctx); err != nil {
+		return err
+	}
+	g.SetSeal(true)
+	return nil
+}
+
+func (g *GlobalObjectBuilder) Build(ctx context.Context) error {
+	var meta ObjectMeta
+	meta.Init()
+	meta.SetTypeName(g.typeName)
+	meta.SetGlobal(true)
+	prefix, sizeKey := globalMemberKeys(g.typeName)
+	instances := make(map[common.InstanceID]bool)
+	nbytes := 0
+	for index, member := range g.members {
+		if err := meta.AddMember(prefix+strconv.Itoa(index), member); err != nil {
+			return err
+		}
+		instances[member.GetInstanceId()] = true
+		nbytes += member.GetNBytes()
+	}
+	meta.AddKeyValue(sizeKey, len(g.members))
+	if g.typeName == objectSetTypeName {
+		meta.AddKeyValue("num_of_instances", len(instances))
+	}
+	meta.SetNBytes(nbytes)
+	var id common.ObjectID
+	if err := g.Client.CreateMetaData(ctx, &meta, &id); err != nil {
+		return err
+	}
+	// global objects are visible to other instances only after persisted
+	if err := g.Client.Persist(ctx, id); err != nil {
+		return err
+	}
+	g.meta = &meta
+	return nil
+}
+
+func (g *GlobalObjectBuilder) Id() common.ObjectID {
+	if g.meta == nil {
+		return common.InvalidObjectID()
+	}
+	return g.meta.GetId()
+}
+
+func (g *GlobalObjectBuilder) Meta() *ObjectMeta {
+	return g.meta
+}
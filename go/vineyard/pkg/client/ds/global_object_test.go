@@ -0,0 +1,58 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestGlobalObject_BuildAndResolve(t *testing.T) {
+	client := newFakeIPCClient()
+	local, err := BuildTensor(context.Background(), client, []int64{1, 2}, []int64{2})
+	assert.NilError(t, err)
+	remote, err := BuildTensor(context.Background(), client, []int64{3}, []int64{1})
+	assert.NilError(t, err)
+	remote.SetInstanceId(2)
+
+	for _, typeName := range []string{"", "vineyard::GlobalTensor"} {
+		var builder GlobalObjectBuilder
+		builder.Init(client, typeName)
+		builder.AddMember(local)
+		builder.AddMember(remote)
+		assert.NilError(t, builder.Seal(context.Background()))
+		assert.Assert(t, client.persisted[builder.Id()])
+		assert.Equal(t, builder.Meta().GetNBytes(), 24)
+
+		object, err := ResolveObject(client.getMetaData(t, builder.Meta()))
+		assert.NilError(t, err)
+		global, ok := object.(*GlobalObject)
+		assert.Assert(t, ok, "expect a global object, but got %T", object)
+		assert.Equal(t, global.Size(), 2)
+		assert.Equal(t, global.Members()[1].GetId(), remote.GetId())
+		locals := global.LocalMembers()
+		assert.Equal(t, len(locals), 1)
+		tensor, err := ResolveTensor(locals[0])
+		assert.NilError(t, err)
+		values, err := TensorValues[int64](tensor)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, values, []int64{1, 2})
+	}
+	_, err = ResolveGlobalObject(local)
+	assert.ErrorContains(t, err, "is not global")
+}
@@ -0,0 +1,353 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"unsafe"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+// HashMapKey is the key types of vineyard::Hashmap<K, V> that supported in Go.
+type HashMapKey interface {
+	int32 | int64 | uint32 | uint64
+}
+
+// HashMapValue is the value types of vineyard::Hashmap<K, V> that supported
+// in Go.
+type HashMapValue interface {
+	int32 | int64 | uint32 | uint64 | float32 | float64
+}
+
+// The vineyard::Hashmap<K, V> is a ska::flat_hash_map, i.e., an open
+// addressing hash table with robin hood hashing, whose entries are stored in
+// a vineyard::Array. The hash of keys is wy::hash<K>, and the index of keys
+// is the hash modulo the number of slots, which is a prime number.
+const (
+	wyp0 = 0xa0761d6478bd642f
+	wyp1 = 0xe7037ed1a0b428db
+
+	hashMapMinLookups = 4
+	// the entry at the end of table has the distance 0 to stop iterating
+	hashMapSpecialEndValue = 0
+)
+
+// the prime numbers of ska::prime_number_hash_policy, larger ones are
+// omitted as such tables don't fit in memory
+var hashMapPrimes = []uint64{
+	2, 3, 5, 7, 11, 13, 17, 23, 29, 37, 47, 59, 73, 97, 127, 151, 197, 251, 313, 397,
+	499, 631, 797, 1009, 1259, 1597, 2011, 2539, 3203, 4027, 5087, 6421, 8089, 10193,
+	12853, 16193, 20399, 25717, 32401, 40823, 51437, 64811, 81649, 102877, 129607,
+	163307, 205759, 259229, 326617, 411527, 518509, 653267, 823117, 1037059, 1306601,
+	1646237, 2074129, 2613229, 3292489, 4148279, 5226491, 6584983, 8296553, 10453007,
+	13169977, 16593127, 20906033, 26339969, 33186281, 41812097, 52679969, 66372617,
+	83624237, 105359939, 132745199, 167248483, 210719881, 265490441, 334496971,
+	421439783, 530980861, 668993977, 842879579, 1061961721, 1337987929, 1685759167,
+	2123923447, 2675975881, 3371518343, 4247846927, 5351951779, 6743036717,
+	8495693897, 10703903591, 13486073473, 16991387857, 21407807219, 26972146961,
+	33982775741, 42815614441, 53944293929, 67965551447, 85631228929, 107888587883,
+}
+
+func wymix(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return lo ^ hi
+}
+
+// wyhash returns the same hash as wy::hash<K> in C++, which hashes 64-bit
+// integers by wyhash64 and other types by their bytes.
+func wyhash[K HashMapKey](key K) uint64 {
+	if unsafe.Sizeof(key) == 8 {
+		hi, lo := bits.Mul64(*(*uint64)(unsafe.Pointer(&key))^wyp0, wyp0^wyp1)
+		return wymix(lo^wyp0, hi^wyp1)
+	}
+	value := uint64(*(*uint32)(unsafe.Pointer(&key)))
+	value = value<<32 | value
+	return wymix(wyp1^4, wymix(value^wyp1, value^wyp0))
+}
+
+func hashMapTypeNames[K HashMapKey, V HashMapValue]() (string, string) {
+	key, _ := cppTypeName[K]()
+	value, _ := cppTypeName[V]()
+	return fmt.Sprintf("vineyard::Hashmap<%s,%s,wy::hash<%s>,std::equal_to<%s>>", key, value, key, key),
+		fmt.Sprintf("vineyard::Array<ska::detailv3::sherwood_v3_entry<std::pair<%s,%s>>>", key, value)
+}
+
+// hashMapLayout is the memory layout of ska::detailv3::sherwood_v3_entry<
+// std::pair<K, V>>, i.e., an int8 distance followed by the aligned pair.
+type hashMapLayout struct {
+	entrySize   int
+	keyOffset   int
+	valueOffset int
+}
+
+func layoutOf[K HashMapKey, V HashMapValue]() hashMapLayout {
+	var key K
+	var value V
+	keySize, valueSize := int(unsafe.Sizeof(key)), int(unsafe.Sizeof(value))
+	align := keySize
+	if valueSize > align {
+		align = valueSize
+	}
+	valueOffset := (keySize + valueSize - 1) / valueSize * valueSize
+	pairSize := (valueOffset + valueSize + align - 1) / align * align
+	return hashMapLayout{entrySize: align + pairSize, keyOffset: align, valueOffset: align + valueOffset}
+}
+
+// HashMap is a vineyard::Hashmap<K, V> with integer keys, lookups happen on
+// the shared memory of vineyard server directly.
+type HashMap[K HashMapKey, V HashMapValue] struct {
+	ObjectBase
+
+	numSlotsMinusOne uint64
+	maxLookups       int
+	numElements      int
+	entries          []byte
+	layout           hashMapLayout
+}
+
+func ResolveHashMap[K HashMapKey, V HashMapValue](meta *ObjectMeta) (*HashMap[K, V], error) {
+	typeName, _ := hashMapTypeNames[K, V]()
+	if meta.GetTypeName() != typeName {
+		return nil, fmt.Errorf("expect typename '%s', but got '%s'", typeName, meta.GetTypeName())
+	}
+	h := &HashMap[K, V]{ObjectBase: ObjectBase{meta: meta}, layout: layoutOf[K, V]()}
+	var err error
+	if h.numSlotsMinusOne, err = GetKeyValue[uint64](meta, "num_slots_minus_one_"); err != nil {
+		return nil, err
+	}
+	if h.maxLookups, err = GetKeyValue[int](meta, "max_lookups_"); err != nil {
+		return nil, err
+	}
+	if h.numElements, err = GetKeyValue[int](meta, "num_elements_"); err != nil {
+		return nil, err
+	}
+	entries, err := meta.GetMember("entries_")
+	if err != nil {
+		return nil, err
+	}
+	size, err := GetKeyValue[int](entries, "size_")
+	if err != nil {
+		return nil, err
+	}
+	buffer, err := memberBuffer(entries, "buffer_")
+	if err != nil {
+		return nil, err
+	}
+	if size != int(h.numSlotsMinusOne)+h.maxLookups+1 || buffer.Len() < size*h.layout.entrySize {
+		return nil, fmt.Errorf("invalid entries of hashmap: %d entries in %d bytes", size, buffer.Len())
+	}
+	h.entries = buffer.Bytes()[:size*h.layout.entrySize]
+	return h, nil
+}
+
+func (h *HashMap[K, V]) Len() int {
+	return h.numElements
+}
+
+func (h *HashMap[K, V]) entry(index int) (int8, K, V) {
+	entry := h.entries[index*h.layout.entrySize:]
+	return int8(entry[0]), *(*K)(unsafe.Pointer(&entry[h.layout.keyOffset])),
+		*(*V)(unsafe.Pointer(&entry[h.layout.valueOffset]))
+}
+
+func (h *HashMap[K, V]) Get(key K) (V, bool) {
+	index := int(wyhash(key) % (h.numSlotsMinusOne + 1))
+	for distance := int8(0); index < len(h.entries)/h.layout.entrySize; distance, index = distance+1, index+1 {
+		entryDistance, entryKey, entryValue := h.entry(index)
+		if entryDistance < distance {
+			break
+		}
+		if entryKey == key {
+			return entryValue, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// Range calls fn for each key and value in the map until fn returns false.
+func (h *HashMap[K, V]) Range(fn func(key K, value V) bool) {
+	// the last entry is the special end
+	for index := 0; index < len(h.entries)/h.layout.entrySize-1; index++ {
+		distance, key, value := h.entry(index)
+		if distance >= 0 && !fn(key, value) {
+			return
+		}
+	}
+}
+
+// HashMapBuilder builds a vineyard::Hashmap<K, V>, which could be used by
+// the Hashmap in C++ as well.
+type HashMapBuilder[K HashMapKey, V HashMapValue] struct {
+	ObjectBuilder
+
+	Client IIPCClient
+	values map[K]V
+	meta   *ObjectMeta
+}
+
+func (h *HashMapBuilder[K, V]) Init(client IIPCClient) {
+	h.Client = client
+	h.values = make(map[K]V)
+}
+
+func (h *HashMapBuilder[K, V]) Put(key K, value V) {
+	h.values[key] = value
+}
+
+func (h *HashMapBuilder[K, V]) Len() int {
+	return len(h.values)
+}
+
+func (h *HashMapBuilder[K, V]) Seal(ctx context.Context) error {
+	if h.sealed {
+		return fmt.Errorf("the builder has already been sealed")
+	}
+	if err := h.Build(ctx); err != nil {
+		return err
+	}
+	h.SetSeal(true)
+	return nil
+}
+
+// buildEntries lays out the entries in the same way as ska::flat_hash_map
+// after shrink_to_fit(), the number of slots grows when any key cannot be
+// placed within max_lookups.
+func (h *HashMapBuilder[K, V]) buildEntries() (uint64, int, []byte) {
+	layout := layoutOf[K, V]()
+	if len(h.values) == 0 {
+		// the empty default table of ska::flat_hash_map
+		entries := make([]byte, hashMapMinLookups*layout.entrySize)
+		for index := 0; index < hashMapMinLookups-1; index++ {
+			entries[index*layout.entrySize] = 0xff
+		}
+		return 0, hashMapMinLookups - 1, entries
+	}
+	numSlots := uint64(2 * len(h.values))
+	for {
+		for _, prime := range hashMapPrimes {
+			if prime >= numSlots {
+				numSlots = prime
+				break
+			}
+		}
+		maxLookups := bits.Len64(numSlots) - 1
+		if maxLookups < hashMapMinLookups {
+			maxLookups = hashMapMinLookups
+		}
+		if entries, ok := h.place(numSlots, maxLookups, layout); ok {
+			return numSlots - 1, maxLookups, entries
+		}
+		numSlots *= 2
+	}
+}
+
+func (h *HashMapBuilder[K, V]) place(numSlots uint64, maxLookups int, layout hashMapLayout) ([]byte, bool) {
+	type entry struct {
+		distance int
+		key      K
+		value    V
+	}
+	table := make([]entry, int(numSlots)+maxLookups)
+	for index := range table {
+		table[index].distance = -1
+	}
+	// the special end
+	table[len(table)-1].distance = hashMapSpecialEndValue
+	for key, value := range h.values {
+		current := entry{distance: 0, key: key, value: value}
+		index := int(wyhash(key) % numSlots)
+		for {
+			if current.distance == maxLookups || index == len(table)-1 {
+				return nil, false
+			}
+			if table[index].distance < 0 {
+				table[index] = current
+				break
+			}
+			if table[index].distance < current.distance {
+				current, table[index] = table[index], current
+			}
+			current.distance++
+			index++
+		}
+	}
+	entries := make([]byte, len(table)*layout.entrySize)
+	for index, e := range table {
+		buffer := entries[index*layout.entrySize:]
+		buffer[0] = byte(int8(e.distance))
+		*(*K)(unsafe.Pointer(&buffer[layout.keyOffset])) = e.key
+		*(*V)(unsafe.Pointer(&buffer[layout.valueOffset])) = e.value
+	}
+	return entries, true
+}
+
+func (h *HashMapBuilder[K, V]) Build(ctx context.Context) error {
+	numSlotsMinusOne, maxLookups, entries := h.buildEntries()
+	typeName, entriesTypeName := hashMapTypeNames[K, V]()
+
+	bufferMeta, err := buildBuffer(ctx, h.Client, entries)
+	if err != nil {
+		return err
+	}
+	var entriesMeta ObjectMeta
+	entriesMeta.Init()
+	entriesMeta.SetTypeName(entriesTypeName)
+	entriesMeta.AddKeyValue("size_", len(entries)/layoutOf[K, V]().entrySize)
+	if err := entriesMeta.AddMember("buffer_", bufferMeta); err != nil {
+		return err
+	}
+	entriesMeta.SetNBytes(len(entries))
+	var id common.ObjectID
+	if err := h.Client.CreateMetaData(ctx, &entriesMeta, &id); err != nil {
+		return err
+	}
+
+	var meta ObjectMeta
+	meta.Init()
+	meta.SetTypeName(typeName)
+	meta.AddKeyValue("num_slots_minus_one_", numSlotsMinusOne)
+	meta.AddKeyValue("max_lookups_", maxLookups)
+	meta.AddKeyValue("num_elements_", len(h.values))
+	if err := meta.AddMember("entries_", &entriesMeta); err != nil {
+		return err
+	}
+	// the data buffer is only used by hashmaps of string views
+	meta.AddKeyValue("data_buffer_", 0)
+	if err := meta.AddMember("data_buffer_mapped_", newBlobMeta(h.Client, common.EmptyBlobID(), nil)); err != nil {
+		return err
+	}
+	meta.SetNBytes(len(entries))
+	if err := h.Client.CreateMetaData(ctx, &meta, &id); err != nil {
+		return err
+	}
+	h.meta = &meta
+	return nil
+}
+
+func (h *HashMapBuilder[K, V]) Id() common.ObjectID {
+	if h.meta == nil {
+		return common.InvalidObjectID()
+	}
+	return h.meta.GetId()
+}
+
+func (h *HashMapBuilder[K, V]) Meta() *ObjectMeta {
+	return h.meta
+}
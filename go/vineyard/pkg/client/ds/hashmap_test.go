@@ -0,0 +1,121 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+	"gotest.tools/v3/assert"
+)
+
+func TestHashMap_Hash(t *testing.T) {
+	// the results of wy::hash<T> in C++
+	assert.Equal(t, wyhash(int64(0)), uint64(584455911125106724))
+	assert.Equal(t, wyhash(int64(42)), uint64(4130620294406806347))
+	assert.Equal(t, wyhash(int64(-7)), uint64(16288156761208931361))
+	assert.Equal(t, wyhash(uint64(18446744073709551615)), uint64(801071983235799382))
+	assert.Equal(t, wyhash(int32(0)), uint64(14021260516210555171))
+	assert.Equal(t, wyhash(int32(42)), uint64(11078366583945735588))
+	assert.Equal(t, wyhash(int32(-7)), uint64(16446981658642591100))
+}
+
+func TestHashMap_BuildAndResolve(t *testing.T) {
+	client := newFakeIPCClient()
+	var builder HashMapBuilder[int32, float64]
+	builder.Init(client)
+	for key := int32(0); key < 1000; key++ {
+		builder.Put(key*7, float64(key)/2)
+	}
+	assert.NilError(t, builder.Seal(context.Background()))
+	assert.Equal(t, builder.Meta().GetTypeName(),
+		"vineyard::Hashmap<int,double,wy::hash<int>,std::equal_to<int>>")
+
+	hashmap, err := ResolveHashMap[int32, float64](client.getMetaData(t, builder.Meta()))
+	assert.NilError(t, err)
+	assert.Equal(t, hashmap.Len(), 1000)
+	for key := int32(0); key < 1000; key++ {
+		value, ok := hashmap.Get(key * 7)
+		assert.Assert(t, ok, key)
+		assert.Equal(t, value, float64(key)/2)
+	}
+	_, ok := hashmap.Get(1)
+	assert.Assert(t, !ok)
+	count := 0
+	hashmap.Range(func(key int32, value float64) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, count, 1000)
+
+	_, err = ResolveHashMap[int64, float64](client.getMetaData(t, builder.Meta()))
+	assert.Assert(t, err != nil)
+
+	var empty HashMapBuilder[uint64, uint64]
+	empty.Init(client)
+	assert.NilError(t, empty.Seal(context.Background()))
+	emptyMap, err := ResolveHashMap[uint64, uint64](client.getMetaData(t, empty.Meta()))
+	assert.NilError(t, err)
+	_, ok = emptyMap.Get(0)
+	assert.Assert(t, !ok)
+}
+
+// the entries of ska::flat_hash_map<int64_t, double> {10: 1.5, 20: 2.5, ...,
+// 50: 5.5} after shrink_to_fit()
+const cppHashMapEntries = "ff0000000000000000000000000000000000000000000000ff0000000000000000000000000000000000000000000000" +
+	"000000000000000032000000000000000000000000001640ff0000000000000000000000000000000000000000000000" +
+	"ff0000000000000000000000000000000000000000000000ff0000000000000000000000000000000000000000000000" +
+	"00000000000000000a00000000000000000000000000f83f01000000000000001e000000000000000000000000000c40" +
+	"000000000000000028000000000000000000000000001240000000000000000014000000000000000000000000000440" +
+	"ff0000000000000000000000000000000000000000000000ff0000000000000000000000000000000000000000000000" +
+	"ff0000000000000000000000000000000000000000000000ff0000000000000000000000000000000000000000000000" +
+	"000000000000000000000000000000000000000000000000"
+
+func TestHashMap_ResolveCpp(t *testing.T) {
+	entries, err := hex.DecodeString(cppHashMapEntries)
+	assert.NilError(t, err)
+	var tree map[string]interface{}
+	assert.NilError(t, common.DecodeMsg(`{
+		"id": "o0000000000000010",
+		"typename": "vineyard::Hashmap<int64,double,wy::hash<int64>,std::equal_to<int64>>",
+		"num_slots_minus_one_": 10,
+		"max_lookups_": 4,
+		"num_elements_": 5,
+		"data_buffer_": 0,
+		"entries_": {
+			"id": "o0000000000000011",
+			"typename": "vineyard::Array<ska::detailv3::sherwood_v3_entry<std::pair<int64,double>>>",
+			"size_": 15,
+			"buffer_": {"id": "o8000000000000012", "typename": "vineyard::Blob", "instance_id": 1}
+		}
+	}`, &tree))
+	var meta ObjectMeta
+	assert.NilError(t, meta.SetMetaData(&fakeClient{instanceID: 1}, tree))
+	assert.NilError(t, meta.GetBufferSet().SetBuffer(0x8000000000000012, memory.NewBufferBytes(entries)))
+
+	hashmap, err := ResolveHashMap[int64, float64](&meta)
+	assert.NilError(t, err)
+	for key := int64(1); key <= 5; key++ {
+		value, ok := hashmap.Get(key * 10)
+		assert.Assert(t, ok, key)
+		assert.Equal(t, value, float64(key)+0.5)
+	}
+	_, ok := hashmap.Get(60)
+	assert.Assert(t, !ok)
+}
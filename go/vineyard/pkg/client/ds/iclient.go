@@ -1,5 +1,20 @@
 package ds
 
+import (
+	"context"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+type IClient interface {
+	InstanceID() common.InstanceID
+}
+
 type IIPCClient interface {
-	CreateBlob(size int, blob *BlobWriter)
+	IClient
+	CreateBlob(ctx context.Context, size int, blob *BlobWriter) error
+	DropBuffer(ctx context.Context, id common.ObjectID) error
+	CreateMetaData(ctx context.Context, meta *ObjectMeta, id *common.ObjectID) error
+	Seal(ctx context.Context, id common.ObjectID) error
+	Persist(ctx context.Context, id common.ObjectID) error
 }
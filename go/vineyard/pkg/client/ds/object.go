@@ -0,0 +1,148 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+// Object is a vineyard object that has been resolved from its metadata.
+type Object interface {
+	ID() common.ObjectID
+	Meta() *ObjectMeta
+	NBytes() int
+	IsLocal() bool
+}
+
+// ObjectBase implements the Object interface on the metadata, and is expected
+// to be embedded in the data structures.
+type ObjectBase struct {
+	meta *ObjectMeta
+}
+
+// NewObjectBase returns the ObjectBase of the metadata, which is used by the
+// resolvers registered outside this package to construct their objects.
+func NewObjectBase(meta *ObjectMeta) ObjectBase {
+	return ObjectBase{meta: meta}
+}
+
+func (o *ObjectBase) ID() common.ObjectID {
+	return o.meta.GetId()
+}
+
+func (o *ObjectBase) Meta() *ObjectMeta {
+	return o.meta
+}
+
+func (o *ObjectBase) NBytes() int {
+	return o.meta.GetNBytes()
+}
+
+func (o *ObjectBase) IsLocal() bool {
+	return o.meta.IsLocal()
+}
+
+// ObjectResolver constructs the object from its metadata, the buffers of the
+// metadata have been fetched if the object is local.
+type ObjectResolver func(meta *ObjectMeta) (Object, error)
+
+type registeredResolver struct {
+	pattern  *regexp.Regexp
+	resolver ObjectResolver
+}
+
+var (
+	resolversMutex sync.RWMutex
+	resolvers      []registeredResolver
+)
+
+// RegisterResolver registers the resolver for typenames that match the
+// pattern, e.g., "vineyard::Tensor<.*>". The pattern must match the whole
+// typename, and the latest registered one wins if multiple patterns match,
+// so the builtin resolvers could be overridden.
+func RegisterResolver(pattern string, resolver ObjectResolver) {
+	compiled := regexp.MustCompile("^(?:" + pattern + ")$")
+	resolversMutex.Lock()
+	defer resolversMutex.Unlock()
+	resolvers = append(resolvers, registeredResolver{pattern: compiled, resolver: resolver})
+}
+
+func lookupResolver(typeName string) ObjectResolver {
+	resolversMutex.RLock()
+	defer resolversMutex.RUnlock()
+	for index := len(resolvers) - 1; index >= 0; index-- {
+		if resolvers[index].pattern.MatchString(typeName) {
+			return resolvers[index].resolver
+		}
+	}
+	return nil
+}
+
+// ResolveObject resolves the object by the resolver registered for its
+// typename.
+func ResolveObject(meta *ObjectMeta) (Object, error) {
+	resolver := lookupResolver(meta.GetTypeName())
+	if resolver == nil {
+		return nil, fmt.Errorf("no resolver is registered for typename '%s'", meta.GetTypeName())
+	}
+	return resolver(meta)
+}
+
+// registerResolver registers a builtin resolver that returns a concrete type.
+func registerResolver[T Object](pattern string, resolve func(meta *ObjectMeta) (T, error)) {
+	RegisterResolver(pattern, func(meta *ObjectMeta) (Object, error) {
+		object, err := resolve(meta)
+		if err != nil {
+			return nil, err
+		}
+		return object, nil
+	})
+}
+
+func registerHashMap[K HashMapKey, V HashMapValue]() {
+	typeName, _ := hashMapTypeNames[K, V]()
+	registerResolver(regexp.QuoteMeta(typeName), ResolveHashMap[K, V])
+}
+
+func registerHashMaps[K HashMapKey]() {
+	registerHashMap[K, int32]()
+	registerHashMap[K, int64]()
+	registerHashMap[K, uint32]()
+	registerHashMap[K, uint64]()
+	registerHashMap[K, float32]()
+	registerHashMap[K, float64]()
+}
+
+func init() {
+	registerResolver("vineyard::Blob", resolveBlob)
+	registerResolver(`vineyard::(NumericArray<.*>|BooleanArray|FixedSizeBinaryArray|`+
+		`BaseBinaryArray<.*>|FixedSizeListArray|NullArray)`, ResolveArrowArray)
+	registerResolver("vineyard::RecordBatch", ResolveArrowRecordBatch)
+	registerResolver("vineyard::Table", ResolveArrowTable)
+	registerResolver("vineyard::Tensor<.*>", ResolveTensor)
+	registerResolver("vineyard::DataFrame", ResolveDataFrame)
+	registerResolver("vineyard::Scalar<.*>", ResolveScalar)
+	registerResolver("vineyard::Sequence", ResolveSequence)
+	registerResolver("vineyard::(ObjectSet|GlobalTensor|GlobalDataFrame)", ResolveGlobalObject)
+	registerHashMaps[int32]()
+	registerHashMaps[int64]()
+	registerHashMaps[uint32]()
+	registerHashMaps[uint64]()
+}
@@ -16,13 +16,20 @@ limitations under the License.
 package ds
 
 import (
-	"strconv"
+	"encoding/json"
+	"fmt"
+	"sort"
 
+	"github.com/apache/arrow/go/arrow/memory"
 	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
 )
 
+// labelsKey is the key of labels in the metadata, whose value is a json
+// object of the labels.
+const labelsKey = "__labels"
+
 type ObjectMeta struct {
-	client     *IIPCClient
+	client     IClient
 	meta       map[string]interface{}
 	bufferSet  BufferSet
 	inComplete bool
@@ -32,11 +39,11 @@ func (o *ObjectMeta) Init() {
 	o.meta = make(map[string]interface{})
 }
 
-func (o *ObjectMeta) SetClient(client *IIPCClient) {
+func (o *ObjectMeta) SetClient(client IClient) {
 	o.client = client
 }
 
-func (o *ObjectMeta) GetClient() *IIPCClient {
+func (o *ObjectMeta) GetClient() IClient {
 	return o.client
 }
 
@@ -44,6 +51,26 @@ func (o *ObjectMeta) SetInstanceId(id common.InstanceID) {
 	o.meta["instance_id"] = id
 }
 
+func (o *ObjectMeta) GetInstanceId() common.InstanceID {
+	instanceID, err := GetKeyValue[common.InstanceID](o, "instance_id")
+	if err != nil {
+		return common.UnspecifiedInstanceID()
+	}
+	return instanceID
+}
+
+// IsLocal returns true if the object lives in the vineyard instance the client
+// connects to, newly created metadata is treated as local as well.
+func (o *ObjectMeta) IsLocal() bool {
+	if !o.HasKey("instance_id") {
+		return true
+	}
+	if o.client == nil {
+		return false
+	}
+	return o.client.InstanceID() == o.GetInstanceId()
+}
+
 func (o *ObjectMeta) AddKeyValue(key string, value interface{}) {
 	o.meta[key] = value
 }
@@ -59,6 +86,50 @@ func (o *ObjectMeta) SetNBytes(nbytes int) {
 	o.meta["nbytes"] = nbytes
 }
 
+// GetNBytes returns 0 for objects that have no nbytes, e.g., global objects.
+func (o *ObjectMeta) GetNBytes() int {
+	nbytes, err := GetKeyValue[int](o, "nbytes")
+	if err != nil {
+		return 0
+	}
+	return nbytes
+}
+
+func (o *ObjectMeta) SetTypeName(typeName string) {
+	o.meta["typename"] = typeName
+}
+
+func (o *ObjectMeta) GetTypeName() string {
+	typeName, _ := o.meta["typename"].(string)
+	return typeName
+}
+
+func (o *ObjectMeta) SetGlobal(global bool) {
+	o.meta["global"] = global
+}
+
+func (o *ObjectMeta) IsGlobal() bool {
+	global, _ := o.meta["global"].(bool)
+	return global
+}
+
+// SetLabel attaches the label to the metadata before it is created, the
+// labels of existing objects are attached via the Label of clients.
+func (o *ObjectMeta) SetLabel(key, value string) {
+	labels := o.GetLabels()
+	labels[key] = value
+	o.meta[labelsKey] = toJSON(labels)
+}
+
+// GetLabels returns the labels attached to the object.
+func (o *ObjectMeta) GetLabels() map[string]string {
+	labels, err := GetKeyValue[map[string]string](o, labelsKey)
+	if err != nil || labels == nil {
+		return make(map[string]string)
+	}
+	return labels
+}
+
 func (o *ObjectMeta) InComplete() bool {
 	return o.inComplete
 }
@@ -68,13 +139,115 @@ func (o *ObjectMeta) MetaData() interface{} {
 }
 
 func (o *ObjectMeta) SetId(id common.ObjectID) {
-	o.meta["id"] = strconv.FormatUint(id, 10)
+	o.meta["id"] = common.ObjectIDToString(id)
+}
+
+func (o *ObjectMeta) GetId() common.ObjectID {
+	if id, err := getObjectID(o.meta); err == nil {
+		return id
+	}
+	return common.InvalidObjectID()
 }
 
 func (o *ObjectMeta) SetSignature(signature common.Signature) {
 	o.meta["signature"] = signature
 }
 
+func (o *ObjectMeta) GetSignature() common.Signature {
+	signature, err := GetKeyValue[common.Signature](o, "signature")
+	if err != nil {
+		return common.InvalidSignature()
+	}
+	return signature
+}
+
+func (o *ObjectMeta) GetBufferSet() *BufferSet {
+	return &o.bufferSet
+}
+
+// GetBuffer returns the payload of a blob that has been fetched from vineyard
+// server, the empty blob always has a nil buffer.
+func (o *ObjectMeta) GetBuffer(id common.ObjectID) (*memory.Buffer, error) {
+	if id == common.EmptyBlobID() {
+		return memory.NewBufferBytes(nil), nil
+	}
+	buffer, ok := o.bufferSet.Get(id)
+	if !ok {
+		return nil, fmt.Errorf("the blob %s is not a member of the object", common.ObjectIDToString(id))
+	}
+	if buffer == nil {
+		return nil, fmt.Errorf("The object might be a (partially) remote object "+
+			"and the payload data is not locally available: %s", common.ObjectIDToString(id))
+	}
+	return buffer, nil
+}
+
+func (o *ObjectMeta) AddMember(name string, member *ObjectMeta) error {
+	if o.HasKey(name) {
+		return fmt.Errorf("the member '%s' already exists", name)
+	}
+	o.meta[name] = member.meta
+	o.bufferSet.Extend(&member.bufferSet)
+	if member.inComplete {
+		o.inComplete = true
+	}
+	return nil
+}
+
+// AddMemberId adds a member that is only referenced by id, the metadata
+// becomes incomplete and will be completed by vineyard server on creation.
+func (o *ObjectMeta) AddMemberId(name string, id common.ObjectID) error {
+	if o.HasKey(name) {
+		return fmt.Errorf("the member '%s' already exists", name)
+	}
+	o.meta[name] = map[string]interface{}{"id": common.ObjectIDToString(id)}
+	o.inComplete = true
+	return nil
+}
+
+// GetMember returns the metadata of the member with the given name, the
+// buffers that have been resolved in the parent (including those of remote
+// blobs) are shared with the member.
+func (o *ObjectMeta) GetMember(name string) (*ObjectMeta, error) {
+	tree, ok := o.meta[name].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("failed to get member '%s'", name)
+	}
+	member := &ObjectMeta{}
+	if err := member.SetMetaData(o.client, tree); err != nil {
+		return nil, err
+	}
+	for id := range member.Blobs() {
+		if buffer, ok := o.bufferSet.Get(id); ok && buffer != nil {
+			member.bufferSet.EmplaceBuffer(id)
+			_ = member.bufferSet.SetBuffer(id, buffer)
+		}
+	}
+	return member, nil
+}
+
+// ForEachMember calls fn for every member in the order of member names and
+// stops at the first error.
+func (o *ObjectMeta) ForEachMember(fn func(name string, member *ObjectMeta) error) error {
+	names := make([]string, 0, len(o.meta))
+	for name, value := range o.meta {
+		if _, ok := value.(map[string]interface{}); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		member, err := o.GetMember(name)
+		if err != nil {
+			return err
+		}
+		if err := fn(name, member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (o *ObjectMeta) Reset() {
 	o.client = nil
 	o.meta = make(map[string]interface{})
@@ -82,7 +255,108 @@ func (o *ObjectMeta) Reset() {
 	o.inComplete = false
 }
 
-func (o *ObjectMeta) SetMetaData(client *IIPCClient, val map[string]interface{}) {
+// SetMetaData sets the metadata tree and collects the ids of blobs that
+// are local to the client into the buffer set.
+func (o *ObjectMeta) SetMetaData(client IClient, val map[string]interface{}) error {
 	o.client = client
 	o.meta = val
+	o.bufferSet.Reset()
+	return o.findAllBlobs(val)
+}
+
+func (o *ObjectMeta) findAllBlobs(tree map[string]interface{}) error {
+	if len(tree) == 0 {
+		return nil
+	}
+	id, err := getObjectID(tree)
+	if err != nil {
+		return err
+	}
+	if common.IsBlob(id) {
+		// newly created blobs that haven't been assigned an instance id are local
+		if _, ok := tree["instance_id"]; !ok || o.client == nil {
+			o.bufferSet.EmplaceBuffer(id)
+			return nil
+		}
+		instanceID, err := convertTo[common.InstanceID](tree["instance_id"])
+		if err != nil {
+			return fmt.Errorf("invalid instance_id of blob %s: %v", common.ObjectIDToString(id), err)
+		}
+		if instanceID == o.client.InstanceID() {
+			o.bufferSet.EmplaceBuffer(id)
+		}
+		return nil
+	}
+	for _, value := range tree {
+		if member, ok := value.(map[string]interface{}); ok {
+			if err := o.findAllBlobs(member); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Blobs returns the blobs that the object consists of, mapped to the vineyard
+// instances they live in. Blobs that haven't been assigned an instance are
+// mapped to common.UnspecifiedInstanceID().
+func (o *ObjectMeta) Blobs() map[common.ObjectID]common.InstanceID {
+	blobs := make(map[common.ObjectID]common.InstanceID)
+	collectBlobs(o.meta, blobs)
+	return blobs
+}
+
+func collectBlobs(tree map[string]interface{}, blobs map[common.ObjectID]common.InstanceID) {
+	if id, err := getObjectID(tree); err == nil && common.IsBlob(id) {
+		instanceID, err := convertTo[common.InstanceID](tree["instance_id"])
+		if err != nil {
+			instanceID = common.UnspecifiedInstanceID()
+		}
+		blobs[id] = instanceID
+		return
+	}
+	for _, value := range tree {
+		if member, ok := value.(map[string]interface{}); ok {
+			collectBlobs(member, blobs)
+		}
+	}
+}
+
+func getObjectID(tree map[string]interface{}) (common.ObjectID, error) {
+	id, ok := tree["id"].(string)
+	if !ok || id == "" {
+		return common.InvalidObjectID(), fmt.Errorf("metadata doesn't contain a valid id: %v", tree["id"])
+	}
+	return common.ObjectIDFromString(id)
+}
+
+// GetKeyValue returns the value of the given key as type T. Besides plain
+// values, composite values (e.g., slices and maps) that are stored as a json
+// string in the metadata are decoded as well.
+func GetKeyValue[T any](o *ObjectMeta, key string) (T, error) {
+	value, ok := o.meta[key]
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("key '%s' doesn't exist in metadata", key)
+	}
+	result, err := convertTo[T](value)
+	if err != nil {
+		return result, fmt.Errorf("invalid value at key '%s': %v", key, err)
+	}
+	return result, nil
+}
+
+func convertTo[T any](value interface{}) (T, error) {
+	var result T
+	if v, ok := value.(T); ok {
+		return v, nil
+	}
+	if s, ok := value.(string); ok {
+		return result, json.Unmarshal([]byte(s), &result)
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return result, err
+	}
+	return result, json.Unmarshal(bytes, &result)
 }
@@ -0,0 +1,150 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+	"gotest.tools/v3/assert"
+)
+
+type fakeClient struct {
+	instanceID common.InstanceID
+}
+
+func (f *fakeClient) InstanceID() common.InstanceID {
+	return f.instanceID
+}
+
+const getDataReply = `{
+	"type": "get_data_reply",
+	"content": {
+		"o0000000000000010": {
+			"id": "o0000000000000010",
+			"typename": "vineyard::Tensor<int64>",
+			"nbytes": 24,
+			"instance_id": 1,
+			"signature": 18446744073709551557,
+			"transient": true,
+			"shape_": "[3]",
+			"value_type_": "int64",
+			"buffer_": {
+				"id": "o8000000000000011",
+				"typename": "vineyard::Blob",
+				"nbytes": 24,
+				"instance_id": 1
+			},
+			"remote_": {
+				"id": "o8000000000000012",
+				"typename": "vineyard::Blob",
+				"nbytes": 8,
+				"instance_id": 2
+			}
+		}
+	}
+}`
+
+func TestObjectMeta_SetMetaData(t *testing.T) {
+	var reply common.GetDataReply
+	assert.NilError(t, common.DecodeMsg(getDataReply, &reply))
+
+	var meta ObjectMeta
+	err := meta.SetMetaData(&fakeClient{instanceID: 1}, reply.Content["o0000000000000010"])
+	assert.NilError(t, err)
+
+	assert.Equal(t, meta.GetId(), common.ObjectID(0x10))
+	assert.Equal(t, meta.GetTypeName(), "vineyard::Tensor<int64>")
+	assert.Equal(t, meta.GetNBytes(), 24)
+	assert.Equal(t, meta.GetInstanceId(), common.InstanceID(1))
+	assert.Equal(t, meta.GetSignature(), common.Signature(18446744073709551557))
+	assert.Assert(t, meta.IsLocal())
+
+	shape, err := GetKeyValue[[]int](&meta, "shape_")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, shape, []int{3})
+	valueType, err := GetKeyValue[string](&meta, "value_type_")
+	assert.NilError(t, err)
+	assert.Equal(t, valueType, "int64")
+	transient, err := GetKeyValue[bool](&meta, "transient")
+	assert.NilError(t, err)
+	assert.Assert(t, transient)
+	_, err = GetKeyValue[int](&meta, "not_exists")
+	assert.Assert(t, err != nil)
+
+	// the remote blob is not part of the local buffer set
+	assert.DeepEqual(t, meta.GetBufferSet().AllBufferIds(), []common.ObjectID{0x8000000000000011})
+
+	buffer, err := meta.GetMember("buffer_")
+	assert.NilError(t, err)
+	assert.Equal(t, buffer.GetId(), common.ObjectID(0x8000000000000011))
+	assert.Equal(t, buffer.GetTypeName(), "vineyard::Blob")
+	_, err = meta.GetMember("shape_")
+	assert.Assert(t, err != nil)
+
+	var names []string
+	err = meta.ForEachMember(func(name string, member *ObjectMeta) error {
+		names = append(names, name)
+		return nil
+	})
+	assert.NilError(t, err)
+	assert.Assert(t, sort.StringsAreSorted(names))
+	assert.DeepEqual(t, names, []string{"buffer_", "remote_"})
+}
+
+func TestObjectMeta_RemoteBlobs(t *testing.T) {
+	var reply common.GetDataReply
+	assert.NilError(t, common.DecodeMsg(getDataReply, &reply))
+
+	var meta ObjectMeta
+	err := meta.SetMetaData(&fakeClient{instanceID: 1}, reply.Content["o0000000000000010"])
+	assert.NilError(t, err)
+	assert.DeepEqual(t, meta.Blobs(), map[common.ObjectID]common.InstanceID{
+		0x8000000000000011: 1,
+		0x8000000000000012: 2,
+	})
+
+	// the payload of remote blob fetched into the parent is shared with members
+	meta.GetBufferSet().EmplaceBuffer(0x8000000000000012)
+	assert.NilError(t, meta.GetBufferSet().SetBuffer(0x8000000000000012, memory.NewBufferBytes(make([]byte, 8))))
+	remote, err := meta.GetMember("remote_")
+	assert.NilError(t, err)
+	buffer, err := remote.GetBuffer(0x8000000000000012)
+	assert.NilError(t, err)
+	assert.Equal(t, buffer.Len(), 8)
+}
+
+func TestObjectMeta_SetMetaDataInvalid(t *testing.T) {
+	var meta ObjectMeta
+	err := meta.SetMetaData(nil, map[string]interface{}{"typename": "vineyard::Blob"})
+	assert.Assert(t, err != nil)
+}
+
+func TestObjectMeta_Labels(t *testing.T) {
+	var meta ObjectMeta
+	meta.Init()
+	assert.DeepEqual(t, meta.GetLabels(), map[string]string{})
+
+	meta.SetLabel("k8s.v6d.io/job", "producer")
+	meta.SetLabel("run", "1")
+	assert.DeepEqual(t, meta.GetLabels(), map[string]string{"k8s.v6d.io/job": "producer", "run": "1"})
+
+	// the labels from vineyard server might be a json object as well
+	meta.AddKeyValue("__labels", map[string]interface{}{"run": "2"})
+	assert.DeepEqual(t, meta.GetLabels(), map[string]string{"run": "2"})
+}
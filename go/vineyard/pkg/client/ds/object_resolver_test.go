@@ -0,0 +1,69 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds_test
+
+import (
+	"testing"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/client/ds"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+	"gotest.tools/v3/assert"
+)
+
+// pair is a data structure defined outside the ds package.
+type pair struct {
+	ds.ObjectBase
+
+	first, second int64
+}
+
+func resolvePair(meta *ds.ObjectMeta) (ds.Object, error) {
+	first, err := ds.GetKeyValue[int64](meta, "first_")
+	if err != nil {
+		return nil, err
+	}
+	second, err := ds.GetKeyValue[int64](meta, "second_")
+	if err != nil {
+		return nil, err
+	}
+	return &pair{ObjectBase: ds.NewObjectBase(meta), first: first, second: second}, nil
+}
+
+func TestObject_RegisterResolver(t *testing.T) {
+	ds.RegisterResolver("test::Pair<.*>", resolvePair)
+
+	var meta ds.ObjectMeta
+	assert.NilError(t, meta.SetMetaData(nil, map[string]interface{}{
+		"id":       common.ObjectIDToString(0x10),
+		"typename": "test::Pair<int64,int64>",
+		"nbytes":   0,
+		"first_":   1,
+		"second_":  2,
+	}))
+	object, err := ds.ResolveObject(&meta)
+	assert.NilError(t, err)
+	p, ok := object.(*pair)
+	assert.Assert(t, ok, "expect a pair, but got %T", object)
+	assert.Equal(t, p.ID(), common.ObjectID(0x10))
+	assert.Equal(t, p.Meta(), &meta)
+	assert.Equal(t, p.first, int64(1))
+	assert.Equal(t, p.second, int64(2))
+
+	// the pattern must match the whole typename
+	meta.SetTypeName("test::Pair<int64,int64>::Inner")
+	_, err = ds.ResolveObject(&meta)
+	assert.ErrorContains(t, err, "no resolver is registered for typename 'test::Pair<int64,int64>::Inner'")
+}
@@ -0,0 +1,66 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"context"
+	"testing"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+	"gotest.tools/v3/assert"
+)
+
+func TestObject_ResolveBuiltin(t *testing.T) {
+	client := newFakeIPCClient()
+	meta, err := BuildTensor(context.Background(), client, []int32{1, 2, 3}, []int64{3})
+	assert.NilError(t, err)
+	object, err := ResolveObject(client.getMetaData(t, meta))
+	assert.NilError(t, err)
+	tensor, ok := object.(*Tensor)
+	assert.Assert(t, ok, "expect a tensor, but got %T", object)
+	assert.Equal(t, tensor.ID(), meta.GetId())
+	assert.Equal(t, tensor.NBytes(), 12)
+	assert.Assert(t, tensor.IsLocal())
+
+	var builder HashMapBuilder[int64, float64]
+	builder.Init(client)
+	builder.Put(1, 1.5)
+	assert.NilError(t, builder.Seal(context.Background()))
+	meta = builder.Meta()
+	object, err = ResolveObject(client.getMetaData(t, meta))
+	assert.NilError(t, err)
+	hashmap, ok := object.(*HashMap[int64, float64])
+	assert.Assert(t, ok, "expect a hashmap, but got %T", object)
+	value, _ := hashmap.Get(1)
+	assert.Equal(t, value, 1.5)
+
+	entries, err := meta.GetMember("entries_")
+	assert.NilError(t, err)
+	buffer, err := entries.GetMember("buffer_")
+	assert.NilError(t, err)
+	blob := buffer.GetId()
+	var blobMeta ObjectMeta
+	assert.NilError(t, blobMeta.SetMetaData(client, map[string]interface{}{
+		"id":       common.ObjectIDToString(blob),
+		"typename": "vineyard::Blob",
+		"length":   len(client.blobs[blob]),
+	}))
+	object, err = ResolveObject(client.getMetaData(t, &blobMeta))
+	assert.NilError(t, err)
+	data, err := object.(*Blob).Data()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, data, client.blobs[blob])
+}
@@ -0,0 +1,120 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+// ScalarValue is the value types of vineyard::Scalar<T>.
+type ScalarValue interface {
+	int32 | int64 | uint32 | uint64 | float32 | float64 | string
+}
+
+// AnyType is the type of scalar values, the same as vineyard::AnyType in C++.
+type AnyType int
+
+const (
+	AnyTypeUndefined AnyType = 0
+	AnyTypeInt32     AnyType = 1
+	AnyTypeUInt32    AnyType = 2
+	AnyTypeInt64     AnyType = 3
+	AnyTypeUInt64    AnyType = 4
+	AnyTypeFloat     AnyType = 5
+	AnyTypeDouble    AnyType = 6
+	AnyTypeString    AnyType = 7
+)
+
+// cppTypeName returns the name of the type in vineyard's C++ typenames.
+func cppTypeName[T ScalarValue]() (string, AnyType) {
+	var value T
+	switch any(value).(type) {
+	case int32:
+		return "int", AnyTypeInt32
+	case int64:
+		return "int64", AnyTypeInt64
+	case uint32:
+		return "uint", AnyTypeUInt32
+	case uint64:
+		return "uint64", AnyTypeUInt64
+	case float32:
+		return "float", AnyTypeFloat
+	case float64:
+		return "double", AnyTypeDouble
+	}
+	return "std::string", AnyTypeString
+}
+
+// BuildScalar creates a vineyard::Scalar<T> of the value.
+func BuildScalar[T ScalarValue](ctx context.Context, client IIPCClient, value T) (*ObjectMeta, error) {
+	typeName, anyType := cppTypeName[T]()
+	var meta ObjectMeta
+	meta.Init()
+	meta.SetTypeName(fmt.Sprintf("vineyard::Scalar<%s>", typeName))
+	meta.AddKeyValue("value_", value)
+	meta.AddKeyValue("type_", anyType)
+	meta.SetNBytes(0)
+	var id common.ObjectID
+	if err := client.CreateMetaData(ctx, &meta, &id); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// Scalar is a vineyard::Scalar<T>, e.g., the int, float and str values put
+// by python.
+type Scalar struct {
+	ObjectBase
+
+	value interface{}
+}
+
+// ResolveScalar resolves the scalar from the metadata. The value is an int64,
+// uint64, float64 or string, as python creates vineyard::Scalar<int> for
+// all integers.
+func ResolveScalar(meta *ObjectMeta) (*Scalar, error) {
+	typeName := meta.GetTypeName()
+	if !strings.HasPrefix(typeName, "vineyard::Scalar<") {
+		return nil, fmt.Errorf("expect typename 'vineyard::Scalar<T>', but got '%s'", typeName)
+	}
+	valueType := strings.TrimSuffix(strings.TrimPrefix(typeName, "vineyard::Scalar<"), ">")
+	var value interface{}
+	var err error
+	switch valueType {
+	case "int", "int32", "int64", "long long":
+		value, err = GetKeyValue[int64](meta, "value_")
+	case "uint", "uint32", "uint64":
+		value, err = GetKeyValue[uint64](meta, "value_")
+	case "float", "double":
+		value, err = GetKeyValue[float64](meta, "value_")
+	case "std::string", "string", "str":
+		value, err = GetKeyValue[string](meta, "value_")
+	default:
+		return nil, fmt.Errorf("unsupported value type of scalar: %s", valueType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Scalar{ObjectBase: ObjectBase{meta: meta}, value: value}, nil
+}
+
+func (s *Scalar) Value() interface{} {
+	return s.value
+}
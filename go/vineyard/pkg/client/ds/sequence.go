@@ -0,0 +1,121 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+// Sequence is a vineyard::Sequence, i.e., a tuple of objects, which is how
+// python stores lists and tuples.
+type Sequence struct {
+	ObjectBase
+
+	size int
+}
+
+func ResolveSequence(meta *ObjectMeta) (*Sequence, error) {
+	if meta.GetTypeName() != "vineyard::Sequence" {
+		return nil, fmt.Errorf("expect typename 'vineyard::Sequence', but got '%s'", meta.GetTypeName())
+	}
+	size, err := GetKeyValue[int](meta, "__elements_-size")
+	if err != nil {
+		return nil, err
+	}
+	return &Sequence{ObjectBase: ObjectBase{meta: meta}, size: size}, nil
+}
+
+func (s *Sequence) Size() int {
+	return s.size
+}
+
+// At returns the metadata of the index-th element, which could be resolved
+// according to its typename, e.g., by ResolveTensor.
+func (s *Sequence) At(index int) (*ObjectMeta, error) {
+	if index < 0 || index >= s.size {
+		return nil, fmt.Errorf("index out of range: %d, the size of sequence is %d", index, s.size)
+	}
+	return s.meta.GetMember("__elements_-" + strconv.Itoa(index))
+}
+
+// SequenceBuilder builds a vineyard::Sequence of existing objects.
+type SequenceBuilder struct {
+	ObjectBuilder
+
+	Client   IIPCClient
+	elements []*ObjectMeta
+	meta     *ObjectMeta
+}
+
+func (s *SequenceBuilder) Init(client IIPCClient) {
+	s.Client = client
+}
+
+func (s *SequenceBuilder) Append(element *ObjectMeta) {
+	s.elements = append(s.elements, element)
+}
+
+func (s *SequenceBuilder) Size() int {
+	return len(s.elements)
+}
+
+func (s *SequenceBuilder) Seal(ctx context.Context) error {
+	if s.sealed {
+		return fmt.Errorf("the builder has already been sealed")
+	}
+	if err := s.Build(ctx); err != nil {
+		return err
+	}
+	s.SetSeal(true)
+	return nil
+}
+
+func (s *SequenceBuilder) Build(ctx context.Context) error {
+	var meta ObjectMeta
+	meta.Init()
+	meta.SetTypeName("vineyard::Sequence")
+	meta.AddKeyValue("size_", len(s.elements))
+	nbytes := 0
+	for index, element := range s.elements {
+		if err := meta.AddMember("__elements_-"+strconv.Itoa(index), element); err != nil {
+			return err
+		}
+		nbytes += element.GetNBytes()
+	}
+	meta.AddKeyValue("__elements_-size", len(s.elements))
+	meta.SetNBytes(nbytes)
+	var id common.ObjectID
+	if err := s.Client.CreateMetaData(ctx, &meta, &id); err != nil {
+		return err
+	}
+	s.meta = &meta
+	return nil
+}
+
+func (s *SequenceBuilder) Id() common.ObjectID {
+	if s.meta == nil {
+		return common.InvalidObjectID()
+	}
+	return s.meta.GetId()
+}
+
+func (s *SequenceBuilder) Meta() *ObjectMeta {
+	return s.meta
+}
@@ -0,0 +1,91 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"context"
+	"testing"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+	"gotest.tools/v3/assert"
+)
+
+func TestScalar_BuildAndResolve(t *testing.T) {
+	client := newFakeIPCClient()
+	meta, err := BuildScalar(context.Background(), client, int64(1)<<60)
+	assert.NilError(t, err)
+	assert.Equal(t, meta.GetTypeName(), "vineyard::Scalar<int64>")
+	scalar, err := ResolveScalar(client.getMetaData(t, meta))
+	assert.NilError(t, err)
+	assert.Equal(t, scalar.Value(), int64(1)<<60)
+
+	meta, err = BuildScalar(context.Background(), client, "vineyard")
+	assert.NilError(t, err)
+	assert.Equal(t, meta.GetTypeName(), "vineyard::Scalar<std::string>")
+	scalar, err = ResolveScalar(client.getMetaData(t, meta))
+	assert.NilError(t, err)
+	assert.Equal(t, scalar.Value(), "vineyard")
+}
+
+func TestScalar_ResolvePython(t *testing.T) {
+	// the metadata of vineyard.put(3.5)
+	var tree map[string]interface{}
+	assert.NilError(t, common.DecodeMsg(`{
+		"id": "o0000000000000010",
+		"typename": "vineyard::Scalar<double>",
+		"value_": 3.5,
+		"type_": "float",
+		"nbytes": 0
+	}`, &tree))
+	var meta ObjectMeta
+	assert.NilError(t, meta.SetMetaData(&fakeClient{instanceID: 1}, tree))
+	scalar, err := ResolveScalar(&meta)
+	assert.NilError(t, err)
+	assert.Equal(t, scalar.Value(), 3.5)
+}
+
+func TestSequence_BuildAndResolve(t *testing.T) {
+	client := newFakeIPCClient()
+	first, err := BuildScalar(context.Background(), client, int32(1))
+	assert.NilError(t, err)
+	second, err := BuildTensor(context.Background(), client, []int64{1, 2}, []int64{2})
+	assert.NilError(t, err)
+
+	var builder SequenceBuilder
+	builder.Init(client)
+	builder.Append(first)
+	builder.Append(second)
+	assert.NilError(t, builder.Seal(context.Background()))
+	assert.Equal(t, builder.Meta().GetNBytes(), 16)
+
+	sequence, err := ResolveSequence(client.getMetaData(t, builder.Meta()))
+	assert.NilError(t, err)
+	assert.Equal(t, sequence.Size(), 2)
+	element, err := sequence.At(0)
+	assert.NilError(t, err)
+	scalar, err := ResolveScalar(element)
+	assert.NilError(t, err)
+	assert.Equal(t, scalar.Value(), int64(1))
+	element, err = sequence.At(1)
+	assert.NilError(t, err)
+	tensor, err := ResolveTensor(element)
+	assert.NilError(t, err)
+	values, err := TensorValues[int64](tensor)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, values, []int64{1, 2})
+	_, err = sequence.At(2)
+	assert.Assert(t, err != nil)
+}
@@ -0,0 +1,314 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+// TensorValue is the element types of tensors that could be accessed in Go.
+type TensorValue interface {
+	bool | int8 | int16 | int32 | int64 | uint8 | uint16 | uint32 | uint64 | float32 | float64
+}
+
+// tensorType describes the value type of vineyard::Tensor<T>, in the same
+// way as numpy and the python builder of vineyard.
+type tensorType struct {
+	cppName   string // the "T" in the typename
+	numpyName string // "value_type_"
+	numpyStr  string // "value_type_meta_"
+	size      int
+}
+
+var tensorTypes = []tensorType{
+	{"bool", "bool", "|b1", 1},
+	{"int8", "int8", "|i1", 1},
+	{"int16", "int16", "<i2", 2},
+	{"int", "int32", "<i4", 4},
+	{"int64", "int64", "<i8", 8},
+	{"uint8", "uint8", "|u1", 1},
+	{"uint16", "uint16", "<u2", 2},
+	{"uint32", "uint32", "<u4", 4},
+	{"uint64", "uint64", "<u8", 8},
+	{"float", "float32", "<f4", 4},
+	{"double", "float64", "<f8", 8},
+}
+
+// the aliases of value types that used by C++ and python
+var tensorTypeAliases = map[string]string{
+	"int32_t": "int32", "i32": "int32", "int": "int32",
+	"int64_t": "int64", "i64": "int64", "long long": "int64",
+	"uint32_t": "uint32", "u32": "uint32", "uint": "uint32", "uint_t": "uint32",
+	"uint64_t": "uint64", "u64": "uint64",
+	"int8_t": "int8", "int16_t": "int16", "uint8_t": "uint8", "uint16_t": "uint16",
+	"float": "float32", "double": "float64",
+}
+
+func tensorTypeOf[T TensorValue]() tensorType {
+	var value T
+	var name string
+	switch any(value).(type) {
+	case bool:
+		name = "bool"
+	case int8:
+		name = "int8"
+	case int16:
+		name = "int16"
+	case int32:
+		name = "int32"
+	case int64:
+		name = "int64"
+	case uint8:
+		name = "uint8"
+	case uint16:
+		name = "uint16"
+	case uint32:
+		name = "uint32"
+	case uint64:
+		name = "uint64"
+	case float32:
+		name = "float32"
+	case float64:
+		name = "float64"
+	}
+	t, _ := lookupTensorType(name)
+	return t
+}
+
+func lookupTensorType(name string) (tensorType, bool) {
+	if alias, ok := tensorTypeAliases[name]; ok {
+		name = alias
+	}
+	for _, t := range tensorTypes {
+		if t.numpyName == name {
+			return t, true
+		}
+	}
+	return tensorType{}, false
+}
+
+func toJSON(value interface{}) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+func numElements(shape []int64) int {
+	size := 1
+	for _, dim := range shape {
+		size *= int(dim)
+	}
+	return size
+}
+
+// Tensor is a vineyard::Tensor<T>, which is the vineyard representation of
+// numpy.ndarray.
+type Tensor struct {
+	ObjectBase
+
+	shape          []int64
+	partitionIndex []int64
+	valueType      string
+	order          string
+	buffer         *memory.Buffer
+}
+
+// ResolveTensor resolves the tensor from the metadata, the buffer is the
+// shared memory of vineyard server and no copy happens.
+func ResolveTensor(meta *ObjectMeta) (*Tensor, error) {
+	if !strings.HasPrefix(meta.GetTypeName(), "vineyard::Tensor<") {
+		return nil, fmt.Errorf("expect typename 'vineyard::Tensor<T>', but got '%s'", meta.GetTypeName())
+	}
+	tensor := &Tensor{ObjectBase: ObjectBase{meta: meta}, order: "C"}
+	var err error
+	if tensor.shape, err = GetKeyValue[[]int64](meta, "shape_"); err != nil {
+		return nil, err
+	}
+	if meta.HasKey("partition_index_") {
+		if tensor.partitionIndex, err = GetKeyValue[[]int64](meta, "partition_index_"); err != nil {
+			return nil, err
+		}
+	}
+	if tensor.valueType, err = GetKeyValue[string](meta, "value_type_"); err != nil {
+		return nil, err
+	}
+	if t, ok := lookupTensorType(tensor.valueType); ok {
+		tensor.valueType = t.numpyName
+	}
+	if meta.HasKey("order_") {
+		order, err := GetKeyValue[string](meta, "order_")
+		if err != nil {
+			return nil, err
+		}
+		// the order is a json string, e.g., "\"C\""
+		if err := json.Unmarshal([]byte(order), &tensor.order); err != nil {
+			tensor.order = order
+		}
+	}
+	if tensor.buffer, err = memberBuffer(meta, "buffer_"); err != nil {
+		return nil, err
+	}
+	return tensor, nil
+}
+
+func (t *Tensor) Shape() []int64 {
+	return t.shape
+}
+
+func (t *Tensor) PartitionIndex() []int64 {
+	return t.partitionIndex
+}
+
+// ValueType returns the numpy name of the value type, e.g., "int64" and
+// "float32", tensors of python objects have the value type "object".
+func (t *Tensor) ValueType() string {
+	return t.valueType
+}
+
+// Order returns "C" or "F", the buffer is always in the row-major order and
+// "F" means the tensor is expected to be read as a Fortran array by numpy.
+func (t *Tensor) Order() string {
+	return t.order
+}
+
+func (t *Tensor) Buffer() *memory.Buffer {
+	return t.buffer
+}
+
+// TensorValues returns the elements of the tensor in the row-major order
+// without copying, T must match the value type of the tensor.
+func TensorValues[T TensorValue](t *Tensor) ([]T, error) {
+	expected := tensorTypeOf[T]()
+	if t.valueType != expected.numpyName {
+		return nil, fmt.Errorf("the value type of tensor is '%s', but got '%s'", t.valueType, expected.numpyName)
+	}
+	length := numElements(t.shape)
+	if length*expected.size > t.buffer.Len() {
+		return nil, fmt.Errorf("the size of tensor buffer is %d, but expect %d", t.buffer.Len(), length*expected.size)
+	}
+	if length == 0 {
+		return []T{}, nil
+	}
+	return unsafe.Slice((*T)(unsafe.Pointer(&t.buffer.Bytes()[0])), length), nil
+}
+
+// TensorBuilder builds a vineyard::Tensor<T>, the elements are written to
+// the shared memory of vineyard server directly by Data().
+type TensorBuilder[T TensorValue] struct {
+	ObjectBuilder
+
+	Client         IIPCClient
+	Shape          []int64
+	PartitionIndex []int64
+	blobWriter     BlobWriter
+	meta           *ObjectMeta
+}
+
+func (t *TensorBuilder[T]) Init(ctx context.Context, client IIPCClient, shape []int64) error {
+	t.Client = client
+	t.Shape = shape
+	t.PartitionIndex = []int64{}
+	if size := numElements(shape) * tensorTypeOf[T]().size; size > 0 {
+		return client.CreateBlob(ctx, size, &t.blobWriter)
+	}
+	return nil
+}
+
+// Data returns the elements of the tensor in the row-major order.
+func (t *TensorBuilder[T]) Data() []T {
+	buffer := t.blobWriter.Buf()
+	if len(buffer) == 0 {
+		return []T{}
+	}
+	return unsafe.Slice((*T)(unsafe.Pointer(&buffer[0])), numElements(t.Shape))
+}
+
+func (t *TensorBuilder[T]) Seal(ctx context.Context) error {
+	if t.sealed {
+		return fmt.Errorf("the builder has already been sealed")
+	}
+	if err := t.Build(ctx); err != nil {
+		return err
+	}
+	t.SetSeal(true)
+	return nil
+}
+
+func (t *TensorBuilder[T]) Build(ctx context.Context) error {
+	valueType := tensorTypeOf[T]()
+	var meta ObjectMeta
+	meta.Init()
+	meta.SetTypeName(fmt.Sprintf("vineyard::Tensor<%s>", valueType.cppName))
+	meta.AddKeyValue("value_type_", valueType.numpyName)
+	meta.AddKeyValue("value_type_meta_", valueType.numpyStr)
+	meta.AddKeyValue("shape_", toJSON(t.Shape))
+	meta.AddKeyValue("partition_index_", toJSON(t.PartitionIndex))
+	meta.AddKeyValue("order_", toJSON("C"))
+	meta.SetNBytes(t.blobWriter.Size())
+
+	var bufferMeta *ObjectMeta
+	if t.blobWriter.Size() == 0 {
+		bufferMeta = newBlobMeta(t.Client, common.EmptyBlobID(), nil)
+	} else {
+		blob, err := t.blobWriter.Seal(ctx)
+		if err != nil {
+			return err
+		}
+		bufferMeta = newBlobMeta(t.Client, blob.ID(), blob.buffer)
+	}
+	if err := meta.AddMember("buffer_", bufferMeta); err != nil {
+		return err
+	}
+	var id common.ObjectID
+	if err := t.Client.CreateMetaData(ctx, &meta, &id); err != nil {
+		return err
+	}
+	t.meta = &meta
+	return nil
+}
+
+func (t *TensorBuilder[T]) Id() common.ObjectID {
+	if t.meta == nil {
+		return common.InvalidObjectID()
+	}
+	return t.meta.GetId()
+}
+
+func (t *TensorBuilder[T]) Meta() *ObjectMeta {
+	return t.meta
+}
+
+// BuildTensor creates a vineyard::Tensor<T> with a copy of values.
+func BuildTensor[T TensorValue](ctx context.Context, client IIPCClient, values []T, shape []int64) (*ObjectMeta, error) {
+	if numElements(shape) != len(values) {
+		return nil, fmt.Errorf("the shape %v doesn't match the number of values %d", shape, len(values))
+	}
+	var builder TensorBuilder[T]
+	if err := builder.Init(ctx, client, shape); err != nil {
+		return nil, err
+	}
+	copy(builder.Data(), values)
+	if err := builder.Seal(ctx); err != nil {
+		return nil, err
+	}
+	return builder.Meta(), nil
+}
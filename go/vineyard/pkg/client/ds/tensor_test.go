@@ -0,0 +1,98 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ds
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+	"gotest.tools/v3/assert"
+)
+
+func TestTensor_BuildAndResolve(t *testing.T) {
+	client := newFakeIPCClient()
+	var builder TensorBuilder[int32]
+	assert.NilError(t, builder.Init(context.Background(), client, []int64{2, 3}))
+	for index := range builder.Data() {
+		builder.Data()[index] = int32(index * 10)
+	}
+	assert.NilError(t, builder.Seal(context.Background()))
+	assert.Assert(t, builder.Seal(context.Background()) != nil)
+	assert.Equal(t, builder.Meta().GetTypeName(), "vineyard::Tensor<int>")
+	assert.Equal(t, builder.Meta().GetNBytes(), 24)
+
+	tensor, err := ResolveTensor(client.getMetaData(t, builder.Meta()))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, tensor.Shape(), []int64{2, 3})
+	assert.Equal(t, tensor.ValueType(), "int32")
+	assert.Equal(t, tensor.Order(), "C")
+	values, err := TensorValues[int32](tensor)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, values, []int32{0, 10, 20, 30, 40, 50})
+	_, err = TensorValues[int64](tensor)
+	assert.Assert(t, err != nil)
+
+	meta, err := BuildTensor(context.Background(), client, []float64{}, []int64{0})
+	assert.NilError(t, err)
+	tensor, err = ResolveTensor(client.getMetaData(t, meta))
+	assert.NilError(t, err)
+	empty, err := TensorValues[float64](tensor)
+	assert.NilError(t, err)
+	assert.Equal(t, len(empty), 0)
+}
+
+// the metadata of vineyard.put(np.array([1.5, 2.5]))
+const pythonTensorMeta = `{
+	"id": "o0000000000000010",
+	"typename": "vineyard::Tensor<double>",
+	"instance_id": 1,
+	"nbytes": 16,
+	"value_type_": "float64",
+	"value_type_meta_": "<f8",
+	"shape_": "[2]",
+	"partition_index_": "[]",
+	"order_": "\"C\"",
+	"buffer_": {
+		"id": "o8000000000000011",
+		"typename": "vineyard::Blob",
+		"length": 16,
+		"nbytes": 16,
+		"instance_id": 1
+	}
+}`
+
+func TestTensor_ResolvePython(t *testing.T) {
+	var tree map[string]interface{}
+	assert.NilError(t, common.DecodeMsg(pythonTensorMeta, &tree))
+	var meta ObjectMeta
+	assert.NilError(t, meta.SetMetaData(&fakeClient{instanceID: 1}, tree))
+	buffer := make([]byte, 16)
+	binary.LittleEndian.PutUint64(buffer, math.Float64bits(1.5))
+	binary.LittleEndian.PutUint64(buffer[8:], math.Float64bits(2.5))
+	assert.NilError(t, meta.GetBufferSet().SetBuffer(0x8000000000000011, memory.NewBufferBytes(buffer)))
+
+	tensor, err := ResolveTensor(&meta)
+	assert.NilError(t, err)
+	assert.Equal(t, tensor.Order(), "C")
+	assert.DeepEqual(t, tensor.PartitionIndex(), []int64{})
+	values, err := TensorValues[float64](tensor)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, values, []float64{1.5, 2.5})
+}
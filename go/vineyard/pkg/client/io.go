@@ -16,85 +16,99 @@ limitations under the License.
 package vineyard
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"net"
 	"strconv"
+	"syscall"
 	"time"
 )
 
-const kNumConnectAttempts = 10
-const kConnectTimeoutMs = 1000
+// ReconnectPolicy controls the attempts to connect to vineyard server, the
+// backoff between attempts doubles from InitialBackoff up to MaxBackoff.
+type ReconnectPolicy struct {
+	// MaxAttempts is the number of attempts, a non-positive value means
+	// retrying until the context is done.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
 
-func ConnectIPCSocketRetry(pathname string, conn **net.UnixConn) error {
-	var numRetries int = kNumConnectAttempts
-	var timeout int64 = kConnectTimeoutMs
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	}
+}
 
-	err := ConnectIPCSocket(pathname, conn)
-	for {
-		if err == nil || numRetries < 0 {
-			break
+// retry calls fn until it succeeds, the attempts are exhausted or ctx is done.
+func (p ReconnectPolicy) retry(ctx context.Context, fn func() error) error {
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+			return err
+		}
+		if err := sleepContext(ctx, backoff); err != nil {
+			return err
+		}
+		if backoff *= 2; backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
 		}
-		fmt.Println("Connecting to IPC socket failed for pathname", pathname, "with ret err", err.Error(),
-			", retrying", numRetries, "more times.")
-		time.Sleep(time.Duration(timeout) * time.Millisecond)
-		err = ConnectIPCSocket(pathname, conn)
-		numRetries--
-	}
-	if err != nil {
-		return err
 	}
-	return nil
 }
 
-func ConnectIPCSocket(pathname string, conn **net.UnixConn) error {
-	addr, err := net.ResolveUnixAddr("unix", pathname)
-	if err != nil {
-		return err
-	}
+// ConnectIPCSocketRetry connects to the unix socket, and retries following
+// the policy.
+func ConnectIPCSocketRetry(ctx context.Context, pathname string, conn **net.UnixConn, policy ReconnectPolicy) error {
+	return policy.retry(ctx, func() error {
+		return ConnectIPCSocket(ctx, pathname, conn)
+	})
+}
 
-	*conn, err = net.DialUnix("unix", nil, addr)
+func ConnectIPCSocket(ctx context.Context, pathname string, conn **net.UnixConn) error {
+	var dialer net.Dialer
+	c, err := dialer.DialContext(ctx, "unix", pathname)
 	if err != nil {
 		return err
 	}
-
+	*conn = c.(*net.UnixConn)
 	return nil
 }
 
-func ConnectRPCSocket(host string, port uint16, conn *net.Conn) error {
+func ConnectRPCSocket(ctx context.Context, host string, port uint16, conn *net.Conn) error {
+	var dialer net.Dialer
 	var err error
-	tcpAddr, err := net.ResolveTCPAddr("tcp", host+":"+strconv.Itoa(int(port)))
-	if err != nil {
-		return err
-	}
-
-	*conn, err = net.DialTCP("tcp", nil, tcpAddr)
+	*conn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func ConnectRPCSocketRetry(host string, port uint16, conn *net.Conn) error {
-	var numRetries int = kNumConnectAttempts
-	var timeout int64 = kConnectTimeoutMs
+// ConnectRPCSocketRetry connects to the tcp endpoint, and retries following
+// the policy.
+func ConnectRPCSocketRetry(ctx context.Context, host string, port uint16, conn *net.Conn, policy ReconnectPolicy) error {
+	return policy.retry(ctx, func() error {
+		return ConnectRPCSocket(ctx, host, port, conn)
+	})
+}
 
-	err := ConnectRPCSocket(host, port, conn)
-	for {
-		if err == nil || numRetries < 0 {
-			break
-		}
-		fmt.Println("Connecting to RPC socket failed for endpoint", host, ":", port, "with ret = ", err.Error(),
-			", retrying", numRetries, "more times.")
-		time.Sleep(time.Duration(timeout) * time.Millisecond)
-		err = ConnectRPCSocket(host, port, conn)
-		numRetries--
-	}
-	if err != nil {
-		return err
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return nil
 }
 
 func SendBytes(conn net.Conn, data []byte, length int) error {
@@ -103,7 +117,7 @@ func SendBytes(conn net.Conn, data []byte, length int) error {
 	for bytesLeft > 0 {
 		nBytes, err := conn.Write(data[offset:length])
 		if err != nil {
-			return errors.New(fmt.Sprintf("Send message failed :%s", err.Error()))
+			return fmt.Errorf("Send message failed :%w", err)
 		}
 		bytesLeft -= nBytes
 		offset += nBytes
@@ -131,7 +145,7 @@ func RecvBytes(conn net.Conn, data []byte, length int) error {
 	for bytesLeft > 0 {
 		nBytes, err := conn.Read(data[offset:length])
 		if err != nil {
-			return errors.New(fmt.Sprintf("Receive message failed :%s", err.Error()))
+			return fmt.Errorf("Receive message failed :%w", err)
 		}
 		bytesLeft -= nBytes
 		offset += nBytes
@@ -153,3 +167,41 @@ func RecvMessage(conn net.Conn, msg *string) error {
 	*msg = string(stringBytes)
 	return nil
 }
+
+// RecvFd receives a file descriptor that vineyard server sends along with a
+// single byte via SCM_RIGHTS.
+func RecvFd(conn *net.UnixConn) (int, error) {
+	data := make([]byte, 1)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	for {
+		_, oobn, _, _, err := conn.ReadMsgUnix(data, oob)
+		if err != nil {
+			if errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN) {
+				continue
+			}
+			return -1, fmt.Errorf("Receive file descriptor failed: %v", err)
+		}
+		oob = oob[:oobn]
+		break
+	}
+	messages, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return -1, fmt.Errorf("Receive file descriptor failed: %v", err)
+	}
+	var fds []int
+	for index := range messages {
+		rights, err := syscall.ParseUnixRights(&messages[index])
+		if err != nil {
+			continue
+		}
+		fds = append(fds, rights...)
+	}
+	if len(fds) != 1 {
+		// avoid leaking the unexpected fds
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+		return -1, fmt.Errorf("Receive file descriptor failed: expect 1 fd but got %d", len(fds))
+	}
+	return fds[0], nil
+}
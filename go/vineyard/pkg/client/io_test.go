@@ -16,15 +16,16 @@ limitations under the License.
 package vineyard
 
 import (
+	"context"
 	"net"
+	"strconv"
 	"testing"
 )
 
-// need root permission to run
 func TestConnectIPCSocketRetry(t *testing.T) {
-	var pathname string = "/var/run/vineyard.sock"
+	var pathname string = newTestServer(t).IPCSocket()
 	conn := new(net.UnixConn)
-	err := ConnectIPCSocketRetry(pathname, &conn)
+	err := ConnectIPCSocketRetry(context.Background(), pathname, &conn, DefaultReconnectPolicy())
 	if err != nil {
 		t.Fatal("Connect to IPC socket failed", err.Error())
 	}
@@ -32,10 +33,13 @@ func TestConnectIPCSocketRetry(t *testing.T) {
 }
 
 func TestConnectRPCSocketRetry(t *testing.T) {
-	var host string = "127.0.0.1"
-	var port uint16 = 9600
+	host, portString, err := net.SplitHostPort(newTestServer(t).RPCEndpoint())
+	if err != nil {
+		t.Fatal("invalid rpc endpoint", err)
+	}
+	port, _ := strconv.ParseUint(portString, 10, 16)
 	var conn net.Conn
-	err := ConnectRPCSocketRetry(host, port, &conn)
+	err = ConnectRPCSocketRetry(context.Background(), host, uint16(port), &conn, DefaultReconnectPolicy())
 	if err != nil {
 		t.Fatal("Connect to IPC socket failed", err.Error())
 	}
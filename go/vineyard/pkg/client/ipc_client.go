@@ -15,37 +15,39 @@ limitations under the License.
 
 package vineyard
 
-/*
-#cgo CFLAGS: -I ../common/memory
-#cgo LDFLAGS: -L ../common/memory -lfling
-
-#include <sys/mman.h>
-
-#include "fling.h"
-*/
-// nolint: typecheck
-import "C"
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"unsafe"
 
-	"github.com/apache/arrow/go/arrow/memory"
 	"github.com/v6d-io/v6d/go/vineyard/pkg/client/ds"
 	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+	"golang.org/x/sys/unix"
 )
 
 type IPCClient struct {
 	ClientBase
-	connected     bool
-	ipcSocket     string
-	conn          *net.UnixConn
-	instanceID    int
-	serverVersion string
-	rpcEndpoint   string
-	mmapTable     map[int]MmapEntry
+	ipcSocket   string
+	conn        *net.UnixConn
+	rpcEndpoint string
+	mmapTable   map[int]*MmapEntry
+
+	// references counts the blobs fetched in the current session, vineyard
+	// server holds a single reference per connection, which is released with
+	// the last blob. The generation increases once the session is reset.
+	refMutex   sync.Mutex
+	references map[common.ObjectID]int
+	generation int
+	// alive counts the references of each generation that haven't been
+	// dropped, and retired keeps the mappings of previous generations, which
+	// are unmapped once no blob of the generation refers to them.
+	alive   map[int]int
+	retired map[int][]*MmapEntry
 }
 
 type MmapEntry struct {
@@ -53,140 +55,643 @@ type MmapEntry struct {
 	mapSize   int64
 	readOnly  bool
 	realign   bool
-	roPointer unsafe.Pointer
-	rwPointer unsafe.Pointer
+	roMapping []byte
+	rwMapping []byte
 }
 
-func (m *MmapEntry) MapReadOnly() {
-	m.roPointer = C.mmap(nil, C.ulong(m.mapSize), C.PROT_READ, C.MAP_SHARED, C.int(m.clientFd), 0)
-	// TODO: error fix
+// length returns the size to map, the fake_mmap in server leaves a gap
+// between memory segments to make map_size page-aligned again.
+func (m *MmapEntry) length() int64 {
+	if m.realign {
+		return m.mapSize - int64(unsafe.Sizeof(uint64(0)))
+	}
+	return m.mapSize
 }
 
-func (m *MmapEntry) MapReadWrite() {
-	m.rwPointer = C.mmap(nil, C.ulong(m.mapSize), C.PROT_READ|C.PROT_WRITE, C.MAP_SHARED, C.int(m.clientFd), 0)
+func (m *MmapEntry) MapReadOnly() ([]byte, error) {
+	if m.roMapping == nil {
+		mapping, err := unix.Mmap(m.clientFd, 0, int(m.length()), unix.PROT_READ, unix.MAP_SHARED)
+		if err != nil {
+			return nil, fmt.Errorf("failed to mmap received fd as a readonly buffer: %v", err)
+		}
+		m.roMapping = mapping
+	}
+	return m.roMapping, nil
+}
+
+func (m *MmapEntry) MapReadWrite() ([]byte, error) {
+	if m.rwMapping == nil {
+		mapping, err := unix.Mmap(m.clientFd, 0, int(m.length()), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+		if err != nil {
+			return nil, fmt.Errorf("failed to mmap received fd as a writable buffer: %v", err)
+		}
+		m.rwMapping = mapping
+	}
+	return m.rwMapping, nil
 }
 
 // Connect to IPCClient steps as follows
 // 1. using unix socket connecct to vineyead server
 // 2. sending register request to server and get response from server
 // Note: you should send message's length first to server, then send message
-func (i *IPCClient) Connect(ipcSocket string) error {
-	if i.connected || i.ipcSocket == ipcSocket {
-		return nil
+//
+// The client reconnects to the same socket once the connection is lost,
+// following the reconnect policy in options.
+func (i *IPCClient) Connect(ctx context.Context, ipcSocket string, options ...Option) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.connected {
+		if i.ipcSocket == ipcSocket {
+			return nil
+		}
+		return fmt.Errorf("the client has already connected to %s", i.ipcSocket)
 	}
 	i.ipcSocket = ipcSocket
-	i.conn = new(net.UnixConn)
-	if err := ConnectIPCSocketRetry(i.ipcSocket, &i.conn); err != nil {
-		return err
-	}
-	i.ClientBase.conn = i.conn
-	var messageOut string
-	common.WriteRegisterRequest(&messageOut)
-	if err := i.DoWrite(messageOut); err != nil {
+	i.applyOptions(options)
+	if err := i.register(ctx); err != nil {
 		return err
 	}
-	var messageIn string
-	err := i.DoRead(&messageIn)
-	if err != nil {
+	i.ClientBase.register = i.register
+	return nil
+}
+
+// register connects to the socket and registers the client to vineyard
+// server, the caller must hold the mutex.
+func (i *IPCClient) register(ctx context.Context) error {
+	var conn *net.UnixConn
+	if err := ConnectIPCSocketRetry(ctx, i.ipcSocket, &conn, i.policy); err != nil {
 		return err
 	}
+	i.conn = conn
+	i.ClientBase.conn = conn
 	var registerReply common.RegisterReply
-	err = json.Unmarshal([]byte(messageIn), &registerReply)
+	err := i.withContext(ctx, func() error {
+		var messageOut string
+		if err := i.writeRegisterRequest(&messageOut); err != nil {
+			return err
+		}
+		return i.request(messageOut, common.REGISTER_REPLY, &registerReply)
+	})
+	if err == nil {
+		err = i.checkRegisterReply(&registerReply)
+	}
 	if err != nil {
+		conn.Close()
 		return err
 	}
-	i.instanceID = registerReply.InstanceID
-	if registerReply.Version == "" {
-		i.serverVersion = common.DEFAULT_SERVER_VERSION
-	} else {
-		i.serverVersion = registerReply.Version
-	}
+	atomic.StoreUint64(&i.instanceID, registerReply.InstanceID)
 	i.connected = true
 	i.rpcEndpoint = registerReply.RPCEndpoint
-	i.mmapTable = make(map[int]MmapEntry)
-	// TODO: compatible server check
+	i.resetMmapTable()
+	i.resetReferences()
 	return nil
 }
 
-func (i *IPCClient) CreateBlob(size int, blob *ds.BlobWriter) {
-	if i.connected == false {
+// NewSession creates a session of vineyard server with a bulk store of the
+// given type, i.e., common.NormalStore or common.PlasmaStore, and returns a
+// client connected to the session with the same options of this client. The
+// objects in the session are isolated from other sessions.
+func (i *IPCClient) NewSession(ctx context.Context, storeType string) (*IPCClient, error) {
+	if err := i.checkFeature(common.FeatureSessions, common.NEW_SESSION_REQUEST); err != nil {
+		return nil, err
+	}
+	var messageOut string
+	if err := common.WriteNewSessionRequest(storeType, &messageOut); err != nil {
+		return nil, err
+	}
+	var newSessionReply common.NewSessionReply
+	if err := i.doRequest(ctx, messageOut, common.NEW_SESSION_REPLY, &newSessionReply); err != nil {
+		return nil, err
+	}
+	i.mutex.Lock()
+	options := append(append([]Option(nil), i.options...), WithStoreType(storeType))
+	i.mutex.Unlock()
+	session := &IPCClient{}
+	if err := session.Connect(ctx, newSessionReply.SocketPath, options...); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// resetMmapTable forgets the fds received in the previous session, as
+// vineyard server tracks the sent fds per connection and sends them again in
+// the new session, which may be a different server instance as well. The
+// mapped memory is retired rather than unmapped, since it might still be used
+// by blobs of the previous session.
+func (i *IPCClient) resetMmapTable() {
+	i.refMutex.Lock()
+	defer i.refMutex.Unlock()
+	for _, entry := range i.mmapTable {
+		unix.Close(entry.clientFd)
+		if entry.roMapping != nil || entry.rwMapping != nil {
+			if i.retired == nil {
+				i.retired = make(map[int][]*MmapEntry)
+			}
+			i.retired[i.generation] = append(i.retired[i.generation], entry)
+		}
+	}
+	i.mmapTable = make(map[int]*MmapEntry)
+}
+
+// resetReferences forgets the references of the previous session, as they
+// have been released by vineyard server on disconnection. The blobs of the
+// previous session could still be dropped by their finalizers.
+func (i *IPCClient) resetReferences() {
+	i.refMutex.Lock()
+	defer i.refMutex.Unlock()
+	i.references = make(map[common.ObjectID]int)
+	i.unmapRetired(i.generation)
+	i.generation++
+}
+
+// unmapRetired unmaps the retired mappings of the generation if no blob of it
+// is alive, the caller must hold the refMutex.
+func (i *IPCClient) unmapRetired(generation int) {
+	if i.alive[generation] > 0 {
 		return
 	}
-	var buffer memory.Buffer
+	for _, entry := range i.retired[generation] {
+		if entry.roMapping != nil {
+			_ = unix.Munmap(entry.roMapping)
+		}
+		if entry.rwMapping != nil {
+			_ = unix.Munmap(entry.rwMapping)
+		}
+	}
+	delete(i.retired, generation)
+	delete(i.alive, generation)
+}
+
+func (i *IPCClient) addReferences(ids []common.ObjectID) int {
+	i.refMutex.Lock()
+	defer i.refMutex.Unlock()
+	if i.references == nil {
+		i.references = make(map[common.ObjectID]int)
+	}
+	for _, id := range ids {
+		i.references[id]++
+	}
+	i.addAlive(len(ids))
+	return i.generation
+}
+
+// pinMappings keeps the mappings of the current generation from being
+// unmapped, as the buffers created from them are not tracked.
+func (i *IPCClient) pinMappings() {
+	i.refMutex.Lock()
+	defer i.refMutex.Unlock()
+	i.addAlive(1)
+}
+
+// addAlive counts the alive references of the current generation, the caller
+// must hold the refMutex.
+func (i *IPCClient) addAlive(count int) {
+	if i.alive == nil {
+		i.alive = make(map[int]int)
+	}
+	i.alive[i.generation] += count
+}
+
+// dropReference drops a reference counted in the generation, and returns
+// whether it is the last one that should be released in vineyard server. The
+// retired mappings are unmapped once the last reference of a previous
+// generation is dropped.
+func (i *IPCClient) dropReference(id common.ObjectID, generation int) bool {
+	i.refMutex.Lock()
+	defer i.refMutex.Unlock()
+	if generation != i.generation {
+		if i.alive[generation] > 0 {
+			i.alive[generation]--
+			i.unmapRetired(generation)
+		}
+		return false
+	}
+	count, ok := i.references[id]
+	if !ok {
+		return false
+	}
+	i.alive[generation]--
+	if count > 1 {
+		i.references[id] = count - 1
+		return false
+	}
+	delete(i.references, id)
+	return true
+}
+
+// setReleaseFinalizer releases the blob once it is garbage collected, as the
+// blob owns its payload.
+func setReleaseFinalizer(i *IPCClient, blob *ds.Blob, generation int) {
+	id := blob.ID()
+	runtime.SetFinalizer(blob, func(*ds.Blob) {
+		if i.dropReference(id, generation) {
+			go func() {
+				_ = i.ClientBase.Release(context.Background(), id)
+			}()
+		}
+	})
+}
+
+// IncreaseReferenceCount adds references of this client to the blobs, each of
+// them should be released by Release.
+func (i *IPCClient) IncreaseReferenceCount(ctx context.Context, ids ...common.ObjectID) error {
+	if err := i.ClientBase.IncreaseReferenceCount(ctx, ids...); err != nil {
+		return err
+	}
+	i.addReferences(ids)
+	return nil
+}
+
+// Release drops a reference to the blob, which is added by GetBlobs,
+// GetMetaData or IncreaseReferenceCount, the reference in vineyard server is
+// released once all of them are dropped.
+func (i *IPCClient) Release(ctx context.Context, id common.ObjectID) error {
+	i.refMutex.Lock()
+	count := i.references[id]
+	if count > 0 {
+		i.alive[i.generation]--
+	}
+	if count > 1 {
+		i.references[id] = count - 1
+		i.refMutex.Unlock()
+		return nil
+	}
+	delete(i.references, id)
+	i.refMutex.Unlock()
+	return i.ClientBase.Release(ctx, id)
+}
+
+// CreateBlob allocates a blob of the given size in vineyard server, the
+// writer exposes the shared memory of the blob and no copy happens.
+func (i *IPCClient) CreateBlob(ctx context.Context, size int, blob *ds.BlobWriter) error {
+	var buffer []byte
 	var id common.ObjectID = common.InvalidObjectID()
 	var payload ds.Payload
-	i.CreateBuffer(size, &id, &payload, &buffer)
-	blob.Reset(id, payload, buffer)
+	if err := i.CreateBuffer(ctx, size, &id, &payload, &buffer); err != nil {
+		return err
+	}
+	blob.Reset(i, id, payload, buffer)
+	return nil
 }
 
-func (i *IPCClient) CreateBuffer(size int, id *common.ObjectID, payload *ds.Payload, buffer *memory.Buffer) error {
-	if i.connected == false {
-		return errors.New("ipc client is not connected")
-	}
+func (i *IPCClient) CreateBuffer(ctx context.Context, size int, id *common.ObjectID, payload *ds.Payload, buffer *[]byte) error {
 	var messageOut string
-	common.WriteCreateBufferRequest(size, &messageOut)
-
-	if err := i.DoWrite(messageOut); err != nil {
+	if err := common.WriteCreateBufferRequest(size, &messageOut); err != nil {
 		return err
 	}
-	var messageIn string
-	err := i.DoRead(&messageIn)
+	return i.roundTrip(ctx, func() error {
+		var createBufferReply common.CreateBufferReply
+		if err := i.request(messageOut, common.CREATE_BUFFER_REPLY, &createBufferReply); err != nil {
+			return err
+		}
+		*id = createBufferReply.ID
+		payload.ID = createBufferReply.ID
+		payload.StoreFd = createBufferReply.Created.StoreFd
+		payload.DataOffset = createBufferReply.Created.DataOffset
+		payload.DataSize = createBufferReply.Created.DataSize
+		payload.MapSize = createBufferReply.Created.MapSize
+
+		// the payload is mapped first as the fd follows the reply
+		var err error
+		*buffer, err = i.mmapPayload(&createBufferReply.Created, false)
+		if err == nil && size != payload.DataSize {
+			err = errors.New("data size not match")
+		}
+		if err != nil {
+			// the buffer is of no use, and is dropped rather than leaked
+			*buffer = nil
+			var dropMessage string
+			if common.WriteDropBufferRequest(createBufferReply.ID, &dropMessage) == nil {
+				_ = i.request(dropMessage, common.DROP_BUFFER_REPLY, nil)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// mmapPayload returns the memory of the payload, the shared memory is mapped
+// (and the fd is received if necessary) on demand. The caller must hold the
+// mutex, as the fd follows the reply on the connection. The mappings are
+// pinned, as the returned memory is not tracked by references.
+func (i *IPCClient) mmapPayload(payload *common.CreatedBuffer, readOnly bool) ([]byte, error) {
+	i.pinMappings()
+	return i.mmapTrackedPayload(payload, readOnly)
+}
+
+// mmapTrackedPayload is like mmapPayload, but the caller counts the
+// references to the returned memory.
+func (i *IPCClient) mmapTrackedPayload(payload *common.CreatedBuffer, readOnly bool) ([]byte, error) {
+	if payload.DataSize <= 0 {
+		return nil, nil
+	}
+	shared, err := i.MmapToClient(payload.StoreFd, int64(payload.MapSize), readOnly, true)
 	if err != nil {
+		return nil, err
+	}
+	if payload.DataOffset < 0 || payload.DataOffset+payload.DataSize > len(shared) {
+		return nil, fmt.Errorf("invalid payload of blob %s: out of the mapped region",
+			common.ObjectIDToString(payload.ID))
+	}
+	return shared[payload.DataOffset : payload.DataOffset+payload.DataSize], nil
+}
+
+// DropBuffer releases a blob that hasn't been sealed yet.
+func (i *IPCClient) DropBuffer(ctx context.Context, id common.ObjectID) error {
+	var messageOut string
+	if err := common.WriteDropBufferRequest(id, &messageOut); err != nil {
 		return err
 	}
-	fmt.Println("receive from vineyard create buffer is :", messageIn)
-	var createBufferReply common.CreateBufferReply
-	err = json.Unmarshal([]byte(messageIn), &createBufferReply)
+	return i.doRequest(ctx, messageOut, common.DROP_BUFFER_REPLY, nil)
+}
+
+func (i *IPCClient) GetBlob(ctx context.Context, id common.ObjectID) (*ds.Blob, error) {
+	blobs, err := i.GetBlobs(ctx, id)
 	if err != nil {
-		fmt.Println("create buffer reply json failed")
-		return err
+		return nil, err
 	}
-	*id = createBufferReply.ID // TODO: check whether two id is same
-	payload.ID = createBufferReply.ID
-	payload.StoreFd = createBufferReply.Created.StoreFd
-	payload.DataOffset = createBufferReply.Created.DataOffset
-	payload.DataSize = createBufferReply.Created.DataSize
-	payload.MapSize = createBufferReply.Created.MapSize
+	return blobs[0], nil
+}
 
-	if size != payload.DataSize {
-		return errors.New("data size not match")
+// GetBlobs returns the blobs in the same order of ids, the payload of blobs
+// are memory mapped from vineyard server as readonly and no copy happens.
+// Each blob holds a reference that should be released by Release, unless the
+// client is connected WithBlobFinalizer.
+func (i *IPCClient) GetBlobs(ctx context.Context, ids ...common.ObjectID) ([]*ds.Blob, error) {
+	blobs, generation, err := i.fetchBlobs(ctx, ids)
+	if err != nil || !i.blobFinalizer {
+		return blobs, err
 	}
+	finalized := make(map[*ds.Blob]bool)
+	for _, blob := range blobs {
+		if !finalized[blob] {
+			finalized[blob] = true
+			setReleaseFinalizer(i, blob, generation)
+		}
+	}
+	return blobs, nil
+}
 
-	var shared *uint8
-	if payload.DataSize > 0 {
-		i.MmapToClient(payload.StoreFd, int64(payload.MapSize), false, true, &shared)
-		//i.MmapToClient()
+// fetchBlobs gets the blobs and counts the references to them, the
+// generation of the references is returned as well.
+func (i *IPCClient) fetchBlobs(ctx context.Context, ids []common.ObjectID) ([]*ds.Blob, int, error) {
+	if len(ids) == 0 {
+		return nil, 0, nil
 	}
-	//fmt.Println(shared[0:1])
-	//buffer := memory.NewBufferBytes(shared[])
-	return nil
+	var messageOut string
+	if err := common.WriteGetBuffersRequest(ids, false, &messageOut); err != nil {
+		return nil, 0, err
+	}
+	var result []*ds.Blob
+	var generation int
+	err := i.roundTrip(ctx, func() (err error) {
+		if result, err = i.getBlobs(ids, messageOut); err != nil {
+			return err
+		}
+		fetched := make(map[common.ObjectID]bool)
+		for _, blob := range result {
+			fetched[blob.ID()] = true
+		}
+		fetchedIds := make([]common.ObjectID, 0, len(fetched))
+		for id := range fetched {
+			fetchedIds = append(fetchedIds, id)
+		}
+		generation = i.addReferences(fetchedIds)
+		return nil
+	})
+	return result, generation, err
 }
 
-func (i *IPCClient) MmapToClient(fd int, mapSize int64, readOnly bool, realign bool, ptr **uint8) error {
-	_, ok := i.mmapTable[fd]
-	if !ok {
-		file, err := i.conn.File()
+func (i *IPCClient) getBlobs(ids []common.ObjectID, messageOut string) ([]*ds.Blob, error) {
+	if err := i.DoWrite(messageOut); err != nil {
+		return nil, err
+	}
+	var messageIn string
+	if err := i.DoRead(&messageIn); err != nil {
+		return nil, err
+	}
+	var getBuffersReply common.GetBuffersReply
+	if err := common.ReadGetBuffersReply(messageIn, &getBuffersReply); err != nil {
+		return nil, err
+	}
+	if err := common.CheckReply(common.ReplyHeader{
+		Type:    getBuffersReply.Type,
+		Code:    getBuffersReply.Code,
+		Message: getBuffersReply.Message,
+	}, common.GET_BUFFERS_REQUEST, common.GET_BUFFERS_REPLY); err != nil {
+		return nil, err
+	}
+
+	// the server sends the fds that haven't been sent to this client in the
+	// order of payloads, they are received when mapping the payloads below.
+	if getBuffersReply.Fds != nil {
+		var fdsToRecv []int
+		dedup := make(map[int]bool)
+		for _, payload := range getBuffersReply.Payloads {
+			if _, ok := i.mmapTable[payload.StoreFd]; payload.DataSize > 0 && !ok && !dedup[payload.StoreFd] {
+				fdsToRecv = append(fdsToRecv, payload.StoreFd)
+				dedup[payload.StoreFd] = true
+			}
+		}
+		if !equalFds(fdsToRecv, getBuffersReply.Fds) {
+			return nil, fmt.Errorf("the fd set is not matched between client and server: %v, %v",
+				fdsToRecv, getBuffersReply.Fds)
+		}
+	}
+
+	blobs := make(map[common.ObjectID]*ds.Blob)
+	for index := range getBuffersReply.Payloads {
+		payload := &getBuffersReply.Payloads[index]
+		buffer, err := i.mmapTrackedPayload(payload, true)
+		if err != nil {
+			return nil, err
+		}
+		blobs[payload.ID] = ds.NewBlob(payload.ID, payload.DataSize, buffer)
+	}
+	result := make([]*ds.Blob, 0, len(ids))
+	for _, id := range ids {
+		blob, ok := blobs[id]
+		if !ok {
+			return nil, &common.Status{
+				Code:    common.KObjectNotExists,
+				Message: fmt.Sprintf("blob not exists: %s", common.ObjectIDToString(id)),
+				Request: common.GET_BUFFERS_REQUEST,
+			}
+		}
+		result = append(result, blob)
+	}
+	return result, nil
+}
+
+// GetMetaData gets the metadata of the object, and the payload of local
+// blobs that the object consists of are fetched as well, thus the object
+// could be resolved (e.g., by ds.ResolveArray) without further requests.
+// Each fetched blob holds a reference that should be released by Release,
+// regardless of WithBlobFinalizer.
+func (i *IPCClient) GetMetaData(ctx context.Context, id common.ObjectID, meta *ds.ObjectMeta, syncRemote bool) error {
+	if err := i.ClientBase.GetMetaData(ctx, id, meta, syncRemote); err != nil {
+		return err
+	}
+	ids := make([]common.ObjectID, 0)
+	for _, blobID := range meta.GetBufferSet().AllBufferIds() {
+		if blobID != common.EmptyBlobID() {
+			ids = append(ids, blobID)
+		}
+	}
+	blobs, _, err := i.fetchBlobs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, blob := range blobs {
+		buffer, err := blob.Buffer()
 		if err != nil {
-			fmt.Println("Get connection file")
 			return err
 		}
-		clientFd := C.recv_fd(C.int(file.Fd()))
-		if clientFd <= 0 {
-			return errors.New("receive client fd error")
+		if err := meta.GetBufferSet().SetBuffer(blob.ID(), buffer); err != nil {
+			return err
 		}
-		newEntry := MmapEntry{int(clientFd), mapSize, readOnly, realign, nil, nil}
+	}
+	return nil
+}
 
-		if readOnly {
-			newEntry.MapReadOnly()
-		} else {
-			newEntry.MapReadWrite()
+// Migrate pulls the object from the vineyard instance it lives in to the
+// instance the client connects to, and returns the id of the local object.
+// The object is returned as is if it is already local, and global objects
+// are not supported.
+func (i *IPCClient) Migrate(ctx context.Context, id common.ObjectID) (common.ObjectID, error) {
+	var meta ds.ObjectMeta
+	if err := i.ClientBase.GetMetaData(ctx, id, &meta, true); err != nil {
+		return common.InvalidObjectID(), err
+	}
+	if meta.GetInstanceId() == i.InstanceID() {
+		return id, nil
+	}
+	if meta.IsGlobal() {
+		return common.InvalidObjectID(), &common.Status{
+			Code:    common.KInvalid,
+			Message: "migration on global object is not supported",
+			Request: common.MIGRATE_OBJECT_REQUEST,
 		}
-		i.mmapTable[fd] = newEntry
 	}
+	var messageOut string
+	if err := common.WriteMigrateObjectRequest(id, &messageOut); err != nil {
+		return common.InvalidObjectID(), err
+	}
+	var migrateObjectReply common.MigrateObjectReply
+	if err := i.doRequest(ctx, messageOut, common.MIGRATE_OBJECT_REPLY, &migrateObjectReply); err != nil {
+		return common.InvalidObjectID(), err
+	}
+	return migrateObjectReply.ObjectID, nil
+}
 
-	// TODO: set entry to read only
-	//if readOnly {
-	//} else {
-	//}
-	return nil
+// GetObject gets the metadata and local buffers of the object, and resolves
+// it by the resolver registered for its typename, see ds.RegisterResolver.
+func (i *IPCClient) GetObject(ctx context.Context, id common.ObjectID) (ds.Object, error) {
+	var meta ds.ObjectMeta
+	if err := i.GetMetaData(ctx, id, &meta, false); err != nil {
+		return nil, err
+	}
+	return ds.ResolveObject(&meta)
+}
+
+// Seal marks the blob as sealed in vineyard server, a sealed blob is immutable
+// and visible to other clients.
+func (i *IPCClient) Seal(ctx context.Context, id common.ObjectID) error {
+	var messageOut string
+	if err := common.WriteSealRequest(id, &messageOut); err != nil {
+		return err
+	}
+	return i.doRequest(ctx, messageOut, common.SEAL_REPLY, nil)
+}
+
+// Evict spills the blobs (or the blobs of the objects) to the disk and
+// releases their memory, if vineyard server is configured with spilling.
+// Blobs that are in use or pinned are not evicted.
+func (i *IPCClient) Evict(ctx context.Context, ids ...common.ObjectID) error {
+	var messageOut string
+	if err := common.WriteEvictRequest(ids, &messageOut); err != nil {
+		return err
+	}
+	return i.doRequest(ctx, messageOut, common.EVICT_REPLY, nil)
+}
+
+// Load reloads the spilled blobs (or the blobs of the objects) into memory,
+// e.g., to prefetch objects before they are used. Pinned blobs won't be
+// spilled again until they are unpinned by Unpin.
+func (i *IPCClient) Load(ctx context.Context, pin bool, ids ...common.ObjectID) error {
+	var messageOut string
+	if err := common.WriteLoadRequest(ids, pin, &messageOut); err != nil {
+		return err
+	}
+	return i.doRequest(ctx, messageOut, common.LOAD_REPLY, nil)
+}
+
+// Unpin allows the blobs pinned by Load to be spilled again.
+func (i *IPCClient) Unpin(ctx context.Context, ids ...common.ObjectID) error {
+	var messageOut string
+	if err := common.WriteUnpinRequest(ids, &messageOut); err != nil {
+		return err
+	}
+	return i.doRequest(ctx, messageOut, common.UNPIN_REPLY, nil)
+}
+
+// IsSpilled returns whether the blob has been spilled to the disk.
+func (i *IPCClient) IsSpilled(ctx context.Context, id common.ObjectID) (bool, error) {
+	var messageOut string
+	if err := common.WriteIsSpilledRequest(id, &messageOut); err != nil {
+		return false, err
+	}
+	var isSpilledReply common.IsSpilledReply
+	if err := i.doRequest(ctx, messageOut, common.IS_SPILLED_REPLY, &isSpilledReply); err != nil {
+		return false, err
+	}
+	return isSpilledReply.IsSpilled, nil
+}
+
+// IsInUse returns whether the blob is referenced by clients, blobs in use
+// are never spilled.
+func (i *IPCClient) IsInUse(ctx context.Context, id common.ObjectID) (bool, error) {
+	var messageOut string
+	if err := common.WriteIsInUseRequest(id, &messageOut); err != nil {
+		return false, err
+	}
+	var isInUseReply common.IsInUseReply
+	if err := i.doRequest(ctx, messageOut, common.IS_IN_USE_REPLY, &isInUseReply); err != nil {
+		return false, err
+	}
+	return isInUseReply.IsInUse, nil
+}
+
+func equalFds(fds1 []int, fds2 []int) bool {
+	if len(fds1) != len(fds2) {
+		return false
+	}
+	for index := range fds1 {
+		if fds1[index] != fds2[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// MmapToClient maps the memory of the given store fd into the client, the fd
+// is received from the server when it is seen for the first time and the
+// mapping is reused afterwards. It must be called right after the reply that
+// carries the fd, with the connection held by the caller.
+func (i *IPCClient) MmapToClient(fd int, mapSize int64, readOnly bool, realign bool) ([]byte, error) {
+	entry, ok := i.mmapTable[fd]
+	if !ok {
+		clientFd, err := RecvFd(i.conn)
+		if err != nil {
+			// the following messages cannot be parsed correctly anymore
+			i.closeConn()
+			return nil, &connError{sent: true, err: err}
+		}
+		entry = &MmapEntry{clientFd, mapSize, readOnly, realign, nil, nil}
+		i.mmapTable[fd] = entry
+	}
+	if readOnly {
+		return entry.MapReadOnly()
+	}
+	return entry.MapReadWrite()
 }
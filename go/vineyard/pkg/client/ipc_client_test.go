@@ -16,49 +16,64 @@ limitations under the License.
 package vineyard
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/apache/arrow/go/arrow"
 	"github.com/apache/arrow/go/arrow/array"
 	"github.com/apache/arrow/go/arrow/memory"
 	vineyard "github.com/v6d-io/v6d/go/vineyard/pkg/client/ds"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/client/vineyardtest"
 	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
 )
 
 func TestIPCServer_Connect(t *testing.T) {
-	ipcAddr := "/var/run/vineyard.sock"
+	ipcAddr := newTestServer(t).IPCSocket()
 	ipcServer := IPCClient{}
-	err := ipcServer.Connect(ipcAddr)
+	err := ipcServer.Connect(context.Background(), ipcAddr)
 	if err != nil {
 		t.Error("connect to ipc server failed", err)
 	}
-	err = ipcServer.Disconnect()
+	err = ipcServer.Disconnect(context.Background())
 	if err != nil {
 		t.Error("disconnect ipc server failed", err.Error())
 	}
 }
 
 func TestIPCClient_GetName(t *testing.T) {
-	ipcAddr := "/var/run/vineyard.sock"
+	ipcAddr := newTestServer(t).IPCSocket()
 	name := "test_name"
 	nameNoExist := "undefined_name"
 	ipcServer := IPCClient{}
-	err := ipcServer.Connect(ipcAddr)
+	err := ipcServer.Connect(context.Background(), ipcAddr)
 	if err != nil {
 		t.Error("connect to ipc server failed", err)
 	}
-	var id1 common.ObjectID = common.GenerateObjectID()
-	if err := ipcServer.PutName(id1, name); err != nil {
-		if putErr, ok := err.(*common.ReplyError); ok {
+	var meta vineyard.ObjectMeta
+	meta.Init()
+	meta.SetTypeName("vineyard::Scalar<int>")
+	meta.AddKeyValue("value_", 1)
+	var id1 common.ObjectID
+	if err := ipcServer.CreateMetaData(context.Background(), &meta, &id1); err != nil {
+		t.Fatal("create metadata failed", err)
+	}
+	if err := ipcServer.PutName(context.Background(), id1, name); err != nil {
+		var putErr *common.Status
+		if errors.As(err, &putErr) {
 			t.Log("get name return code", putErr.Code)
 		} else {
 			t.Error("get name failed", err)
 		}
 	}
 	var id2 common.ObjectID
-	if err := ipcServer.GetName(name, false, &id2); err != nil {
-		if getErr, ok := err.(*common.ReplyError); ok {
+	if err := ipcServer.GetName(context.Background(), name, false, &id2); err != nil {
+		var getErr *common.Status
+		if errors.As(err, &getErr) {
 			if getErr.Code == common.KObjectNotExists {
 				t.Log("get object not exist")
 			}
@@ -72,8 +87,9 @@ func TestIPCClient_GetName(t *testing.T) {
 	t.Log("put name and get name success!")
 
 	var id3 common.ObjectID
-	if err := ipcServer.GetName(nameNoExist, false, &id3); err != nil {
-		if getErr, ok := err.(*common.ReplyError); ok {
+	if err := ipcServer.GetName(context.Background(), nameNoExist, false, &id3); err != nil {
+		var getErr *common.Status
+		if errors.As(err, &getErr) {
 			if getErr.Code == common.KObjectNotExists {
 				t.Log("get object not exist")
 			}
@@ -83,8 +99,9 @@ func TestIPCClient_GetName(t *testing.T) {
 	}
 	t.Log("get no exist name test success")
 
-	if err := ipcServer.DropName(name); err != nil {
-		if dropErr, ok := err.(*common.ReplyError); ok {
+	if err := ipcServer.DropName(context.Background(), name); err != nil {
+		var dropErr *common.Status
+		if errors.As(err, &dropErr) {
 			if dropErr.Code == common.KObjectNotExists {
 				t.Log("drop object not exist")
 			}
@@ -94,8 +111,9 @@ func TestIPCClient_GetName(t *testing.T) {
 	}
 	t.Log("drop name success")
 
-	if err := ipcServer.GetName(name, false, &id1); err != nil {
-		if getErr, ok := err.(*common.ReplyError); ok {
+	if err := ipcServer.GetName(context.Background(), name, false, &id1); err != nil {
+		var getErr *common.Status
+		if errors.As(err, &getErr) {
 			if getErr.Code == common.KObjectNotExists {
 				t.Log("get object not exist")
 			}
@@ -104,14 +122,398 @@ func TestIPCClient_GetName(t *testing.T) {
 		}
 	}
 
-	err = ipcServer.Disconnect()
+	err = ipcServer.Disconnect(context.Background())
 	if err != nil {
 		t.Error("disconnect ipc server failed", err.Error())
 	}
 }
 
-// need root privilege to run
-// TODO: unfinished
+func TestIPCClient_Reconnect(t *testing.T) {
+	server := newTestServer(t)
+	policy := ReconnectPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	var client IPCClient
+	if err := client.Connect(context.Background(), server.IPCSocket(), WithReconnectPolicy(policy)); err != nil {
+		t.Fatal("connect failed", err)
+	}
+	blob := newTestBlob(t, &client, "hello")
+	if _, err := client.GetBlob(context.Background(), blob); err != nil {
+		t.Fatal("get blob failed", err)
+	}
+	scalar := newTestScalar(t, &client, 1)
+	if err := client.PutName(context.Background(), scalar, "name"); err != nil {
+		t.Fatal("put name failed", err)
+	}
+	if len(client.mmapTable) != 1 || len(client.references) != 1 {
+		t.Fatal("unexpected session", len(client.mmapTable), len(client.references))
+	}
+
+	server.DisconnectAll()
+	// the request that has been sent when the connection is lost fails, and the
+	// client reconnects on the next request
+	var id common.ObjectID
+	err := client.GetName(context.Background(), "name", false, &id)
+	if err != nil && !errors.Is(err, ErrDisconnected) {
+		t.Fatal("expect ErrDisconnected", err)
+	}
+	if err != nil {
+		err = client.GetName(context.Background(), "name", false, &id)
+	}
+	if err != nil || id != scalar {
+		t.Fatal("the request should succeed after reconnecting", err, id)
+	}
+	if len(client.mmapTable) != 0 || len(client.references) != 0 {
+		t.Error("the session should be reset after reconnecting", len(client.mmapTable), len(client.references))
+	}
+
+	if err := client.Disconnect(context.Background()); err != nil {
+		t.Fatal("disconnect failed", err)
+	}
+	if err := client.GetName(context.Background(), "name", false, &id); !errors.Is(err, ErrDisconnected) {
+		t.Error("the client shouldn't reconnect after disconnecting", err)
+	}
+}
+
+func TestIPCClient_WithoutReconnect(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, WithoutReconnect())
+	server.DisconnectAll()
+	var id common.ObjectID
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := client.GetName(context.Background(), "name", false, &id); !errors.Is(err, ErrDisconnected) {
+			t.Error("expect ErrDisconnected", err)
+		}
+	}
+}
+
+func TestIPCClient_RetiredMappings(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	id := newTestBlob(t, newTestClient(t, server), "hello")
+	client := newTestClient(t, server, WithBlobFinalizer())
+	blob, err := client.GetBlob(ctx, id)
+	if err != nil {
+		t.Fatal("get blob failed", err)
+	}
+
+	// the mappings are kept after reconnecting, as the blob is still alive
+	server.DisconnectAll()
+	if _, err := client.Exists(ctx, id); err != nil {
+		if _, err := client.Exists(ctx, id); err != nil {
+			t.Fatal("the request should succeed after reconnecting", err)
+		}
+	}
+	if data, err := blob.Data(); err != nil || string(data) != "hello" {
+		t.Fatal("the blob should be accessible after reconnecting", string(data), err)
+	}
+	retired := func() int {
+		client.refMutex.Lock()
+		defer client.refMutex.Unlock()
+		return len(client.retired)
+	}
+	if retired() != 1 {
+		t.Fatal("the mappings should be retired", retired())
+	}
+
+	// and unmapped once the blob is garbage collected
+	blob = nil
+	deadline := time.Now().Add(5 * time.Second)
+	for retired() != 0 && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if retired() != 0 {
+		t.Error("the retired mappings should be unmapped")
+	}
+}
+
+func TestIPCClient_ServerVersion(t *testing.T) {
+	server := newTestServer(t, vineyardtest.WithVersion("0.2.4"))
+	client := newTestClient(t, server)
+	if version := client.ServerVersion(); version.String() != "0.2.4" {
+		t.Error("unexpected server version", version)
+	}
+	if !client.Supports(common.FeatureRemoteBuffers) || client.Supports(common.FeatureSessions) {
+		t.Error("unexpected features of server 0.2.4")
+	}
+
+	// the server has been upgraded after reconnecting
+	server.Upgrade("0.11.0")
+	if _, err := client.Exists(context.Background(), 0x10); err != nil {
+		client.Exists(context.Background(), 0x10)
+	}
+	if version := client.ServerVersion(); version.String() != "0.11.0" || !client.Supports(common.FeatureSessions) {
+		t.Error("unexpected server version after reconnecting", version)
+	}
+}
+
+func TestIPCClient_IncompatibleServer(t *testing.T) {
+	server := newTestServer(t, vineyardtest.WithVersion("0.1.9"))
+	var client IPCClient
+	err := client.Connect(context.Background(), server.IPCSocket())
+	if !errors.Is(err, ErrIncompatibleServer) {
+		t.Fatal("expect ErrIncompatibleServer", err)
+	}
+	if client.connected {
+		t.Error("the client shouldn't be connected to an incompatible server")
+	}
+}
+
+func TestIPCClient_Release(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+	ctx := context.Background()
+	blob := newTestBlob(t, client, "hello")
+	isInUse := func() bool {
+		inUse, err := client.IsInUse(ctx, blob)
+		if err != nil {
+			t.Fatal("is in use failed", err)
+		}
+		return inUse
+	}
+
+	// the reference in server is released with the last blob
+	for attempt := 0; attempt < 2; attempt++ {
+		if _, err := client.GetBlob(ctx, blob); err != nil {
+			t.Fatal("get blob failed", err)
+		}
+	}
+	for _, expected := range []bool{true, false} {
+		if err := client.Release(ctx, blob); err != nil {
+			t.Fatal("release failed", err)
+		}
+		if isInUse() != expected {
+			t.Fatal("unexpected reference in server", expected)
+		}
+	}
+
+	finalized := newTestClient(t, server, WithBlobFinalizer())
+	if _, err := finalized.GetBlob(ctx, blob); err != nil {
+		t.Fatal("get blob failed", err)
+	}
+	if !isInUse() {
+		t.Fatal("the blob should be referenced")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for isInUse() && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if isInUse() {
+		t.Error("the blob should be released once it is garbage collected")
+	}
+
+	// the buffers of metadata are released explicitly even with the finalizer
+	var meta vineyard.ObjectMeta
+	if err := finalized.GetMetaData(ctx, blob, &meta, false); err != nil {
+		t.Fatal("get metadata failed", err)
+	}
+	runtime.GC()
+	if !isInUse() {
+		t.Fatal("the buffer of metadata shouldn't be released by the finalizer")
+	}
+	if err := finalized.Release(ctx, blob); err != nil || isInUse() {
+		t.Error("the buffer of metadata should be released explicitly", err)
+	}
+}
+
+func TestIPCClient_Session(t *testing.T) {
+	server := newTestServer(t, vineyardtest.WithCredentials("user", "secret"))
+	ctx := context.Background()
+	var anonymous IPCClient
+	if err := anonymous.Connect(ctx, server.IPCSocket()); !errors.Is(err, common.ErrConnectionError) {
+		t.Error("the client without credentials should be rejected", err)
+	}
+
+	client := newTestClient(t, server, WithCredentials("user", "secret"))
+	if err := client.DeleteSession(ctx); err == nil {
+		t.Error("the root session shouldn't be deleted")
+	}
+	session, err := client.NewSession(ctx, common.PlasmaStore)
+	if err != nil {
+		t.Fatal("new session failed", err)
+	}
+	if session.SessionID() != 1 || client.SessionID() != common.RootSessionID() {
+		t.Error("unexpected sessions", session.SessionID(), client.SessionID())
+	}
+	var mismatched IPCClient
+	err = mismatched.Connect(ctx, session.ipcSocket, WithCredentials("user", "secret"))
+	if err == nil || !strings.Contains(err.Error(), "mismatched store type") {
+		t.Error("expect mismatched store type", err)
+	}
+
+	if err := session.DeleteSession(ctx); err != nil {
+		t.Fatal("delete session failed", err)
+	}
+	var id common.ObjectID
+	if err := session.GetName(ctx, "name", false, &id); !errors.Is(err, ErrDisconnected) {
+		t.Error("the client should be disconnected after deleting the session", err)
+	}
+}
+
+func TestIPCClient_Migrate(t *testing.T) {
+	servers, err := vineyardtest.NewCluster(2)
+	if err != nil {
+		t.Fatal("start vineyard cluster failed", err)
+	}
+	for _, server := range servers {
+		server := server
+		t.Cleanup(func() { server.Close() })
+	}
+	ctx := context.Background()
+	clients := make([]*IPCClient, len(servers))
+	for index, server := range servers {
+		clients[index] = &IPCClient{}
+		if err := clients[index].Connect(ctx, server.IPCSocket()); err != nil {
+			t.Fatal("connect failed", err)
+		}
+		defer clients[index].Disconnect(ctx)
+	}
+
+	// the tensor lives in instance 1
+	meta, err := vineyard.BuildTensor(ctx, clients[1], []int64{1, 2, 3}, []int64{3})
+	if err != nil {
+		t.Fatal("build tensor failed", err)
+	}
+	if err := clients[1].Persist(ctx, meta.GetId()); err != nil {
+		t.Fatal("persist tensor failed", err)
+	}
+	if migrated, err := clients[1].Migrate(ctx, meta.GetId()); err != nil || migrated != meta.GetId() {
+		t.Error("the local object should not be migrated", migrated, err)
+	}
+	migrated, err := clients[0].Migrate(ctx, meta.GetId())
+	if err != nil || migrated == meta.GetId() {
+		t.Fatal("migrate failed", migrated, err)
+	}
+	if persist, err := clients[0].IfPersist(ctx, migrated); err != nil || !persist {
+		t.Error("the migrated object should be persisted", err)
+	}
+	object, err := clients[0].GetObject(ctx, migrated)
+	if err != nil {
+		t.Fatal("get migrated object failed", err)
+	}
+	values, err := vineyard.TensorValues[int64](object.(*vineyard.Tensor))
+	if err != nil || fmt.Sprint(values) != "[1 2 3]" || !object.IsLocal() {
+		t.Error("unexpected migrated tensor", values, err)
+	}
+
+	var global vineyard.ObjectMeta
+	global.Init()
+	global.SetTypeName("vineyard::ObjectSet")
+	global.SetGlobal(true)
+	global.AddKeyValue("num_of_objects", 0)
+	var id common.ObjectID
+	if err := clients[1].CreateMetaData(ctx, &global, &id); err != nil {
+		t.Fatal("create global object failed", err)
+	}
+	if err := clients[1].Persist(ctx, id); err != nil {
+		t.Fatal("persist global object failed", err)
+	}
+	if _, err := clients[0].Migrate(ctx, id); err == nil {
+		t.Error("the global object should not be migrated")
+	}
+}
+
+func TestIPCClient_GetBlobs(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+	ctx := context.Background()
+	ids := []common.ObjectID{newTestBlob(t, client, "vineyard"), newTestBlob(t, client, "hello"), common.EmptyBlobID()}
+
+	// the blobs are fetched by another client, which receives the fd
+	var reader IPCClient
+	if err := reader.Connect(ctx, server.IPCSocket()); err != nil {
+		t.Fatal("connect failed", err)
+	}
+	defer reader.Disconnect(ctx)
+	blobs, err := reader.GetBlobs(ctx, ids...)
+	if err != nil {
+		t.Fatal("get blobs failed", err)
+	}
+	expected := []string{"vineyard", "hello", ""}
+	for index, blob := range blobs {
+		data, err := blob.Data()
+		if err != nil || string(data) != expected[index] {
+			t.Error("unexpected blob data", string(data), err)
+		}
+	}
+	buffer, err := blobs[0].Buffer()
+	if err != nil || string(buffer.Bytes()) != "vineyard" {
+		t.Error("unexpected arrow buffer of blob", err)
+	}
+
+	// the mmapped memory is reused in later calls
+	blob, err := reader.GetBlob(ctx, ids[1])
+	if err != nil {
+		t.Fatal("get blob failed", err)
+	}
+	if data, _ := blob.Data(); string(data) != "hello" {
+		t.Error("unexpected blob data", string(data))
+	}
+	if len(reader.mmapTable) != 1 {
+		t.Error("the mmap table should be reused", len(reader.mmapTable))
+	}
+
+	if _, err := reader.GetBlob(ctx, 0x8000000000000010); err == nil {
+		t.Error("get non-existing blob should fail")
+	}
+}
+
+func TestIPCClient_BlobWriter(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+	ctx := context.Background()
+
+	var writer vineyard.BlobWriter
+	if err := client.CreateBlob(ctx, 16, &writer); err != nil {
+		t.Fatal("create blob failed", err)
+	}
+	if writer.Size() != 16 || len(writer.Buf()) != 16 {
+		t.Fatal("unexpected size of blob writer", writer.Size())
+	}
+	if _, err := fmt.Fprint(&writer, "hello, "); err != nil {
+		t.Fatal("write blob failed", err)
+	}
+	if _, err := writer.Write([]byte("vineyard!")); err != nil {
+		t.Fatal("write blob failed", err)
+	}
+	if n, err := writer.Write([]byte("overflow")); err == nil || n != 0 {
+		t.Error("write beyond the blob size should fail", n)
+	}
+	blob, err := writer.Seal(ctx)
+	if err != nil {
+		t.Fatal("seal blob failed", err)
+	}
+	if data, _ := blob.Data(); blob.ID() != writer.ID || string(data) != "hello, vineyard!" {
+		t.Error("unexpected sealed blob", blob.ID(), string(data))
+	}
+	if err := writer.Abort(ctx); err == nil {
+		t.Error("abort a sealed blob should fail")
+	}
+
+	var aborted vineyard.BlobWriter
+	if err := client.CreateBlob(ctx, 8, &aborted); err != nil {
+		t.Fatal("create blob failed", err)
+	}
+	if err := aborted.Abort(ctx); err != nil {
+		t.Fatal("abort blob failed", err)
+	}
+
+	// the payload lives in the shared memory, and the dropped blobs are gone
+	var reader IPCClient
+	if err := reader.Connect(ctx, server.IPCSocket()); err != nil {
+		t.Fatal("connect failed", err)
+	}
+	defer reader.Disconnect(ctx)
+	if blob, err := reader.GetBlob(ctx, writer.ID); err != nil {
+		t.Error("get the sealed blob failed", err)
+	} else if data, _ := blob.Data(); string(data) != "hello, vineyard!" {
+		t.Error("the payload is not written to the shared memory", string(data))
+	}
+	if exists, err := reader.Exists(ctx, aborted.ID); err != nil || exists {
+		t.Error("the blob should be dropped", common.ObjectIDToString(aborted.ID), err)
+	}
+}
+
 func TestIPCClient_ArrowDataStructure(t *testing.T) {
 	pool := memory.NewGoAllocator()
 
@@ -142,6 +544,7 @@ func TestIPCClient_ArrowDataStructure(t *testing.T) {
 	vb.Append(8)
 
 	lb.AppendNull()
+	vb.AppendValues([]int64{-1, -1, -1}, nil)
 
 	arr := lb.NewArray().(*array.FixedSizeList)
 	defer arr.Release()
@@ -151,19 +554,38 @@ func TestIPCClient_ArrowDataStructure(t *testing.T) {
 	fmt.Printf("Type()    = %v\n", arr.DataType())
 	fmt.Printf("List      = %v\n", arr)
 
-	ipcAddr := "/var/run/vineyard.sock"
+	ipcAddr := newTestServer(t).IPCSocket()
 	ipcClient := IPCClient{}
-	err := ipcClient.Connect(ipcAddr)
+	err := ipcClient.Connect(context.Background(), ipcAddr)
 	if err != nil {
 		t.Error("connect to ipc server failed", err)
 	}
 
-	var array vineyard.ArrayBuilder
-	array.Init(&ipcClient, arr)
-	array.Seal()
-	// TODO: how to get array's id
+	var builder vineyard.ArrayBuilder
+	builder.Init(&ipcClient, arr)
+	if err := builder.Seal(context.Background()); err != nil {
+		t.Fatal("seal array failed", err)
+	}
+	if err := ipcClient.Persist(context.Background(), builder.Id()); err != nil {
+		t.Fatal("persist array failed", err)
+	}
+	if persist, err := ipcClient.IfPersist(context.Background(), builder.Id()); err != nil || !persist {
+		t.Error("the array should be persisted", err)
+	}
 
-	ipcClient.Persist(array.Id())
+	object, err := ipcClient.GetObject(context.Background(), builder.Id())
+	if err != nil {
+		t.Fatal("get array failed", err)
+	}
+	resolved, ok := object.(*vineyard.ArrowArray)
+	if !ok {
+		t.Fatalf("expect an arrow array, but got %T", object)
+	}
+	// the null bitmap of lists is not kept by vineyard::FixedSizeListArray
+	values := resolved.Array.(*array.FixedSizeList).ListValues()
+	if resolved.Array.Len() != arr.Len() || !array.ArrayEqual(values, arr.ListValues()) {
+		t.Error("unexpected array", resolved.Array)
+	}
 }
 
 func TestJSON(t *testing.T) {
@@ -174,3 +596,137 @@ func TestJSON(t *testing.T) {
 	}
 	fmt.Println(a["1"])
 }
+
+func TestIPCClient_Spill(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+	ctx := context.Background()
+	blobs := []common.ObjectID{newTestBlob(t, client, "hello"), newTestBlob(t, client, "vineyard")}
+	isSpilled := func(id common.ObjectID) bool {
+		spilled, err := client.IsSpilled(ctx, id)
+		if err != nil {
+			t.Fatal("is spilled failed", err)
+		}
+		return spilled
+	}
+	if err := client.Evict(ctx, blobs...); err != nil || !isSpilled(blobs[0]) || !isSpilled(blobs[1]) {
+		t.Fatal("the blobs should be spilled", err)
+	}
+	if err := client.Load(ctx, true, blobs[0]); err != nil || isSpilled(blobs[0]) {
+		t.Fatal("the blob should be loaded", err)
+	}
+	if err := client.Evict(ctx, blobs[0]); err != nil || isSpilled(blobs[0]) {
+		t.Error("the pinned blob shouldn't be spilled", err)
+	}
+	if err := client.Unpin(ctx, blobs[0]); err != nil {
+		t.Fatal("unpin failed", err)
+	}
+	if err := client.Evict(ctx, blobs[0]); err != nil || !isSpilled(blobs[0]) {
+		t.Error("the unpinned blob should be spilled", err)
+	}
+
+	// the blobs in use are reloaded and never spilled
+	if _, err := client.GetBlob(ctx, blobs[1]); err != nil || isSpilled(blobs[1]) {
+		t.Fatal("the blob should be reloaded once it is got", err)
+	}
+	if inUse, err := client.IsInUse(ctx, blobs[1]); err != nil || !inUse {
+		t.Error("the blob should be in use", err)
+	}
+	if err := client.Evict(ctx, blobs[1]); err != nil || isSpilled(blobs[1]) {
+		t.Error("the blob in use shouldn't be spilled", err)
+	}
+	if _, err := client.IsSpilled(ctx, 0x8000000000000010); !errors.Is(err, common.ErrObjectNotExists) {
+		t.Error("expect object not exists, but got", err)
+	}
+}
+
+func TestIPCClient_TestServer(t *testing.T) {
+	server := newTestServer(t, vineyardtest.WithInstanceID(3))
+	ctx := context.Background()
+	var client IPCClient
+	if err := client.Connect(ctx, server.IPCSocket()); err != nil {
+		t.Fatal("connect failed", err)
+	}
+	defer client.Disconnect(ctx)
+	if client.InstanceID() != 3 || client.ServerVersion().String() != vineyardtest.DefaultVersion {
+		t.Error("unexpected instance", client.InstanceID(), client.ServerVersion())
+	}
+
+	meta, err := vineyard.BuildTensor(ctx, &client, []int64{1, 2, 3, 4}, []int64{2, 2})
+	if err != nil {
+		t.Fatal("build tensor failed", err)
+	}
+	object, err := client.GetObject(ctx, meta.GetId())
+	if err != nil {
+		t.Fatal("get tensor failed", err)
+	}
+	values, err := vineyard.TensorValues[int64](object.(*vineyard.Tensor))
+	if err != nil || fmt.Sprint(values) != "[1 2 3 4]" {
+		t.Error("unexpected values of tensor", values, err)
+	}
+	metas, err := client.ListObjects(ctx, "vineyard::Tensor<*>", false, 10)
+	if err != nil || len(metas) != 1 || metas[0].GetId() != meta.GetId() {
+		t.Error("unexpected listed objects", metas, err)
+	}
+
+	// the payload is fetched over rpc as well
+	buffer, err := meta.GetMember("buffer_")
+	if err != nil {
+		t.Fatal("get buffer of tensor failed", err)
+	}
+	var rpcClient RPCClient
+	if err := rpcClient.Connect(ctx, server.RPCEndpoint()); err != nil {
+		t.Fatal("connect over rpc failed", err)
+	}
+	defer rpcClient.Disconnect(ctx)
+	blobs, err := rpcClient.GetRemoteBlobs(ctx, buffer.GetId())
+	if err != nil {
+		t.Fatal("get remote blobs failed", err)
+	}
+	if data, _ := blobs[0].Data(); len(data) != 32 || data[8] != 2 {
+		t.Error("unexpected payload of remote blob", data)
+	}
+
+	if err := client.Delete(ctx, []common.ObjectID{meta.GetId()}, DeleteOptions{Deep: true}); err != nil {
+		t.Fatal("delete failed", err)
+	}
+	for _, id := range []common.ObjectID{meta.GetId(), buffer.GetId()} {
+		if exists, err := client.Exists(ctx, id); err != nil || exists {
+			t.Error("the object should be deleted", common.ObjectIDToString(id), err)
+		}
+	}
+}
+
+func TestIPCClient_InjectedFaults(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	policy := ReconnectPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	var client IPCClient
+	if err := client.Connect(ctx, server.IPCSocket(), WithReconnectPolicy(policy)); err != nil {
+		t.Fatal("connect failed", err)
+	}
+	defer client.Disconnect(ctx)
+
+	server.Inject(common.EXISTS_REQUEST, vineyardtest.Fault{Code: common.KEtcdError, Message: "etcd is down", Times: 1})
+	if _, err := client.Exists(ctx, 0x10); !errors.Is(err, common.ErrEtcdError) {
+		t.Error("expect the injected error, but got", err)
+	}
+	if exists, err := client.Exists(ctx, 0x10); err != nil || exists {
+		t.Error("the fault should only apply once", err)
+	}
+
+	server.Inject(common.EXISTS_REQUEST, vineyardtest.Fault{Latency: 200 * time.Millisecond, Times: 1})
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.Exists(timeout, 0x10); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("expect the request to time out, but got", err)
+	}
+
+	server.Inject(common.EXISTS_REQUEST, vineyardtest.Fault{Disconnect: true, Times: 1})
+	if _, err := client.Exists(ctx, 0x10); !errors.Is(err, ErrDisconnected) {
+		t.Error("expect ErrDisconnected, but got", err)
+	}
+	if _, err := client.Exists(ctx, 0x10); err != nil {
+		t.Error("the client should reconnect", err)
+	}
+}
@@ -0,0 +1,191 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vineyard
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/client/ds"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+// plasmaPollInterval is the interval to get the buffers that haven't been
+// sealed again in PlasmaClient.Get.
+const plasmaPollInterval = 10 * time.Millisecond
+
+// PlasmaClient works with the plasma-compatible bulk store of vineyard
+// server, where buffers are keyed by the 20-byte ids of Apache Arrow Plasma,
+// rather than the object ids allocated by vineyard server.
+type PlasmaClient struct {
+	ipc IPCClient
+}
+
+// Connect connects to the IPC socket of vineyard server, and registers the
+// client to the plasma store. It fails if the store of the server (or the
+// session) is not plasma.
+func (p *PlasmaClient) Connect(ctx context.Context, ipcSocket string, options ...Option) error {
+	options = append(options[:len(options):len(options)], WithStoreType(common.PlasmaStore))
+	return p.ipc.Connect(ctx, ipcSocket, options...)
+}
+
+func (p *PlasmaClient) Disconnect(ctx context.Context) error {
+	return p.ipc.Disconnect(ctx)
+}
+
+func (p *PlasmaClient) InstanceID() common.InstanceID {
+	return p.ipc.InstanceID()
+}
+
+// Create allocates a buffer of the given size keyed by the id, the returned
+// memory is shared with vineyard server and could be written until the
+// buffer is sealed. The creator holds a reference of the buffer, which
+// should be released once it is sealed.
+func (p *PlasmaClient) Create(ctx context.Context, id common.PlasmaID, size int) ([]byte, error) {
+	var messageOut string
+	if err := common.WriteCreateBufferByPlasmaRequest(id, size, &messageOut); err != nil {
+		return nil, err
+	}
+	var buffer []byte
+	err := p.ipc.roundTrip(ctx, func() (err error) {
+		var createBufferReply common.CreateBufferByPlasmaReply
+		if err := p.ipc.request(messageOut, common.CREATE_BUFFER_BY_PLASMA_REPLY, &createBufferReply); err != nil {
+			return err
+		}
+		payload := createBufferReply.Created.Buffer()
+		if size != payload.DataSize {
+			return errors.New("data size not match")
+		}
+		buffer, err = p.ipc.mmapPayload(&payload, false)
+		return err
+	})
+	return buffer, err
+}
+
+// Seal makes the buffer immutable and visible to Get.
+func (p *PlasmaClient) Seal(ctx context.Context, id common.PlasmaID) error {
+	var messageOut string
+	if err := common.WritePlasmaSealRequest(id, &messageOut); err != nil {
+		return err
+	}
+	return p.ipc.doRequest(ctx, messageOut, common.PLASMA_SEAL_REPLY, nil)
+}
+
+// Get returns the sealed buffers in the same order of ids, waiting at most
+// timeout for the buffers that haven't been sealed, or until the context is
+// done if the timeout is negative. The buffers that are still not found are
+// nil. Each buffer holds a reference that should be released by Release.
+func (p *PlasmaClient) Get(ctx context.Context, ids []common.PlasmaID, timeout time.Duration) ([]*ds.Blob, error) {
+	result := make([]*ds.Blob, len(ids))
+	pending := make([]int, len(ids))
+	for index := range ids {
+		pending[index] = index
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		pendingIds := make([]common.PlasmaID, 0, len(pending))
+		for _, index := range pending {
+			pendingIds = append(pendingIds, ids[index])
+		}
+		var blobs map[common.PlasmaID]*ds.Blob
+		err := p.ipc.roundTrip(ctx, func() (err error) {
+			blobs, err = p.getBuffers(pendingIds)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		remaining := pending[:0]
+		for _, index := range pending {
+			if blob, ok := blobs[ids[index]]; ok {
+				result[index] = blob
+			} else {
+				remaining = append(remaining, index)
+			}
+		}
+		pending = remaining
+		if len(pending) == 0 || timeout >= 0 && !time.Now().Before(deadline) {
+			return result, nil
+		}
+		if err := sleepContext(ctx, plasmaPollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// getBuffers gets the sealed buffers of the ids, the caller must hold the
+// mutex.
+func (p *PlasmaClient) getBuffers(ids []common.PlasmaID) (map[common.PlasmaID]*ds.Blob, error) {
+	var messageOut string
+	if err := common.WriteGetBuffersByPlasmaRequest(ids, false, &messageOut); err != nil {
+		return nil, err
+	}
+	if err := p.ipc.DoWrite(messageOut); err != nil {
+		return nil, err
+	}
+	var messageIn string
+	if err := p.ipc.DoRead(&messageIn); err != nil {
+		return nil, err
+	}
+	var getBuffersReply common.GetBuffersByPlasmaReply
+	if err := common.ReadGetBuffersByPlasmaReply(messageIn, &getBuffersReply); err != nil {
+		return nil, err
+	}
+	if err := common.CheckReply(common.ReplyHeader{
+		Type:    getBuffersReply.Type,
+		Code:    getBuffersReply.Code,
+		Message: getBuffersReply.Message,
+	}, common.GET_BUFFERS_BY_PLASMA_REQUEST, common.GET_BUFFERS_BY_PLASMA_REPLY); err != nil {
+		return nil, err
+	}
+
+	// every payload is mapped, as the fds that haven't been received follow
+	// the reply in the order of payloads.
+	blobs := make(map[common.PlasmaID]*ds.Blob)
+	for index := range getBuffersReply.Payloads {
+		payload := &getBuffersReply.Payloads[index]
+		created := payload.Buffer()
+		buffer, err := p.ipc.mmapPayload(&created, true)
+		if err != nil {
+			return nil, err
+		}
+		if payload.IsSealed {
+			blobs[payload.PlasmaID] = ds.NewBlob(payload.ID, payload.DataSize, buffer)
+		}
+	}
+	return blobs, nil
+}
+
+// Release releases a reference of the buffer got by Get (or created by
+// Create), the buffer must not be accessed after that.
+func (p *PlasmaClient) Release(ctx context.Context, id common.PlasmaID) error {
+	var messageOut string
+	if err := common.WritePlasmaReleaseRequest(id, &messageOut); err != nil {
+		return err
+	}
+	return p.ipc.doRequest(ctx, messageOut, common.PLASMA_RELEASE_REPLY, nil)
+}
+
+// Delete deletes the buffer, which is freed once all references are
+// released.
+func (p *PlasmaClient) Delete(ctx context.Context, id common.PlasmaID) error {
+	var messageOut string
+	if err := common.WritePlasmaDeleteDataRequest(id, &messageOut); err != nil {
+		return err
+	}
+	return p.ipc.doRequest(ctx, messageOut, common.PLASMA_DELETE_DATA_REPLY, nil)
+}
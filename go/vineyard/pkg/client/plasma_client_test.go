@@ -0,0 +1,88 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vineyard
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/client/vineyardtest"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+func TestPlasmaClient(t *testing.T) {
+	server := newTestServer(t, vineyardtest.WithStoreType(common.PlasmaStore))
+	ctx := context.Background()
+
+	var ipc IPCClient
+	if err := ipc.Connect(ctx, server.IPCSocket()); err == nil {
+		ipc.Disconnect(ctx)
+		t.Fatal("the normal client should not connect to the plasma store")
+	}
+
+	var client PlasmaClient
+	if err := client.Connect(ctx, server.IPCSocket()); err != nil {
+		t.Fatal("connect failed", err)
+	}
+	defer client.Disconnect(ctx)
+
+	id := common.PlasmaID{1, 2, 3}
+	missing := common.PlasmaID{4, 5, 6}
+	buffer, err := client.Create(ctx, id, 5)
+	if err != nil || len(buffer) != 5 {
+		t.Fatal("create failed", len(buffer), err)
+	}
+	copy(buffer, "hello")
+	if _, err := client.Create(ctx, id, 5); !errors.Is(err, common.ErrObjectExists) {
+		t.Error("create an existing buffer should fail", err)
+	}
+	if blobs, err := client.Get(ctx, []common.PlasmaID{id}, 0); err != nil || blobs[0] != nil {
+		t.Error("the buffer that hasn't been sealed should not be got", blobs, err)
+	}
+
+	if err := client.Seal(ctx, id); err != nil {
+		t.Fatal("seal failed", err)
+	}
+	if err := client.Release(ctx, id); err != nil {
+		t.Fatal("release the created buffer failed", err)
+	}
+	blobs, err := client.Get(ctx, []common.PlasmaID{id, missing}, 20*time.Millisecond)
+	if err != nil || len(blobs) != 2 {
+		t.Fatal("get failed", blobs, err)
+	}
+	if blobs[1] != nil {
+		t.Error("the missing buffer should be nil", blobs[1])
+	}
+	if data, err := blobs[0].Data(); err != nil || string(data) != "hello" {
+		t.Error("unexpected data", string(data), err)
+	}
+
+	// the buffer is freed once the reference of get is released
+	if err := client.Delete(ctx, id); err != nil {
+		t.Fatal("delete failed", err)
+	}
+	if blobs, _ := client.Get(ctx, []common.PlasmaID{id}, 0); blobs[0] != nil {
+		t.Error("the deleted buffer should not be got")
+	}
+	if err := client.Release(ctx, id); err != nil {
+		t.Error("release the deleted buffer failed", err)
+	}
+	if err := client.Release(ctx, id); !errors.Is(err, common.ErrObjectNotExists) {
+		t.Error("the buffer should have been freed", err)
+	}
+}
@@ -16,69 +16,244 @@ limitations under the License.
 package vineyard
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net"
 	"strconv"
-	"strings"
+	"sync/atomic"
 
+	"github.com/v6d-io/v6d/go/vineyard/pkg/client/ds"
 	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
 )
 
+// defaultRPCPort is the port vineyardd listens on for rpc clients by default.
+const defaultRPCPort = "9600"
+
 type RPCClient struct {
 	ClientBase
-	connected        bool
 	ipcSocket        string
-	conn             *net.UnixConn
-	instanceID       int
-	remoteInstanceID int
+	remoteInstanceID common.InstanceID
 	rpcEndpoint      string
 }
 
-func (r *RPCClient) Connect(rpcEndpoint string) error {
-	if r.connected || r.rpcEndpoint == rpcEndpoint {
-		return nil
+// Connect connects to the rpc endpoint (i.e., "host:port") of vineyard server,
+// the client reconnects to the same endpoint once the connection is lost,
+// following the reconnect policy in options.
+func (r *RPCClient) Connect(ctx context.Context, rpcEndpoint string, options ...Option) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.connected {
+		if r.rpcEndpoint == rpcEndpoint {
+			return nil
+		}
+		return fmt.Errorf("the client has already connected to %s", r.rpcEndpoint)
 	}
 	r.rpcEndpoint = rpcEndpoint
-
-	str := strings.Split(rpcEndpoint, ":")
-	host := str[0]
-	port := str[1]
-	if port == "" {
-		port = "9600"
+	r.applyOptions(options)
+	if err := r.register(ctx); err != nil {
+		return err
 	}
-	var conn net.Conn
-	portNum, err := strconv.Atoi(port)
+	r.ClientBase.register = r.register
+	return nil
+}
+
+// register connects to the endpoint and registers the client to vineyard
+// server, the caller must hold the mutex.
+func (r *RPCClient) register(ctx context.Context) error {
+	host, port, err := splitRPCEndpoint(r.rpcEndpoint)
 	if err != nil {
 		return err
 	}
-	err = ConnectRPCSocketRetry(host, uint16(portNum), &conn)
+	var conn net.Conn
+	err = ConnectRPCSocketRetry(ctx, host, port, &conn, r.policy)
 	if err != nil {
 		return err
 	}
 
 	r.ClientBase.conn = conn
-	var messageOut string
-	common.WriteRegisterRequest(&messageOut)
-	if err := r.DoWrite(messageOut); err != nil {
-		return err
-	}
-	var messageIn string
-	err = r.DoRead(&messageIn)
-	if err != nil {
-		return err
-	}
 	var registerReply common.RegisterReply
-	err = json.Unmarshal([]byte(messageIn), &registerReply)
+	err = r.withContext(ctx, func() error {
+		var messageOut string
+		if err := r.writeRegisterRequest(&messageOut); err != nil {
+			return err
+		}
+		return r.request(messageOut, common.REGISTER_REPLY, &registerReply)
+	})
+	if err == nil {
+		err = r.checkRegisterReply(&registerReply)
+	}
 	if err != nil {
+		conn.Close()
 		return err
 	}
 
 	r.connected = true
 	r.ipcSocket = registerReply.IPCSocket
 	r.remoteInstanceID = registerReply.InstanceID
-	// TODO: compatible server check
-
-	// r.instanceID = registerReply.InstanceID
-	// fmt.Println(messageIn)
+	atomic.StoreUint64(&r.instanceID, common.UnspecifiedInstanceID()-1)
 	return nil
 }
+
+// splitRPCEndpoint splits the endpoint into host and port, the default port
+// of vineyardd is used if the endpoint (e.g., "localhost") doesn't have one.
+func splitRPCEndpoint(rpcEndpoint string) (string, uint16, error) {
+	host, port, err := net.SplitHostPort(rpcEndpoint)
+	var addrErr *net.AddrError
+	if errors.As(err, &addrErr) && addrErr.Err == "missing port in address" {
+		host, port, err = net.SplitHostPort(rpcEndpoint + ":" + defaultRPCPort)
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid rpc endpoint '%s': %v", rpcEndpoint, err)
+	}
+	if port == "" {
+		port = defaultRPCPort
+	}
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port of rpc endpoint '%s': %v", rpcEndpoint, err)
+	}
+	return host, uint16(portNum), nil
+}
+
+// RemoteInstanceID returns the instance of vineyard server the client
+// connects to.
+func (r *RPCClient) RemoteInstanceID() common.InstanceID {
+	return r.remoteInstanceID
+}
+
+// compress returns whether the payloads of remote buffers are compressed, the
+// caller must hold the mutex.
+func (r *RPCClient) compress() bool {
+	return r.compression && r.Supports(common.FeatureRPCCompression)
+}
+
+// CreateRemoteBlob creates a blob in the vineyard server with size bytes read
+// from reader, the payload is sent over the connection (compressed if the
+// client is connected WithCompression), and the metadata of the blob is
+// returned, which could be added as a member of other objects.
+func (r *RPCClient) CreateRemoteBlob(ctx context.Context, reader io.Reader, size int) (*ds.ObjectMeta, error) {
+	if err := r.checkFeature(common.FeatureRemoteBuffers, common.CREATE_REMOTE_BUFFER_REQUEST); err != nil {
+		return nil, err
+	}
+	var createBufferReply common.CreateBufferReply
+	err := r.roundTrip(ctx, func() error {
+		var messageOut string
+		compress := r.compress()
+		if err := common.WriteCreateRemoteBufferRequest(size, compress, &messageOut); err != nil {
+			return err
+		}
+		if err := r.DoWrite(messageOut); err != nil {
+			return err
+		}
+		var err error
+		if compress {
+			var compressor *compressor
+			if compressor, err = newCompressor(r.conn); err == nil {
+				err = compressor.send(reader, size)
+				compressor.close()
+			}
+		} else {
+			_, err = io.CopyN(r.conn, reader, int64(size))
+		}
+		if err != nil {
+			// the server is still waiting for the payload
+			r.closeConn()
+			return fmt.Errorf("failed to send the payload of remote blob: %w", err)
+		}
+		return r.readReply(messageOut, common.CREATE_BUFFER_REPLY, &createBufferReply)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var meta ds.ObjectMeta
+	meta.Init()
+	meta.SetId(createBufferReply.ID)
+	meta.SetTypeName("vineyard::Blob")
+	meta.AddKeyValue("length", size)
+	meta.SetNBytes(size)
+	meta.SetInstanceId(r.remoteInstanceID)
+	meta.AddKeyValue("transient", true)
+	meta.SetClient(r)
+	return &meta, nil
+}
+
+// GetRemoteBlobs returns the blobs in the same order of ids, the payloads are
+// received over the connection (compressed if the client is connected
+// WithCompression) and copied into the memory of the client.
+func (r *RPCClient) GetRemoteBlobs(ctx context.Context, ids ...common.ObjectID) ([]*ds.Blob, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := r.checkFeature(common.FeatureRemoteBuffers, common.GET_REMOTE_BUFFERS_REQUEST); err != nil {
+		return nil, err
+	}
+	var result []*ds.Blob
+	err := r.roundTrip(ctx, func() (err error) {
+		result, err = r.getRemoteBlobs(ids)
+		return err
+	})
+	return result, err
+}
+
+func (r *RPCClient) getRemoteBlobs(ids []common.ObjectID) ([]*ds.Blob, error) {
+	var messageOut string
+	compress := r.compress()
+	if err := common.WriteGetRemoteBuffersRequest(ids, false, compress, &messageOut); err != nil {
+		return nil, err
+	}
+	if err := r.DoWrite(messageOut); err != nil {
+		return nil, err
+	}
+	var messageIn string
+	if err := r.DoRead(&messageIn); err != nil {
+		return nil, err
+	}
+	var getBuffersReply common.GetBuffersReply
+	if err := common.ReadGetBuffersReply(messageIn, &getBuffersReply); err != nil {
+		return nil, err
+	}
+	if err := common.CheckReply(common.ReplyHeader{
+		Type:    getBuffersReply.Type,
+		Code:    getBuffersReply.Code,
+		Message: getBuffersReply.Message,
+	}, common.GET_REMOTE_BUFFERS_REQUEST, common.GET_BUFFERS_REPLY); err != nil {
+		return nil, err
+	}
+
+	// the payloads follow the reply in the order of payloads
+	decompressor := decompressor{conn: r.conn}
+	defer decompressor.close()
+	blobs := make(map[common.ObjectID]*ds.Blob)
+	for _, payload := range getBuffersReply.Payloads {
+		buffer := make([]byte, payload.DataSize)
+		if payload.DataSize > 0 {
+			var err error
+			if compress {
+				err = decompressor.recv(buffer)
+			} else {
+				err = RecvBytes(r.conn, buffer, payload.DataSize)
+			}
+			if err != nil {
+				r.closeConn()
+				return nil, &connError{sent: true, err: err}
+			}
+		}
+		blobs[payload.ID] = ds.NewBlob(payload.ID, payload.DataSize, buffer)
+	}
+	r.trailingChunk = decompressor.decoder != nil
+	result := make([]*ds.Blob, 0, len(ids))
+	for _, id := range ids {
+		blob, ok := blobs[id]
+		if !ok {
+			return nil, &common.Status{
+				Code:    common.KObjectNotExists,
+				Message: fmt.Sprintf("blob not exists: %s", common.ObjectIDToString(id)),
+				Request: common.GET_REMOTE_BUFFERS_REQUEST,
+			}
+		}
+		result = append(result, blob)
+	}
+	return result, nil
+}
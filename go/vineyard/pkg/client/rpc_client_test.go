@@ -15,17 +15,177 @@ limitations under the License.
 
 package vineyard
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/client/vineyardtest"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
 
 func TestRPCServer_Connect(t *testing.T) {
-	ipcAddr := "0.0.0.0:9600"
+	ipcAddr := newTestServer(t).RPCEndpoint()
 	var rpcServer RPCClient
-	err := rpcServer.Connect(ipcAddr)
+	err := rpcServer.Connect(context.Background(), ipcAddr)
 	if err != nil {
 		t.Error("connect to rpc server failed", err.Error())
 	}
-	err = rpcServer.Disconnect()
+	err = rpcServer.Disconnect(context.Background())
 	if err != nil {
 		t.Error("disconnect rpc server failed", err.Error())
 	}
 }
+
+func TestRPCClient_RemoteBlobs(t *testing.T) {
+	// the payload is incompressible to make the server flush in the middle
+	large := make([]byte, 800000)
+	rand.New(rand.NewSource(0)).Read(large)
+	// compression is skipped for servers that don't support it
+	for _, test := range []struct {
+		version string
+		options []Option
+	}{
+		{vineyardtest.DefaultVersion, nil},
+		{vineyardtest.DefaultVersion, []Option{WithCompression()}},
+		{"0.14.6", []Option{WithCompression()}},
+	} {
+		server := newTestServer(t, vineyardtest.WithVersion(test.version))
+		var client RPCClient
+		if err := client.Connect(context.Background(), server.RPCEndpoint(), test.options...); err != nil {
+			t.Fatal("connect failed", err)
+		}
+		var ids []common.ObjectID
+		for _, payload := range [][]byte{[]byte("hello"), large, {}} {
+			meta, err := client.CreateRemoteBlob(context.Background(), bytes.NewReader(payload), len(payload))
+			if err != nil {
+				t.Fatal("create remote blob failed", err)
+			}
+			if meta.GetTypeName() != "vineyard::Blob" || meta.GetNBytes() != len(payload) ||
+				meta.GetInstanceId() != client.RemoteInstanceID() {
+				t.Error("unexpected metadata of remote blob", meta.MetaData())
+			}
+			ids = append(ids, meta.GetId())
+		}
+
+		// get the blobs in the reversed order
+		blobs, err := client.GetRemoteBlobs(context.Background(), ids[2], ids[1], ids[0])
+		if err != nil || len(blobs) != 3 {
+			t.Fatal("get remote blobs failed", err)
+		}
+		for index, expected := range [][]byte{{}, large, []byte("hello")} {
+			if data, err := blobs[index].Data(); err != nil || !bytes.Equal(data, expected) {
+				t.Error("unexpected payload of remote blob", index, len(data), err)
+			}
+		}
+		// the compressed stream ends with an empty chunk after the large payload
+		blobs, err = client.GetRemoteBlobs(context.Background(), ids[1])
+		if err != nil || len(blobs) != 1 {
+			t.Fatal("get remote blobs failed", err)
+		}
+		if data, err := blobs[0].Data(); err != nil || !bytes.Equal(data, large) {
+			t.Error("unexpected payload of remote blob", len(data), err)
+		}
+		blobs, err = client.GetRemoteBlobs(context.Background(), ids[0])
+		if err != nil || len(blobs) != 1 {
+			t.Fatal("get remote blobs after the empty chunk failed", err)
+		}
+		if _, err := client.GetRemoteBlobs(context.Background(), 0x8000000000000010); err == nil {
+			t.Error("expect an error for blobs not exist")
+		}
+		client.Disconnect(context.Background())
+	}
+}
+
+// TestDecompressor_FlushedStream decompresses the chunks sent by vineyardd,
+// i.e., the outputs of Compressor::Pull in src/server/util/compressor.cc with
+// libzstd, including the empty ones. The second payload refers to the first
+// one.
+func TestDecompressor_FlushedStream(t *testing.T) {
+	chunks := []string{
+		"",
+		"28b52ffd005878000068656c6c6f2c2076696e6579617264",
+		"",
+		"3c0000000100fd530b10",
+	}
+	server, conn := net.Pipe()
+	defer conn.Close()
+	go func() {
+		defer server.Close()
+		header := make([]byte, 8)
+		for _, chunk := range chunks {
+			data, _ := hex.DecodeString(chunk)
+			binary.LittleEndian.PutUint64(header, uint64(len(data)))
+			if _, err := server.Write(append(header, data...)); err != nil {
+				return
+			}
+		}
+		// the next message on the connection must not be consumed
+		_, _ = server.Write([]byte("next"))
+	}()
+	if err := conn.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		t.Fatal("set deadline failed", err)
+	}
+
+	decompressor := decompressor{conn: conn}
+	for _, expected := range [][]byte{[]byte("hello, vineyard"), bytes.Repeat([]byte("vineyard"), 64)} {
+		data := make([]byte, len(expected))
+		if err := decompressor.recv(data); err != nil || !bytes.Equal(data, expected) {
+			t.Fatal("unexpected decompressed payload", string(data), err)
+		}
+	}
+	decompressor.close()
+	next := make([]byte, 4)
+	if _, err := io.ReadFull(conn, next); err != nil || string(next) != "next" {
+		t.Error("the bytes after the payloads are consumed", string(next), err)
+	}
+}
+
+func TestSplitRPCEndpoint(t *testing.T) {
+	for endpoint, expected := range map[string]string{
+		"localhost":      "localhost 9600",
+		"localhost:":     "localhost 9600",
+		"127.0.0.1:9601": "127.0.0.1 9601",
+		"[::1]":          "::1 9600",
+		"[::1]:9601":     "::1 9601",
+	} {
+		host, port, err := splitRPCEndpoint(endpoint)
+		if err != nil || fmt.Sprint(host, " ", port) != expected {
+			t.Error("unexpected host and port of", endpoint, host, port, err)
+		}
+	}
+	for _, endpoint := range []string{"a:b:c", "localhost:port", "localhost:65536"} {
+		if _, _, err := splitRPCEndpoint(endpoint); err == nil {
+			t.Error("the endpoint should be invalid", endpoint)
+		}
+	}
+
+	// connecting to an endpoint without port doesn't panic
+	var client RPCClient
+	if err := client.Connect(context.Background(), "localhost:port", WithoutReconnect()); err == nil {
+		t.Error("connect to an invalid endpoint should fail")
+	}
+}
+
+func TestRPCClient_RemoteBuffersUnsupported(t *testing.T) {
+	// servers that don't report their version support no optional features
+	var client RPCClient
+	if err := client.Connect(context.Background(), newTestServer(t, vineyardtest.WithVersion("")).RPCEndpoint()); err != nil {
+		t.Fatal("connect failed", err)
+	}
+	defer client.Disconnect(context.Background())
+	if _, err := client.CreateRemoteBlob(context.Background(), bytes.NewReader(nil), 0); !errors.Is(err, common.ErrNotImplemented) {
+		t.Error("expect ErrNotImplemented", err)
+	}
+	if _, err := client.GetRemoteBlobs(context.Background(), 0x8000000000000010); !errors.Is(err, common.ErrNotImplemented) {
+		t.Error("expect ErrNotImplemented", err)
+	}
+}
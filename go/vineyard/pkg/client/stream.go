@@ -0,0 +1,331 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vineyard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	vineyard "github.com/v6d-io/v6d/go/vineyard/pkg/client/ds"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+type StreamOpenMode int64
+
+const (
+	StreamOpenRead  StreamOpenMode = 1
+	StreamOpenWrite StreamOpenMode = 2
+)
+
+// CreateStream creates the stream metadata with the given typename (e.g.,
+// "vineyard::ByteStream") and params, and registers the stream in vineyard
+// server.
+func (i *IPCClient) CreateStream(ctx context.Context, typeName string, params map[string]string) (common.ObjectID, error) {
+	var meta vineyard.ObjectMeta
+	meta.Init()
+	meta.SetTypeName(typeName)
+	meta.SetNBytes(0)
+	if params == nil {
+		params = map[string]string{}
+	}
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return common.InvalidObjectID(), err
+	}
+	meta.AddKeyValue("params_", string(encoded))
+
+	id := common.InvalidObjectID()
+	if err := i.CreateMetaData(ctx, &meta, &id); err != nil {
+		return common.InvalidObjectID(), err
+	}
+	var messageOut string
+	if err := common.WriteCreateStreamRequest(id, &messageOut); err != nil {
+		return common.InvalidObjectID(), err
+	}
+	if err := i.doRequest(ctx, messageOut, common.CREATE_STREAM_REPLY, nil); err != nil {
+		return common.InvalidObjectID(), err
+	}
+	return id, nil
+}
+
+func (i *IPCClient) OpenStream(ctx context.Context, id common.ObjectID, mode StreamOpenMode) error {
+	var messageOut string
+	if err := common.WriteOpenStreamRequest(id, int64(mode), &messageOut); err != nil {
+		return err
+	}
+	return i.doRequest(ctx, messageOut, common.OPEN_STREAM_REPLY, nil)
+}
+
+// GetNextStreamChunk allocates the next chunk of the stream, the chunk that
+// has been allocated before is sealed and becomes visible to the reader.
+func (i *IPCClient) GetNextStreamChunk(ctx context.Context, id common.ObjectID, size int, blob *vineyard.BlobWriter) error {
+	var messageOut string
+	if err := common.WriteGetNextStreamChunkRequest(id, size, &messageOut); err != nil {
+		return err
+	}
+	return i.roundTrip(ctx, func() error {
+		var reply common.GetNextStreamChunkReply
+		if err := i.request(messageOut, common.GET_NEXT_STREAM_CHUNK_REPLY, &reply); err != nil {
+			return err
+		}
+		if reply.Buffer.DataSize != size {
+			return errors.New("the size of returned chunk doesn't match")
+		}
+		buffer, err := i.mmapPayload(&reply.Buffer, false)
+		if err != nil {
+			return err
+		}
+		payload := vineyard.Payload{
+			ID:         reply.Buffer.ID,
+			StoreFd:    reply.Buffer.StoreFd,
+			DataOffset: reply.Buffer.DataOffset,
+			DataSize:   reply.Buffer.DataSize,
+			MapSize:    reply.Buffer.MapSize,
+		}
+		blob.Reset(i, reply.Buffer.ID, payload, buffer)
+		return nil
+	})
+}
+
+func (i *IPCClient) PushNextStreamChunk(ctx context.Context, id common.ObjectID, chunk common.ObjectID) error {
+	var messageOut string
+	if err := common.WritePushNextStreamChunkRequest(id, chunk, &messageOut); err != nil {
+		return err
+	}
+	return i.doRequest(ctx, messageOut, common.PUSH_NEXT_STREAM_CHUNK_REPLY, nil)
+}
+
+// PullNextStreamChunk blocks until the next chunk is available, it fails
+// with common.ErrStreamDrained once the stream is drained, or
+// common.ErrStreamFailed if the writer has aborted the stream. The
+// connection is closed if ctx is done while waiting for the next chunk, as
+// the pending reply cannot be consumed anymore.
+func (i *IPCClient) PullNextStreamChunk(ctx context.Context, id common.ObjectID, chunk *common.ObjectID) error {
+	var messageOut string
+	if err := common.WritePullNextStreamChunkRequest(id, &messageOut); err != nil {
+		return err
+	}
+	var reply common.PullNextStreamChunkReply
+	if err := i.doRequest(ctx, messageOut, common.PULL_NEXT_STREAM_CHUNK_REPLY, &reply); err != nil {
+		return err
+	}
+	*chunk = reply.Chunk
+	return nil
+}
+
+func (i *IPCClient) StopStream(ctx context.Context, id common.ObjectID, failed bool) error {
+	var messageOut string
+	if err := common.WriteStopStreamRequest(id, failed, &messageOut); err != nil {
+		return err
+	}
+	return i.doRequest(ctx, messageOut, common.STOP_STREAM_REPLY, nil)
+}
+
+func (i *IPCClient) DropStream(ctx context.Context, id common.ObjectID) error {
+	var messageOut string
+	if err := common.WriteDropStreamRequest(id, &messageOut); err != nil {
+		return err
+	}
+	return i.doRequest(ctx, messageOut, common.DROP_STREAM_REPLY, nil)
+}
+
+// Stream is an opened stream, either as the writer or as the reader. The
+// writer and the reader are expected to use different clients, as pulling
+// blocks the connection until the writer produces the next chunk.
+type Stream struct {
+	client   *IPCClient
+	id       common.ObjectID
+	readonly bool
+	stopped  bool
+	err      error
+	// pulled is the chunk returned by the last Pull, whose reference is
+	// released by the next Pull or Close.
+	pulled common.ObjectID
+}
+
+func (i *IPCClient) OpenStreamWriter(ctx context.Context, id common.ObjectID) (*Stream, error) {
+	if err := i.OpenStream(ctx, id, StreamOpenWrite); err != nil {
+		return nil, err
+	}
+	return &Stream{client: i, id: id, readonly: false, pulled: common.InvalidObjectID()}, nil
+}
+
+func (i *IPCClient) OpenStreamReader(ctx context.Context, id common.ObjectID) (*Stream, error) {
+	if err := i.OpenStream(ctx, id, StreamOpenRead); err != nil {
+		return nil, err
+	}
+	return &Stream{client: i, id: id, readonly: true, pulled: common.InvalidObjectID()}, nil
+}
+
+func (s *Stream) ID() common.ObjectID {
+	return s.id
+}
+
+// Next allocates the next chunk to write, the chunk returned by the previous
+// call is sealed by vineyard server and pushed to the stream, thus the blob
+// writer is not expected to be sealed or aborted by the caller.
+func (s *Stream) Next(ctx context.Context, size int) (*vineyard.BlobWriter, error) {
+	if s.readonly {
+		return nil, errors.New("expect a writable stream")
+	}
+	if s.stopped {
+		return nil, errors.New("the stream has already been stopped")
+	}
+	var blob vineyard.BlobWriter
+	if err := s.client.GetNextStreamChunk(ctx, s.id, size, &blob); err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
+
+// Push pushes an existing (sealed) object to the stream as the next chunk.
+func (s *Stream) Push(ctx context.Context, chunk common.ObjectID) error {
+	if s.readonly {
+		return errors.New("expect a writable stream")
+	}
+	if s.stopped {
+		return errors.New("the stream has already been stopped")
+	}
+	return s.client.PushNextStreamChunk(ctx, s.id, chunk)
+}
+
+// Stop marks the stream as drained, readers will get io.EOF after consuming
+// all pushed chunks.
+func (s *Stream) Stop(ctx context.Context) error {
+	return s.stop(ctx, false)
+}
+
+// Abort marks the stream as failed, readers will fail with
+// common.ErrStreamFailed.
+func (s *Stream) Abort(ctx context.Context) error {
+	return s.stop(ctx, true)
+}
+
+func (s *Stream) stop(ctx context.Context, failed bool) error {
+	if s.readonly {
+		return errors.New("expect a writable stream")
+	}
+	if s.stopped {
+		return nil
+	}
+	if err := s.client.StopStream(ctx, s.id, failed); err != nil {
+		return err
+	}
+	s.stopped = true
+	return nil
+}
+
+// Pull returns the next chunk of the stream, it returns io.EOF once the
+// stream is drained, and an error that matches common.ErrStreamFailed if the
+// writer has aborted the stream. The reference of the chunk is released on
+// the next Pull (or Close), thus it shouldn't be used afterwards. The
+// connection is closed if ctx is done while waiting for the next chunk.
+func (s *Stream) Pull(ctx context.Context) (*vineyard.Blob, error) {
+	if !s.readonly {
+		return nil, errors.New("expect a readonly stream")
+	}
+	if err := s.release(ctx); err != nil {
+		return nil, err
+	}
+	chunk := common.InvalidObjectID()
+	err := s.client.PullNextStreamChunk(ctx, s.id, &chunk)
+	if errors.Is(err, common.ErrStreamDrained) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	blob, err := s.client.GetBlob(ctx, chunk)
+	if err != nil {
+		return nil, err
+	}
+	s.pulled = chunk
+	return blob, nil
+}
+
+// Close releases the chunk returned by the last Pull, the stream could still
+// be pulled afterwards.
+func (s *Stream) Close(ctx context.Context) error {
+	return s.release(ctx)
+}
+
+// release releases the reference of the last pulled chunk, the chunks are
+// released by the finalizer instead if the client is connected
+// WithBlobFinalizer.
+func (s *Stream) release(ctx context.Context) error {
+	chunk := s.pulled
+	s.pulled = common.InvalidObjectID()
+	if chunk == common.InvalidObjectID() || s.client.blobFinalizer {
+		return nil
+	}
+	return s.client.Release(ctx, chunk)
+}
+
+// StreamChunk is a chunk delivered by Stream.Chunks, Done must be called
+// once the chunk has been consumed, the next chunk is pulled (and this chunk
+// is released) afterwards.
+type StreamChunk struct {
+	*vineyard.Blob
+	done chan struct{}
+	once sync.Once
+}
+
+func (c *StreamChunk) Done() {
+	c.once.Do(func() { close(c.done) })
+}
+
+// Chunks iterates over the stream until it is drained, failed or ctx is
+// done, the reason of termination (nil if drained) is available from Err()
+// once the channel is closed.
+func (s *Stream) Chunks(ctx context.Context) <-chan *StreamChunk {
+	chunks := make(chan *StreamChunk)
+	go func() {
+		defer close(chunks)
+		// the last chunk is released even if ctx is done
+		defer s.release(context.Background())
+		for {
+			blob, err := s.Pull(ctx)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				s.err = err
+				return
+			}
+			chunk := &StreamChunk{Blob: blob, done: make(chan struct{})}
+			select {
+			case chunks <- chunk:
+			case <-ctx.Done():
+				s.err = ctx.Err()
+				return
+			}
+			select {
+			case <-chunk.done:
+			case <-ctx.Done():
+				s.err = ctx.Err()
+				return
+			}
+		}
+	}()
+	return chunks
+}
+
+func (s *Stream) Err() error {
+	return s.err
+}
@@ -0,0 +1,277 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vineyard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	vineyard "github.com/v6d-io/v6d/go/vineyard/pkg/client/ds"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/client/vineyardtest"
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+func TestStream_Writer(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server)
+	ctx := context.Background()
+
+	id, err := client.CreateStream(ctx, "vineyard::ByteStream", map[string]string{"kind": "bytes"})
+	if err != nil {
+		t.Fatal("create stream failed", err)
+	}
+	var meta vineyard.ObjectMeta
+	if err := client.GetMetaData(ctx, id, &meta, false); err != nil {
+		t.Fatal("get metadata of stream failed", err)
+	}
+	if params := meta.MetaData().(map[string]interface{})["params_"]; params != `{"kind":"bytes"}` {
+		t.Error("unexpected params of stream", params)
+	}
+	stream, err := client.OpenStreamWriter(ctx, id)
+	if err != nil {
+		t.Fatal("open stream writer failed", err)
+	}
+	chunk, err := stream.Next(ctx, 5)
+	if err != nil {
+		t.Fatal("get next chunk failed", err)
+	}
+	if _, err := chunk.Write([]byte("hello")); err != nil {
+		t.Fatal("write chunk failed", err)
+	}
+	if err := stream.Push(ctx, newTestBlob(t, client, "vineyard")); err != nil {
+		t.Error("push chunk failed", err)
+	}
+	if err := stream.Stop(ctx); err != nil {
+		t.Error("stop stream failed", err)
+	}
+	// stopping multiple times is a no-op
+	if err := stream.Stop(ctx); err != nil {
+		t.Error("stop stream again failed", err)
+	}
+	if _, err := stream.Next(ctx, 5); err == nil {
+		t.Error("get next chunk from a stopped stream should fail")
+	}
+
+	// the allocated chunk is pushed before the pushed one
+	input, err := client.OpenStreamReader(ctx, id)
+	if err != nil {
+		t.Fatal("open stream reader failed", err)
+	}
+	var contents []string
+	for chunk := range input.Chunks(ctx) {
+		data, _ := chunk.Data()
+		contents = append(contents, string(data))
+		chunk.Done()
+	}
+	if input.Err() != nil || fmt.Sprint(contents) != "[hello vineyard]" {
+		t.Error("unexpected chunks of stream", contents, input.Err())
+	}
+}
+
+// newTestStreamReader returns a reader of a stream in the server, whose
+// chunks are written by the returned stream writer.
+func newTestStreamReader(t *testing.T, server *vineyardtest.Server) (*Stream, *Stream) {
+	writer := newTestClient(t, server)
+	ctx := context.Background()
+	id, err := writer.CreateStream(ctx, "vineyard::ByteStream", nil)
+	if err != nil {
+		t.Fatal("create stream failed", err)
+	}
+	output, err := writer.OpenStreamWriter(ctx, id)
+	if err != nil {
+		t.Fatal("open stream writer failed", err)
+	}
+	input, err := newTestClient(t, server).OpenStreamReader(ctx, id)
+	if err != nil {
+		t.Fatal("open stream reader failed", err)
+	}
+	return input, output
+}
+
+func TestStream_Reader(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	input, output := newTestStreamReader(t, server)
+	var chunks []common.ObjectID
+	for _, content := range []string{"a", "b"} {
+		chunk, err := output.Next(ctx, len(content))
+		if err != nil {
+			t.Fatal("get next chunk failed", err)
+		}
+		copy(chunk.Buf(), content)
+		chunks = append(chunks, chunk.ID)
+	}
+	if err := output.Stop(ctx); err != nil {
+		t.Fatal("stop stream failed", err)
+	}
+	for _, expected := range chunks {
+		chunk, err := input.Pull(ctx)
+		if err != nil || chunk.ID() != expected {
+			t.Fatal("pull chunk failed", err)
+		}
+	}
+	if _, err := input.Pull(ctx); err != io.EOF {
+		t.Error("pull from a drained stream should return io.EOF", err)
+	}
+
+	// the chunk that hasn't been pushed is dropped once the stream fails
+	input, output = newTestStreamReader(t, server)
+	for _, content := range []string{"a", "b"} {
+		if _, err := output.Next(ctx, len(content)); err != nil {
+			t.Fatal("get next chunk failed", err)
+		}
+	}
+	if err := output.Abort(ctx); err != nil {
+		t.Fatal("abort stream failed", err)
+	}
+	count := 0
+	for chunk := range input.Chunks(ctx) {
+		count++
+		chunk.Done()
+	}
+	if count != 1 || !errors.Is(input.Err(), common.ErrStreamFailed) {
+		t.Error("iterating a failed stream should fail with ErrStreamFailed", count, input.Err())
+	}
+}
+
+func TestStream_PullCanceled(t *testing.T) {
+	server := newTestServer(t)
+	input, _ := newTestStreamReader(t, server)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := input.Pull(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("pull should be canceled by the context", err)
+	}
+	if input.client.connected {
+		t.Error("the connection should be closed after canceling a pending pull")
+	}
+}
+
+func TestStream_TestServer(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	var writer, reader IPCClient
+	for _, client := range []*IPCClient{&writer, &reader} {
+		if err := client.Connect(ctx, server.IPCSocket()); err != nil {
+			t.Fatal("connect failed", err)
+		}
+		defer client.Disconnect(ctx)
+	}
+	id, err := writer.CreateStream(ctx, "vineyard::ByteStream", nil)
+	if err != nil {
+		t.Fatal("create stream failed", err)
+	}
+	output, err := writer.OpenStreamWriter(ctx, id)
+	if err != nil {
+		t.Fatal("open stream writer failed", err)
+	}
+	input, err := reader.OpenStreamReader(ctx, id)
+	if err != nil {
+		t.Fatal("open stream reader failed", err)
+	}
+	if _, err := writer.OpenStreamWriter(ctx, id); !errors.Is(err, common.ErrStreamOpened) {
+		t.Error("the stream shouldn't be opened twice", err)
+	}
+
+	// the reader waits for the chunks written concurrently
+	done := make(chan error)
+	go func() {
+		for _, content := range []string{"hello", "vineyard"} {
+			chunk, err := output.Next(ctx, len(content))
+			if err != nil {
+				done <- err
+				return
+			}
+			if _, err := chunk.Write([]byte(content)); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- output.Stop(ctx)
+	}()
+	var contents []string
+	for chunk := range input.Chunks(ctx) {
+		data, _ := chunk.Data()
+		contents = append(contents, string(data))
+		chunk.Done()
+	}
+	if err := <-done; err != nil {
+		t.Fatal("write stream failed", err)
+	}
+	if input.Err() != nil || fmt.Sprint(contents) != "[hello vineyard]" {
+		t.Error("unexpected chunks of stream", contents, input.Err())
+	}
+	if len(reader.references) != 0 {
+		t.Error("the chunks should be released once consumed", reader.references)
+	}
+}
+
+func TestStream_ReleaseChunks(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	var client IPCClient
+	if err := client.Connect(ctx, server.IPCSocket()); err != nil {
+		t.Fatal("connect failed", err)
+	}
+	defer client.Disconnect(ctx)
+	id, err := client.CreateStream(ctx, "vineyard::ByteStream", nil)
+	if err != nil {
+		t.Fatal("create stream failed", err)
+	}
+	output, err := client.OpenStreamWriter(ctx, id)
+	if err != nil {
+		t.Fatal("open stream writer failed", err)
+	}
+	for _, content := range []string{"hello", "vineyard"} {
+		chunk, err := output.Next(ctx, len(content))
+		if err != nil {
+			t.Fatal("allocate chunk failed", err)
+		}
+		copy(chunk.Buf(), content)
+	}
+	if err := output.Stop(ctx); err != nil {
+		t.Fatal("stop stream failed", err)
+	}
+
+	input, err := client.OpenStreamReader(ctx, id)
+	if err != nil {
+		t.Fatal("open stream reader failed", err)
+	}
+	first, err := input.Pull(ctx)
+	if err != nil {
+		t.Fatal("pull chunk failed", err)
+	}
+	if client.references[first.ID()] != 1 {
+		t.Error("the pulled chunk should hold a reference", client.references)
+	}
+	second, err := input.Pull(ctx)
+	if err != nil {
+		t.Fatal("pull chunk failed", err)
+	}
+	if _, ok := client.references[first.ID()]; ok || client.references[second.ID()] != 1 {
+		t.Error("the previous chunk should be released by the next pull", client.references)
+	}
+	if err := input.Close(ctx); err != nil {
+		t.Fatal("close stream failed", err)
+	}
+	if len(client.references) != 0 {
+		t.Error("the last chunk should be released by close", client.references)
+	}
+}
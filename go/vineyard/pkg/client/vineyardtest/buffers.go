@@ -0,0 +1,436 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vineyardtest
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+	"golang.org/x/sys/unix"
+)
+
+// alignment of the payloads of blobs, the same as vineyardd
+const alignment = 64
+
+// store is the shared memory of blobs, a memfd that is mapped into both the
+// server and IPC clients.
+type store struct {
+	fd     int
+	memory []byte
+	// extents are the free regions ordered by offset
+	extents []extent
+	blobs   map[common.ObjectID]*blob
+}
+
+type extent struct {
+	offset, size int
+}
+
+// blob is spilled by evict requests and reloaded on demand, the payload is
+// kept in memory even if it is spilled.
+type blob struct {
+	offset, size int
+	sealed       bool
+	spilled      bool
+	pinned       bool
+}
+
+func newStore(size int) (*store, error) {
+	fd, err := unix.MemfdCreate("vineyardtest", unix.MFD_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Ftruncate(fd, int64(size)); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	memory, err := unix.Mmap(fd, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return &store{
+		fd:      fd,
+		memory:  memory,
+		extents: []extent{{0, size}},
+		blobs:   make(map[common.ObjectID]*blob),
+	}, nil
+}
+
+func (s *store) close() {
+	unix.Munmap(s.memory)
+	unix.Close(s.fd)
+}
+
+// allocate finds the first free region that fits, and returns false if the
+// memory is exhausted.
+func (s *store) allocate(id common.ObjectID, size int) (*blob, bool) {
+	aligned := (size + alignment - 1) / alignment * alignment
+	for index, free := range s.extents {
+		if free.size < aligned {
+			continue
+		}
+		if free.size == aligned {
+			s.extents = append(s.extents[:index], s.extents[index+1:]...)
+		} else {
+			s.extents[index] = extent{free.offset + aligned, free.size - aligned}
+		}
+		b := &blob{offset: free.offset, size: size}
+		s.blobs[id] = b
+		return b, true
+	}
+	return nil, false
+}
+
+// free releases the memory of the blob, the adjacent free regions are merged.
+func (s *store) free(id common.ObjectID) {
+	b, ok := s.blobs[id]
+	if !ok {
+		return
+	}
+	delete(s.blobs, id)
+	released := extent{b.offset, (b.size + alignment - 1) / alignment * alignment}
+	if released.size == 0 {
+		return
+	}
+	index := 0
+	for index < len(s.extents) && s.extents[index].offset < released.offset {
+		index++
+	}
+	s.extents = append(s.extents[:index], append([]extent{released}, s.extents[index:]...)...)
+	if index+1 < len(s.extents) && released.offset+released.size == s.extents[index+1].offset {
+		s.extents[index].size += s.extents[index+1].size
+		s.extents = append(s.extents[:index+1], s.extents[index+2:]...)
+	}
+	if index > 0 && s.extents[index-1].offset+s.extents[index-1].size == released.offset {
+		s.extents[index-1].size += s.extents[index].size
+		s.extents = append(s.extents[:index], s.extents[index+1:]...)
+	}
+}
+
+func (s *store) data(b *blob) []byte {
+	return s.memory[b.offset : b.offset+b.size]
+}
+
+// payload describes the blob for clients, the map size follows the fake mmap
+// of vineyardd, which is 8 bytes larger than the mapped memory.
+func (s *store) payload(id common.ObjectID, b *blob) common.CreatedBuffer {
+	if b == nil {
+		return common.CreatedBuffer{ID: id, StoreFd: -1}
+	}
+	return common.CreatedBuffer{
+		ID:         id,
+		StoreFd:    s.fd,
+		DataOffset: b.offset,
+		DataSize:   b.size,
+		MapSize:    len(s.memory) + 8,
+	}
+}
+
+// findBlob finds the blob in the servers of the cluster, and returns the
+// server it lives in.
+func (s *Server) findBlob(id common.ObjectID) (*blob, *Server) {
+	for _, server := range s.servers {
+		if b, ok := server.store.blobs[id]; ok && !server.closed {
+			return b, server
+		}
+	}
+	return nil, nil
+}
+
+// createBlob allocates a blob, the empty blob is shared by all empty buffers.
+func (s *Server) createBlob(size int) (common.ObjectID, *blob, *response) {
+	if size == 0 {
+		return common.EmptyBlobID(), nil, nil
+	}
+	id := s.generateID(true)
+	b, ok := s.store.allocate(id, size)
+	if !ok {
+		return id, nil, errorReply(common.KNotEnoughMemory, "not enough memory to create a blob of %d bytes", size)
+	}
+	return id, b, nil
+}
+
+func (s *Server) handleCreateBuffer(c *session, message string) (*response, error) {
+	var request common.CreateBufferRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid create_buffer request: %v", err), nil
+	}
+	if !c.ipc {
+		return errorReply(common.KInvalid, "create_buffer is only supported for ipc clients"), nil
+	}
+	id, b, failed := s.createBlob(request.Size)
+	if failed != nil {
+		return failed, nil
+	}
+	resp := &response{reply: common.CreateBufferReply{
+		Type:    common.CREATE_BUFFER_REPLY,
+		ID:      id,
+		Created: s.store.payload(id, b),
+	}}
+	if b != nil {
+		resp.fds = c.sendFd(s.store.fd)
+	}
+	return resp, nil
+}
+
+// handleCreateRemoteBuffer receives the payload that follows the request,
+// which is compressed if requested.
+func (s *Server) handleCreateRemoteBuffer(c *session, message string) (*response, error) {
+	var request common.CreateRemoteBufferRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return nil, err
+	}
+	compress := request.Compress && s.supports(common.FeatureRPCCompression)
+	// the payload is received without the mutex
+	s.mutex.Unlock()
+	data := make([]byte, request.Size)
+	var err error
+	if compress {
+		err = recvCompressed(c.conn, data)
+	} else {
+		_, err = io.ReadFull(c.conn, data)
+	}
+	s.mutex.Lock()
+	if err != nil {
+		return nil, err
+	}
+	id, b, failed := s.createBlob(request.Size)
+	if failed != nil {
+		return failed, nil
+	}
+	if b != nil {
+		copy(s.store.data(b), data)
+		b.sealed = true
+	}
+	return &response{reply: common.CreateBufferReply{
+		Type:    common.CREATE_BUFFER_REPLY,
+		ID:      id,
+		Created: s.store.payload(id, b),
+	}}, nil
+}
+
+func (s *Server) handleSeal(c *session, message string) (*response, error) {
+	var request common.SealRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid seal request: %v", err), nil
+	}
+	b, ok := s.store.blobs[request.ObjectID]
+	if !ok {
+		return errorReply(common.KObjectNotExists, "blob not exists: %s", common.ObjectIDToString(request.ObjectID)), nil
+	}
+	if b.sealed {
+		return errorReply(common.KObjectSealed, "blob has been sealed: %s", common.ObjectIDToString(request.ObjectID)), nil
+	}
+	b.sealed = true
+	s.cond.Broadcast()
+	return &response{reply: common.SealReply{Type: common.SEAL_REPLY}}, nil
+}
+
+func (s *Server) handleDropBuffer(c *session, message string) (*response, error) {
+	var request common.DropBufferRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid drop_buffer request: %v", err), nil
+	}
+	if _, ok := s.store.blobs[request.ID]; !ok {
+		return errorReply(common.KObjectNotExists, "blob not exists: %s", common.ObjectIDToString(request.ID)), nil
+	}
+	s.store.free(request.ID)
+	return &response{reply: common.DropBufferReply{Type: common.DROP_BUFFER_REPLY}}, nil
+}
+
+// handleGetBuffers replies the payloads of existing blobs, followed by the
+// fds (for IPC clients) or the payloads (for RPC clients).
+func (s *Server) handleGetBuffers(c *session, message string) (*response, error) {
+	var request map[string]interface{}
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid get_buffers request: %v", err), nil
+	}
+	remote := request["type"] == common.GET_REMOTE_BUFFERS_REQUEST
+	if remote == c.ipc {
+		return errorReply(common.KInvalid, "%s is not supported for the client", request["type"]), nil
+	}
+	unsafe, _ := request["unsafe"].(bool)
+	num, _ := request["num"].(json.Number).Int64()
+	reply := common.GetBuffersReply{Type: common.GET_BUFFERS_REPLY, Payloads: []common.CreatedBuffer{}, Fds: []int{}}
+	var resp response
+	compress, _ := request["compress"].(bool)
+	resp.compress = compress && s.supports(common.FeatureRPCCompression)
+	for index := 0; index < int(num); index++ {
+		value, _ := request[strconv.Itoa(index)].(json.Number)
+		id, err := strconv.ParseUint(value.String(), 10, 64)
+		if err != nil {
+			return errorReply(common.KInvalid, "invalid blob id: '%s'", value), nil
+		}
+		b, ok := s.store.blobs[id]
+		if !ok && id != common.EmptyBlobID() {
+			continue
+		}
+		if ok && !b.sealed && !unsafe {
+			return errorReply(common.KObjectNotSealed, "blob not sealed: %s", common.ObjectIDToString(id)), nil
+		}
+		reply.Payloads = append(reply.Payloads, s.store.payload(id, b))
+		if b == nil || b.size == 0 {
+			continue
+		}
+		b.spilled = false
+		if remote {
+			resp.payloads = append(resp.payloads, append([]byte(nil), s.store.data(b)...))
+			continue
+		}
+		c.refs[id] = true
+		if fds := c.sendFd(s.store.fd); len(fds) > 0 {
+			reply.Fds = append(reply.Fds, fds...)
+			resp.fds = append(resp.fds, fds...)
+		}
+	}
+	resp.reply = reply
+	return &resp, nil
+}
+
+// handleIncreaseReferenceCount and handleRelease track the blobs referenced
+// by each client, like vineyardd, a blob is referenced at most once by a
+// client.
+func (s *Server) handleIncreaseReferenceCount(c *session, message string) (*response, error) {
+	var request common.IncreaseReferenceCountRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid increase_reference_count request: %v", err), nil
+	}
+	for _, id := range request.IDs {
+		if _, ok := s.store.blobs[id]; ok {
+			c.refs[id] = true
+		}
+	}
+	return &response{reply: common.ReplyHeader{Type: common.INCREASE_REFERENCE_COUNT_REPLY}}, nil
+}
+
+func (s *Server) handleRelease(c *session, message string) (*response, error) {
+	var request common.ReleaseRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid release request: %v", err), nil
+	}
+	if _, ok := s.store.blobs[request.ID]; !ok && request.ID != common.EmptyBlobID() {
+		return errorReply(common.KObjectNotExists, "blob not exists: %s", common.ObjectIDToString(request.ID)), nil
+	}
+	delete(c.refs, request.ID)
+	return &response{reply: common.ReplyHeader{Type: common.RELEASE_REPLY}}, nil
+}
+
+// inUse returns whether the blob is referenced by any client.
+func (s *Server) inUse(id common.ObjectID) bool {
+	for _, c := range s.conns {
+		if c.refs[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// localBlobs returns the blobs of the objects (or the blobs themselves) that
+// live in the server.
+func (s *Server) localBlobs(ids []common.ObjectID) ([]common.ObjectID, *response) {
+	var blobs []common.ObjectID
+	for _, id := range ids {
+		if _, ok := s.store.blobs[id]; ok {
+			blobs = append(blobs, id)
+			continue
+		}
+		if common.IsBlob(id) || s.objects[id] == nil {
+			return nil, errorReply(common.KObjectNotExists, "object not exists: %s", common.ObjectIDToString(id))
+		}
+		members, failed := s.localBlobs(s.members(id))
+		if failed != nil {
+			return nil, failed
+		}
+		blobs = append(blobs, members...)
+	}
+	return blobs, nil
+}
+
+// handleEvict spills the blobs that are neither in use nor pinned.
+func (s *Server) handleEvict(c *session, message string) (*response, error) {
+	var request common.EvictRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid evict request: %v", err), nil
+	}
+	blobs, failed := s.localBlobs(request.IDs)
+	if failed != nil {
+		return failed, nil
+	}
+	for _, id := range blobs {
+		if b := s.store.blobs[id]; b.sealed && !b.pinned && !s.inUse(id) {
+			b.spilled = true
+		}
+	}
+	return &response{reply: common.ReplyHeader{Type: common.EVICT_REPLY}}, nil
+}
+
+func (s *Server) handleLoad(c *session, message string) (*response, error) {
+	var request common.LoadRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid load request: %v", err), nil
+	}
+	blobs, failed := s.localBlobs(request.IDs)
+	if failed != nil {
+		return failed, nil
+	}
+	for _, id := range blobs {
+		b := s.store.blobs[id]
+		b.spilled = false
+		b.pinned = b.pinned || request.Pin
+	}
+	return &response{reply: common.ReplyHeader{Type: common.LOAD_REPLY}}, nil
+}
+
+func (s *Server) handleUnpin(c *session, message string) (*response, error) {
+	var request common.UnpinRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid unpin request: %v", err), nil
+	}
+	blobs, failed := s.localBlobs(request.IDs)
+	if failed != nil {
+		return failed, nil
+	}
+	for _, id := range blobs {
+		s.store.blobs[id].pinned = false
+	}
+	return &response{reply: common.ReplyHeader{Type: common.UNPIN_REPLY}}, nil
+}
+
+func (s *Server) handleIsSpilled(c *session, message string) (*response, error) {
+	var request common.IsSpilledRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid is_spilled request: %v", err), nil
+	}
+	b, ok := s.store.blobs[request.ID]
+	if !ok {
+		return errorReply(common.KObjectNotExists, "blob not exists: %s", common.ObjectIDToString(request.ID)), nil
+	}
+	return &response{reply: common.IsSpilledReply{Type: common.IS_SPILLED_REPLY, IsSpilled: b.spilled}}, nil
+}
+
+func (s *Server) handleIsInUse(c *session, message string) (*response, error) {
+	var request common.IsInUseRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid is_in_use request: %v", err), nil
+	}
+	if _, ok := s.store.blobs[request.ID]; !ok {
+		return errorReply(common.KObjectNotExists, "blob not exists: %s", common.ObjectIDToString(request.ID)), nil
+	}
+	return &response{reply: common.IsInUseReply{Type: common.IS_IN_USE_REPLY, IsInUse: s.inUse(request.ID)}}, nil
+}
@@ -0,0 +1,53 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vineyardtest
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStore_Allocate(t *testing.T) {
+	s, err := newStore(4 * alignment)
+	if err != nil {
+		t.Fatal("create store failed", err)
+	}
+	defer s.close()
+	for id := uint64(1); id <= 4; id++ {
+		if b, ok := s.allocate(id, 10); !ok || b.offset != int(id-1)*alignment {
+			t.Fatal("unexpected allocated blob", id, b)
+		}
+	}
+	if _, ok := s.allocate(5, 1); ok {
+		t.Fatal("the memory should be exhausted")
+	}
+
+	// the freed regions are merged with the adjacent ones
+	s.free(2)
+	s.free(4)
+	s.free(3)
+	if fmt.Sprint(s.extents) != "[{64 192}]" {
+		t.Error("unexpected free regions", s.extents)
+	}
+	if b, ok := s.allocate(6, 3*alignment); !ok || b.offset != alignment {
+		t.Error("unexpected allocated blob", b)
+	}
+	s.free(1)
+	s.free(6)
+	if fmt.Sprint(s.extents) != "[{0 256}]" {
+		t.Error("unexpected free regions", s.extents)
+	}
+}
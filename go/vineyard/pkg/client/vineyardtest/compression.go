@@ -0,0 +1,124 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vineyardtest
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The compressed payloads follow vineyardd: the payloads of a request (or a
+// reply) are a single zstd stream that is flushed after each payload and
+// never ends, and the stream is sent in chunks prefixed by their size. Like
+// the compressor of vineyardd, an empty chunk is sent before the data of each
+// payload, and after the payload during which flushThreshold bytes have been
+// accumulated.
+
+// flushThreshold is 64MB in vineyardd, which is lowered to be reached by
+// tests.
+const flushThreshold = 64 << 10
+
+type chunkWriter struct {
+	conn        net.Conn
+	accumulated int
+}
+
+func (w *chunkWriter) Write(data []byte) (int, error) {
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint64(header, uint64(len(data)))
+	if err := sendBytes(w.conn, header); err != nil {
+		return 0, err
+	}
+	if err := sendBytes(w.conn, data); err != nil {
+		return 0, err
+	}
+	w.accumulated += len(data)
+	return len(data), nil
+}
+
+type chunkReader struct {
+	conn      net.Conn
+	remaining int
+}
+
+func (r *chunkReader) Read(data []byte) (int, error) {
+	header := make([]byte, 8)
+	for r.remaining == 0 {
+		if _, err := io.ReadFull(r.conn, header); err != nil {
+			return 0, err
+		}
+		r.remaining = int(binary.LittleEndian.Uint64(header))
+	}
+	if len(data) > r.remaining {
+		data = data[:r.remaining]
+	}
+	n, err := r.conn.Read(data)
+	r.remaining -= n
+	return n, err
+}
+
+// sendCompressed sends the payloads in a single stream, the empty payloads
+// are skipped.
+func sendCompressed(conn net.Conn, payloads [][]byte) error {
+	writer := &chunkWriter{conn: conn}
+	encoder, err := zstd.NewWriter(writer,
+		zstd.WithEncoderConcurrency(1), zstd.WithEncoderCRC(false))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		encoder.Reset(io.Discard)
+		_ = encoder.Close()
+	}()
+	for _, payload := range payloads {
+		if len(payload) == 0 {
+			continue
+		}
+		if _, err := writer.Write(nil); err != nil {
+			return err
+		}
+		if _, err := encoder.Write(payload); err != nil {
+			return err
+		}
+		if err := encoder.Flush(); err != nil {
+			return err
+		}
+		if writer.accumulated >= flushThreshold {
+			writer.accumulated = 0
+			if _, err := writer.Write(nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// recvCompressed receives exactly len(data) bytes from a single stream.
+func recvCompressed(conn net.Conn, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	decoder, err := zstd.NewReader(&chunkReader{conn: conn}, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return err
+	}
+	defer decoder.Close()
+	_, err = io.ReadFull(decoder, data)
+	return err
+}
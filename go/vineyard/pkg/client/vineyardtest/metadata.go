@@ -0,0 +1,661 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vineyardtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+func (s *Server) handleRegister(c *session, message string) (*response, error) {
+	var request common.RegisterRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid register request: %v", err), nil
+	}
+	if request.Username != s.username || request.Password != s.password {
+		return errorReply(common.KConnectionError, "authentication failed for user '%s'", request.Username), nil
+	}
+	return &response{reply: common.RegisterReply{
+		Type:        common.REGISTER_REPLY,
+		InstanceID:  s.instanceID,
+		IPCSocket:   s.IPCSocket(),
+		RPCEndpoint: s.RPCEndpoint(),
+		Version:     s.version,
+		SessionID:   s.sessionID,
+		StoreMatch:  request.StoreType == s.storeType || request.StoreType == "" && s.storeType == common.NormalStore,
+	}}, nil
+}
+
+// handleNewSession starts a server with its own names, metadata and bulk
+// store as the session, which requires the same credentials.
+func (s *Server) handleNewSession(c *session, message string) (*response, error) {
+	var request common.NewSessionRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid new_session request: %v", err), nil
+	}
+	if s.sessionID != common.RootSessionID() {
+		return errorReply(common.KInvalid, "sessions can only be created in the root session"), nil
+	}
+	if request.BulkStoreType != common.NormalStore && request.BulkStoreType != common.PlasmaStore {
+		return errorReply(common.KInvalid, "invalid bulk store type: '%s'", request.BulkStoreType), nil
+	}
+	session, err := newServer(newCluster(), []Option{
+		WithInstanceID(s.instanceID),
+		WithVersion(s.version),
+		WithMemory(s.memory),
+		WithStoreType(request.BulkStoreType),
+		WithCredentials(s.username, s.password),
+	})
+	if err != nil {
+		return errorReply(common.KIOError, "failed to start the session: %v", err), nil
+	}
+	s.sessions = append(s.sessions, session)
+	session.sessionID = common.SessionID(len(s.sessions))
+	return &response{reply: common.NewSessionReply{
+		Type:       common.NEW_SESSION_REPLY,
+		SocketPath: session.IPCSocket(),
+	}}, nil
+}
+
+// handleDeleteSession closes the session once the reply is sent.
+func (s *Server) handleDeleteSession(c *session, message string) (*response, error) {
+	if s.sessionID == common.RootSessionID() {
+		return errorReply(common.KInvalid, "the root session cannot be deleted"), nil
+	}
+	return &response{reply: common.ReplyHeader{Type: common.DELETE_SESSION_REPLY}, shutdown: true}, nil
+}
+
+func (s *Server) handleClusterMeta(c *session, message string) (*response, error) {
+	hostname, _ := os.Hostname()
+	meta := make(map[string]interface{})
+	for _, server := range s.servers {
+		if server.closed {
+			continue
+		}
+		meta[fmt.Sprintf("i%d", server.instanceID)] = map[string]interface{}{
+			"instance_id":  server.instanceID,
+			"hostname":     hostname,
+			"nodename":     hostname,
+			"ipc_socket":   server.IPCSocket(),
+			"rpc_endpoint": server.RPCEndpoint(),
+		}
+	}
+	return &response{reply: map[string]interface{}{
+		"type": common.CLUSTER_META_REPLY,
+		"meta": meta,
+	}}, nil
+}
+
+// handleInstanceStatus reports the requests waiting for objects, names or
+// stream chunks as deferred requests.
+func (s *Server) handleInstanceStatus(c *session, message string) (*response, error) {
+	status := common.InstanceStatus{
+		InstanceID:       s.instanceID,
+		Deployment:       "local",
+		MemoryUsage:      int64(len(s.store.memory)),
+		MemoryLimit:      int64(len(s.store.memory)),
+		DeferredRequests: s.waiters,
+	}
+	for _, free := range s.store.extents {
+		status.MemoryUsage -= int64(free.size)
+	}
+	for _, conn := range s.conns {
+		if conn.ipc {
+			status.IPCConnections++
+		} else {
+			status.RPCConnections++
+		}
+	}
+	return &response{reply: common.InstanceStatusReply{
+		Type: common.INSTANCE_STATUS_REPLY,
+		Meta: status,
+	}}, nil
+}
+
+func (s *Server) handlePutName(c *session, message string) (*response, error) {
+	var request common.PutNameRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid put_name request: %v", err), nil
+	}
+	if _, ok := s.objects[request.ReqObjectID]; !ok {
+		return errorReply(common.KObjectNotExists, "object not exists: %s",
+			common.ObjectIDToString(request.ReqObjectID)), nil
+	}
+	s.names[request.Name] = request.ReqObjectID
+	s.cond.Broadcast()
+	return &response{reply: common.PutNameReply{Type: common.PUT_NAME_REPLY}}, nil
+}
+
+func (s *Server) handleGetName(c *session, message string) (*response, error) {
+	var request common.GetNameRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid get_name request: %v", err), nil
+	}
+	for {
+		if id, ok := s.names[request.Name]; ok {
+			return &response{reply: common.GetNameReply{Type: common.GET_NAME_REPLY, RepObjectID: id}}, nil
+		}
+		if !request.Wait {
+			return errorReply(common.KObjectNotExists, "name not exists: %s", request.Name), nil
+		}
+		if err := s.wait(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *Server) handleDropName(c *session, message string) (*response, error) {
+	var request common.DropNameRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid drop_name request: %v", err), nil
+	}
+	delete(s.names, request.Name)
+	return &response{reply: common.DropNameReply{Type: common.DROP_NAME_REPLY}}, nil
+}
+
+func (s *Server) handleListName(c *session, message string) (*response, error) {
+	var request common.ListNameRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid list_name request: %v", err), nil
+	}
+	matcher, err := newMatcher(request.Pattern, request.Regex)
+	if err != nil {
+		return errorReply(common.KInvalid, "invalid pattern '%s': %v", request.Pattern, err), nil
+	}
+	names := make([]string, 0, len(s.names))
+	for name := range s.names {
+		if matcher(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if request.Limit > 0 && len(names) > request.Limit {
+		names = names[:request.Limit]
+	}
+	reply := common.ListNameReply{Type: common.LIST_NAME_REPLY, Names: make(map[string]common.ObjectID)}
+	for _, name := range names {
+		reply.Names[name] = s.names[name]
+	}
+	return &response{reply: reply}, nil
+}
+
+// newMatcher matches names (or typenames) by the glob pattern like
+// vineyardd, or by the regular expression that matches the whole name.
+func newMatcher(pattern string, regex bool) (func(string) bool, error) {
+	if regex {
+		expr, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, err
+		}
+		return expr.MatchString, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	return func(name string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}, nil
+}
+
+func (s *Server) generateID(blob bool) common.ObjectID {
+	id := s.nextID
+	s.nextID++
+	if blob {
+		return id | common.EmptyBlobID()
+	}
+	// distinguishes the ids from those of other test servers
+	return id | s.instanceID<<48
+}
+
+func (s *Server) handleCreateData(c *session, message string) (*response, error) {
+	var request common.CreateDataRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid create_data request: %v", err), nil
+	}
+	tree, ok := request.Content.(map[string]interface{})
+	if !ok || tree["typename"] == nil {
+		return errorReply(common.KMetaTreeInvalid, "the metadata should be a tree with typename"), nil
+	}
+	if err := s.checkMembers(tree); err != nil {
+		return errorReply(common.KObjectNotExists, "%v", err), nil
+	}
+	id, signature := s.generateID(false), s.generateID(false)
+	tree["id"] = common.ObjectIDToString(id)
+	tree["signature"] = signature
+	tree["instance_id"] = s.instanceID
+	if _, ok := tree["transient"]; !ok {
+		tree["transient"] = true
+	}
+	s.objects[id] = tree
+	s.cond.Broadcast()
+	return &response{reply: common.CreateDataReply{
+		Type:       common.CREATE_DATA_REPLY,
+		ID:         id,
+		Signature:  signature,
+		InstanceID: s.instanceID,
+	}}, nil
+}
+
+// checkMembers checks that the members that are only referenced by id exist.
+func (s *Server) checkMembers(tree map[string]interface{}) error {
+	for _, value := range tree {
+		member, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if member["typename"] != nil {
+			if err := s.checkMembers(member); err != nil {
+				return err
+			}
+			continue
+		}
+		id, err := treeID(member)
+		if err != nil {
+			return err
+		}
+		if !s.exists(id) {
+			return fmt.Errorf("the member %s doesn't exist", common.ObjectIDToString(id))
+		}
+	}
+	return nil
+}
+
+func treeID(tree map[string]interface{}) (common.ObjectID, error) {
+	id, ok := tree["id"].(string)
+	if !ok || len(id) < 2 {
+		return common.InvalidObjectID(), fmt.Errorf("metadata doesn't contain a valid id: %v", tree["id"])
+	}
+	return common.ObjectIDFromString(id)
+}
+
+func (s *Server) exists(id common.ObjectID) bool {
+	if id == common.EmptyBlobID() {
+		return true
+	}
+	if common.IsBlob(id) {
+		b, _ := s.findBlob(id)
+		return b != nil
+	}
+	return s.objects[id] != nil
+}
+
+// metadata returns the metadata of the object, where the members are
+// replaced by their latest metadata.
+func (s *Server) metadata(id common.ObjectID) (map[string]interface{}, bool) {
+	if common.IsBlob(id) {
+		if id == common.EmptyBlobID() {
+			return s.blobMeta(id, 0, s.instanceID), true
+		}
+		if b, owner := s.findBlob(id); b != nil {
+			return s.blobMeta(id, b.size, owner.instanceID), true
+		}
+		return nil, false
+	}
+	tree, ok := s.objects[id]
+	if !ok {
+		return nil, false
+	}
+	return s.expand(tree), true
+}
+
+func (s *Server) blobMeta(id common.ObjectID, size int, instanceID common.InstanceID) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          common.ObjectIDToString(id),
+		"typename":    "vineyard::Blob",
+		"length":      size,
+		"nbytes":      size,
+		"instance_id": instanceID,
+		"transient":   !s.persisted[id],
+	}
+}
+
+func (s *Server) expand(tree map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(tree))
+	for key, value := range tree {
+		member, ok := value.(map[string]interface{})
+		if !ok {
+			result[key] = value
+			continue
+		}
+		if id, err := treeID(member); err == nil {
+			if meta, ok := s.metadata(id); ok {
+				result[key] = meta
+				continue
+			}
+		}
+		result[key] = s.expand(member)
+	}
+	return result
+}
+
+// members returns the ids of members of the object.
+func (s *Server) members(id common.ObjectID) []common.ObjectID {
+	var members []common.ObjectID
+	for _, value := range s.objects[id] {
+		if member, ok := value.(map[string]interface{}); ok {
+			if memberID, err := treeID(member); err == nil {
+				members = append(members, memberID)
+			}
+		}
+	}
+	return members
+}
+
+func (s *Server) handleGetData(c *session, message string) (*response, error) {
+	var request common.GetDataRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid get_data request: %v", err), nil
+	}
+	for {
+		reply := common.GetDataReply{Type: common.GET_DATA_REPLY, Content: make(map[string]map[string]interface{})}
+		missing := common.InvalidObjectID()
+		for _, id := range request.ID {
+			// syncing the metadata asks for the invalid object
+			if id == common.InvalidObjectID() {
+				continue
+			}
+			meta, ok := s.metadata(id)
+			if !ok {
+				missing = id
+				break
+			}
+			reply.Content[common.ObjectIDToString(id)] = meta
+		}
+		if missing == common.InvalidObjectID() {
+			return &response{reply: reply}, nil
+		}
+		if !request.Wait {
+			return errorReply(common.KObjectNotExists, "object not exists: %s", common.ObjectIDToString(missing)), nil
+		}
+		if err := s.wait(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *Server) handleListData(c *session, message string) (*response, error) {
+	var request common.ListDataRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid list_data request: %v", err), nil
+	}
+	matcher, err := newMatcher(request.Pattern, request.Regex)
+	if err != nil {
+		return errorReply(common.KInvalid, "invalid pattern '%s': %v", request.Pattern, err), nil
+	}
+	ids := make([]common.ObjectID, 0, len(s.objects))
+	for id, tree := range s.objects {
+		if typeName, _ := tree["typename"].(string); matcher(typeName) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if request.Limit > 0 && len(ids) > request.Limit {
+		ids = ids[:request.Limit]
+	}
+	reply := common.GetDataReply{Type: common.GET_DATA_REPLY, Content: make(map[string]map[string]interface{})}
+	for _, id := range ids {
+		reply.Content[common.ObjectIDToString(id)] = s.expand(s.objects[id])
+	}
+	return &response{reply: reply}, nil
+}
+
+func (s *Server) handleExists(c *session, message string) (*response, error) {
+	var request common.ExistsRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid exists request: %v", err), nil
+	}
+	return &response{reply: common.ExistsReply{Type: common.EXISTS_REPLY, Exists: s.exists(request.ID)}}, nil
+}
+
+func (s *Server) handlePersist(c *session, message string) (*response, error) {
+	var request common.PersistRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid persist request: %v", err), nil
+	}
+	if !s.exists(request.ID) {
+		return errorReply(common.KObjectNotExists, "object not exists: %s", common.ObjectIDToString(request.ID)), nil
+	}
+	s.persist(request.ID)
+	return &response{reply: common.PersisReply{Type: common.PERSIST_REPLY}}, nil
+}
+
+// persist persists the object and its members.
+func (s *Server) persist(id common.ObjectID) {
+	if s.persisted[id] {
+		return
+	}
+	s.persisted[id] = true
+	if tree, ok := s.objects[id]; ok {
+		tree["transient"] = false
+		for _, member := range s.members(id) {
+			s.persist(member)
+		}
+	}
+}
+
+func (s *Server) handleIfPersist(c *session, message string) (*response, error) {
+	var request common.IfPersistRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid if_persist request: %v", err), nil
+	}
+	if !s.exists(request.ID) {
+		return errorReply(common.KObjectNotExists, "object not exists: %s", common.ObjectIDToString(request.ID)), nil
+	}
+	return &response{reply: common.IfPersistReply{Type: common.IF_PERSIST_REPLY, Persist: s.persisted[request.ID]}}, nil
+}
+
+// handleLabel merges the labels into the "__labels" of the object, which is
+// a json string of the labels.
+func (s *Server) handleLabel(c *session, message string) (*response, error) {
+	var request common.LabelRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid label request: %v", err), nil
+	}
+	if len(request.Keys) != len(request.Values) {
+		return errorReply(common.KInvalid, "the numbers of label keys and values are not matched"), nil
+	}
+	tree, ok := s.objects[request.ID]
+	if !ok {
+		return errorReply(common.KObjectNotExists, "object not exists: %s", common.ObjectIDToString(request.ID)), nil
+	}
+	labels := make(map[string]string)
+	if encoded, ok := tree["__labels"].(string); ok {
+		if err := json.Unmarshal([]byte(encoded), &labels); err != nil {
+			return errorReply(common.KMetaTreeInvalid, "invalid labels of object %s: %v", common.ObjectIDToString(request.ID), err), nil
+		}
+	}
+	for index, key := range request.Keys {
+		labels[key] = request.Values[index]
+	}
+	encoded, _ := json.Marshal(labels)
+	tree["__labels"] = string(encoded)
+	return &response{reply: common.ReplyHeader{Type: common.LABEL_REPLY}}, nil
+}
+
+func (s *Server) handleShallowCopy(c *session, message string) (*response, error) {
+	var request common.ShallowCopyRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid shallow_copy request: %v", err), nil
+	}
+	tree, ok := s.objects[request.ID]
+	if !ok {
+		return errorReply(common.KObjectNotExists, "object not exists: %s", common.ObjectIDToString(request.ID)), nil
+	}
+	copied := make(map[string]interface{}, len(tree)+len(request.Extra))
+	for key, value := range tree {
+		copied[key] = value
+	}
+	for key, value := range request.Extra {
+		copied[key] = value
+	}
+	id := s.generateID(false)
+	copied["id"] = common.ObjectIDToString(id)
+	copied["transient"] = true
+	s.objects[id] = copied
+	s.cond.Broadcast()
+	return &response{reply: common.ShallowCopyReply{Type: common.SHALLOW_COPY_REPLY, TargetID: id}}, nil
+}
+
+// handleMigrateObject copies the object and the blobs of other instances
+// into this instance, the copy is persisted like vineyardd does.
+func (s *Server) handleMigrateObject(c *session, message string) (*response, error) {
+	var request common.MigrateObjectRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid migrate_object request: %v", err), nil
+	}
+	tree, ok := s.objects[request.ObjectID]
+	if !ok {
+		return errorReply(common.KObjectNotExists, "object not exists: %s", common.ObjectIDToString(request.ObjectID)), nil
+	}
+	if tree["instance_id"] == s.instanceID {
+		return &response{reply: common.MigrateObjectReply{Type: common.MIGRATE_OBJECT_REPLY, ObjectID: request.ObjectID}}, nil
+	}
+	copied, failed := s.migrate(tree)
+	if failed != nil {
+		return failed, nil
+	}
+	id := s.generateID(false)
+	copied["id"] = common.ObjectIDToString(id)
+	copied["signature"] = s.generateID(false)
+	copied["instance_id"] = s.instanceID
+	s.objects[id] = copied
+	s.persist(id)
+	s.cond.Broadcast()
+	return &response{reply: common.MigrateObjectReply{Type: common.MIGRATE_OBJECT_REPLY, ObjectID: id}}, nil
+}
+
+// migrate copies the tree, where the members that are objects are migrated
+// as new objects, and the blobs of other instances are copied.
+func (s *Server) migrate(tree map[string]interface{}) (map[string]interface{}, *response) {
+	copied := make(map[string]interface{}, len(tree))
+	for key, value := range tree {
+		member, ok := value.(map[string]interface{})
+		if !ok {
+			copied[key] = value
+			continue
+		}
+		id, err := treeID(member)
+		switch {
+		case err == nil && common.IsBlob(id):
+			if b, owner := s.findBlob(id); b != nil && owner != s {
+				blobID, local, failed := s.createBlob(b.size)
+				if failed != nil {
+					return nil, failed
+				}
+				copy(s.store.data(local), owner.store.data(b))
+				local.sealed = true
+				id = blobID
+			}
+			copied[key] = map[string]interface{}{"id": common.ObjectIDToString(id)}
+		case err == nil && s.objects[id] != nil:
+			migrated, failed := s.migrate(s.objects[id])
+			if failed != nil {
+				return nil, failed
+			}
+			memberID := s.generateID(false)
+			migrated["id"] = common.ObjectIDToString(memberID)
+			migrated["instance_id"] = s.instanceID
+			s.objects[memberID] = migrated
+			copied[key] = map[string]interface{}{"id": migrated["id"]}
+		default:
+			migrated, failed := s.migrate(member)
+			if failed != nil {
+				return nil, failed
+			}
+			copied[key] = migrated
+		}
+	}
+	return copied, nil
+}
+
+func (s *Server) handleDelData(c *session, message string) (*response, error) {
+	var request common.DelDataRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid del_data request: %v", err), nil
+	}
+	deleted := s.delete(request.ID, request.Force, request.Deep)
+	if request.Type == common.DEL_DATA_WITH_FEEDBACKS_REQUEST {
+		return &response{reply: common.DelDataWithFeedbacksReply{
+			Type:       common.DEL_DATA_WITH_FEEDBACKS_REPLY,
+			DeletedIDs: deleted,
+		}}, nil
+	}
+	return &response{reply: common.ReplyHeader{Type: common.DEL_DATA_REPLY}}, nil
+}
+
+// delete deletes the objects and returns the ids of deleted objects. Objects
+// that are referenced by others are kept unless force is true, in which case
+// the referencing objects are deleted as well. Members are deleted as well if
+// deep is true.
+func (s *Server) delete(ids []common.ObjectID, force, deep bool) []common.ObjectID {
+	targets := make(map[common.ObjectID]bool)
+	var visit func(id common.ObjectID)
+	visit = func(id common.ObjectID) {
+		if targets[id] || id == common.EmptyBlobID() || !s.exists(id) {
+			return
+		}
+		targets[id] = true
+		if deep {
+			for _, member := range s.members(id) {
+				visit(member)
+			}
+		}
+	}
+	for _, id := range ids {
+		visit(id)
+	}
+	for changed := true; changed; {
+		changed = false
+		for id := range s.objects {
+			if targets[id] {
+				continue
+			}
+			for _, member := range s.members(id) {
+				if !targets[member] {
+					continue
+				}
+				if force {
+					targets[id] = true
+				} else {
+					delete(targets, member)
+				}
+				changed = true
+			}
+		}
+	}
+
+	deleted := make([]common.ObjectID, 0, len(targets))
+	for id := range targets {
+		if common.IsBlob(id) {
+			if _, owner := s.findBlob(id); owner != nil {
+				owner.store.free(id)
+			}
+		} else {
+			delete(s.objects, id)
+		}
+		delete(s.persisted, id)
+		deleted = append(deleted, id)
+	}
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i] < deleted[j] })
+	return deleted
+}
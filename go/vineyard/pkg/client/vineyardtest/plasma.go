@@ -0,0 +1,211 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vineyardtest
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+// plasmaObject is a buffer in the plasma store, which is backed by a blob.
+type plasmaObject struct {
+	id     common.ObjectID
+	sealed bool
+	refs   int
+	// deleted is set if the object is deleted while it is still in use, it
+	// is freed once the last reference is released.
+	deleted bool
+}
+
+// lookupPlasma returns the object that hasn't been deleted, or the reply of
+// failure.
+func (s *Server) lookupPlasma(id common.PlasmaID) (*plasmaObject, *response) {
+	if s.storeType != common.PlasmaStore {
+		return nil, errorReply(common.KInvalid, "the bulk store is not plasma")
+	}
+	object, ok := s.plasma[id]
+	if !ok || object.deleted {
+		return nil, errorReply(common.KObjectNotExists, "plasma object not exists: %s", id)
+	}
+	return object, nil
+}
+
+func (s *Server) plasmaPayload(id common.PlasmaID, object *plasmaObject) common.PlasmaPayload {
+	created := s.store.payload(object.id, s.store.blobs[object.id])
+	return common.PlasmaPayload{
+		PlasmaID:   id,
+		ID:         object.id,
+		PlasmaSize: created.DataSize,
+		StoreFd:    created.StoreFd,
+		DataOffset: created.DataOffset,
+		DataSize:   created.DataSize,
+		MapSize:    created.MapSize,
+		RefCount:   object.refs,
+		IsSealed:   object.sealed,
+	}
+}
+
+// freePlasmaObject frees the blob of the object, the empty blob is shared and
+// never freed.
+func (s *Server) freePlasmaObject(id common.PlasmaID, object *plasmaObject) {
+	s.store.free(object.id)
+	delete(s.plasma, id)
+}
+
+// handleCreateBufferByPlasma creates the object with a reference of the
+// creator, which should be released once it is sealed.
+func (s *Server) handleCreateBufferByPlasma(c *session, message string) (*response, error) {
+	var request common.CreateBufferByPlasmaRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid create_buffer_by_plasma request: %v", err), nil
+	}
+	if s.storeType != common.PlasmaStore {
+		return errorReply(common.KInvalid, "the bulk store is not plasma"), nil
+	}
+	if !c.ipc {
+		return errorReply(common.KInvalid, "create_buffer_by_plasma is only supported for ipc clients"), nil
+	}
+	if _, ok := s.plasma[request.PlasmaID]; ok {
+		return errorReply(common.KObjectExists, "plasma object exists: %s", request.PlasmaID), nil
+	}
+	id, b, failed := s.createBlob(request.Size)
+	if failed != nil {
+		return failed, nil
+	}
+	object := &plasmaObject{id: id, refs: 1}
+	s.plasma[request.PlasmaID] = object
+	reply := common.CreateBufferByPlasmaReply{
+		Type:    common.CREATE_BUFFER_BY_PLASMA_REPLY,
+		ID:      id,
+		Created: s.plasmaPayload(request.PlasmaID, object),
+		Fd:      -1,
+	}
+	resp := &response{}
+	if b != nil {
+		reply.Fd = s.store.fd
+		resp.fds = c.sendFd(s.store.fd)
+	}
+	resp.reply = reply
+	return resp, nil
+}
+
+// handleGetBuffersByPlasma replies the payloads of the sealed objects (or
+// all existing objects if unsafe), each of them gets a reference.
+func (s *Server) handleGetBuffersByPlasma(c *session, message string) (*response, error) {
+	// the ids are decoded as raw messages, as the raw bytes of ids would be
+	// replaced if they are decoded as strings.
+	var request map[string]json.RawMessage
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid get_buffers_by_plasma request: %v", err), nil
+	}
+	if s.storeType != common.PlasmaStore {
+		return errorReply(common.KInvalid, "the bulk store is not plasma"), nil
+	}
+	if !c.ipc {
+		return errorReply(common.KInvalid, "get_buffers_by_plasma is only supported for ipc clients"), nil
+	}
+	var unsafe bool
+	var num int
+	if err := json.Unmarshal(request["unsafe"], &unsafe); err != nil {
+		return errorReply(common.KInvalid, "invalid get_buffers_by_plasma request: %v", err), nil
+	}
+	if err := json.Unmarshal(request["num"], &num); err != nil {
+		return errorReply(common.KInvalid, "invalid get_buffers_by_plasma request: %v", err), nil
+	}
+	reply := common.GetBuffersByPlasmaReply{Type: common.GET_BUFFERS_BY_PLASMA_REPLY, Payloads: []common.PlasmaPayload{}}
+	var resp response
+	for index := 0; index < num; index++ {
+		var id common.PlasmaID
+		if err := json.Unmarshal(request[strconv.Itoa(index)], &id); err != nil {
+			return errorReply(common.KInvalid, "%v", err), nil
+		}
+		object, ok := s.plasma[id]
+		if !ok || object.deleted || !object.sealed && !unsafe {
+			continue
+		}
+		object.refs++
+		payload := s.plasmaPayload(id, object)
+		reply.Payloads = append(reply.Payloads, payload)
+		if payload.DataSize > 0 {
+			resp.fds = append(resp.fds, c.sendFd(s.store.fd)...)
+		}
+	}
+	reply.Num = len(reply.Payloads)
+	resp.reply = reply
+	return &resp, nil
+}
+
+func (s *Server) handlePlasmaSeal(c *session, message string) (*response, error) {
+	var request common.PlasmaSealRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid plasma_seal request: %v", err), nil
+	}
+	object, failed := s.lookupPlasma(request.PlasmaID)
+	if failed != nil {
+		return failed, nil
+	}
+	if object.sealed {
+		return errorReply(common.KObjectSealed, "plasma object has been sealed: %s", request.PlasmaID), nil
+	}
+	object.sealed = true
+	if b, ok := s.store.blobs[object.id]; ok {
+		b.sealed = true
+	}
+	s.cond.Broadcast()
+	return &response{reply: common.ReplyHeader{Type: common.PLASMA_SEAL_REPLY}}, nil
+}
+
+func (s *Server) handlePlasmaRelease(c *session, message string) (*response, error) {
+	var request common.PlasmaReleaseRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid plasma_release request: %v", err), nil
+	}
+	if s.storeType != common.PlasmaStore {
+		return errorReply(common.KInvalid, "the bulk store is not plasma"), nil
+	}
+	// the objects that have been deleted could still be released
+	object, ok := s.plasma[request.PlasmaID]
+	if !ok {
+		return errorReply(common.KObjectNotExists, "plasma object not exists: %s", request.PlasmaID), nil
+	}
+	if object.refs == 0 {
+		return errorReply(common.KInvalid, "plasma object is not in use: %s", request.PlasmaID), nil
+	}
+	if object.refs--; object.refs == 0 && object.deleted {
+		s.freePlasmaObject(request.PlasmaID, object)
+	}
+	return &response{reply: common.ReplyHeader{Type: common.PLASMA_RELEASE_REPLY}}, nil
+}
+
+// handlePlasmaDeleteData deletes the object once it is no longer in use.
+func (s *Server) handlePlasmaDeleteData(c *session, message string) (*response, error) {
+	var request common.PlasmaDeleteDataRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid plasma_delete_data request: %v", err), nil
+	}
+	object, failed := s.lookupPlasma(request.PlasmaID)
+	if failed != nil {
+		return failed, nil
+	}
+	if object.refs > 0 {
+		object.deleted = true
+	} else {
+		s.freePlasmaObject(request.PlasmaID, object)
+	}
+	return &response{reply: common.ReplyHeader{Type: common.PLASMA_DELETE_DATA_REPLY}}, nil
+}
@@ -0,0 +1,564 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package vineyardtest provides an in-memory vineyard server for tests, which
+// speaks the same protocol as vineyardd over a unix socket (for IPC clients)
+// and a tcp port (for RPC clients), thus clients could be tested without a
+// running vineyardd.
+//
+// The server keeps names, metadata and streams in memory, and the payloads
+// of blobs in a memfd that is shared with IPC clients. Errors and latency
+// could be injected to requests by Inject. Servers started by NewCluster
+// share the names and metadata, like vineyardd instances that connect to the
+// same etcd, while the sessions created by clients are servers of their own.
+package vineyardtest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// DefaultVersion is the version the server reports on register.
+	DefaultVersion = "0.11.0"
+	// DefaultMemory is the size of the shared memory for blobs.
+	DefaultMemory = 64 << 20
+)
+
+type Option func(*Server)
+
+func WithInstanceID(instanceID common.InstanceID) Option {
+	return func(s *Server) {
+		s.instanceID = instanceID
+	}
+}
+
+func WithVersion(version string) Option {
+	return func(s *Server) {
+		s.version = version
+	}
+}
+
+// WithStoreType sets the bulk store of the server, i.e., common.NormalStore
+// (the default) or common.PlasmaStore, clients are registered only if they
+// ask for the same store type.
+func WithStoreType(storeType string) Option {
+	return func(s *Server) {
+		s.storeType = storeType
+	}
+}
+
+// WithCredentials requires clients to register with the username and the
+// password.
+func WithCredentials(username, password string) Option {
+	return func(s *Server) {
+		s.username = username
+		s.password = password
+	}
+}
+
+// WithMemory sets the size of the shared memory for blobs, creating blobs
+// fails with common.ErrNotEnoughMemory once it is exhausted.
+func WithMemory(size int) Option {
+	return func(s *Server) {
+		s.memory = size
+	}
+}
+
+// Fault is injected to requests of some type, see Server.Inject.
+type Fault struct {
+	// Latency delays the handling of the request.
+	Latency time.Duration
+	// Code is replied (with Message) instead of handling the request if it
+	// is not common.KOK.
+	Code    int
+	Message string
+	// Disconnect closes the connection instead of replying.
+	Disconnect bool
+	// Times is the number of requests the fault applies to, a non-positive
+	// value means all following requests.
+	Times int
+}
+
+// Server is an in-memory vineyard server, see NewServer.
+type Server struct {
+	*cluster
+
+	instanceID common.InstanceID
+	version    string
+	memory     int
+	storeType  string
+	username   string
+	password   string
+	sessionID  common.SessionID
+
+	dir         string
+	ipcListener net.Listener
+	rpcListener net.Listener
+	waitGroup   sync.WaitGroup
+
+	// the following states are guarded by the mutex of cluster. conns maps
+	// the connections to their sessions, waiters counts the requests that
+	// are waiting on cond, and sessions are the sessions created by
+	// new_session requests.
+	closed   bool
+	conns    map[net.Conn]*session
+	waiters  int
+	sessions []*Server
+	faults   map[string][]*Fault
+	streams  map[common.ObjectID]*stream
+	plasma   map[common.PlasmaID]*plasmaObject
+	store    *store
+}
+
+// cluster is the states shared by the servers in a cluster. The mutex guards
+// the states of the cluster and its servers, cond is broadcast when objects,
+// names or streams change, or a server is closed.
+type cluster struct {
+	mutex     sync.Mutex
+	cond      *sync.Cond
+	servers   []*Server
+	nextID    uint64
+	names     map[string]common.ObjectID
+	objects   map[common.ObjectID]map[string]interface{}
+	persisted map[common.ObjectID]bool
+}
+
+func newCluster() *cluster {
+	c := &cluster{
+		nextID:    1,
+		names:     make(map[string]common.ObjectID),
+		objects:   make(map[common.ObjectID]map[string]interface{}),
+		persisted: make(map[common.ObjectID]bool),
+	}
+	c.cond = sync.NewCond(&c.mutex)
+	return c
+}
+
+// NewServer starts a server that listens on a unix socket in a temporary
+// directory and a tcp port of localhost, the server should be closed by
+// Close.
+func NewServer(options ...Option) (*Server, error) {
+	return newServer(newCluster(), options)
+}
+
+// NewCluster starts size servers that share the names and metadata, whose
+// instance ids are 0 to size-1. Blobs and streams live in the server they
+// are created in, and the servers should be closed by Close.
+func NewCluster(size int, options ...Option) ([]*Server, error) {
+	c := newCluster()
+	servers := make([]*Server, 0, size)
+	for index := 0; index < size; index++ {
+		instanceOptions := append(options[:len(options):len(options)], WithInstanceID(common.InstanceID(index)))
+		s, err := newServer(c, instanceOptions)
+		if err != nil {
+			for _, s := range servers {
+				s.Close()
+			}
+			return nil, err
+		}
+		servers = append(servers, s)
+	}
+	return servers, nil
+}
+
+func newServer(c *cluster, options []Option) (*Server, error) {
+	s := &Server{
+		cluster:    c,
+		instanceID: 0,
+		version:    DefaultVersion,
+		memory:     DefaultMemory,
+		storeType:  common.NormalStore,
+		conns:      make(map[net.Conn]*session),
+		faults:     make(map[string][]*Fault),
+		streams:    make(map[common.ObjectID]*stream),
+		plasma:     make(map[common.PlasmaID]*plasmaObject),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	var err error
+	if s.store, err = newStore(s.memory); err != nil {
+		return nil, err
+	}
+	if s.dir, err = os.MkdirTemp("", "vineyardtest"); err != nil {
+		s.store.close()
+		return nil, err
+	}
+	if s.ipcListener, err = net.Listen("unix", filepath.Join(s.dir, "vineyard.sock")); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if s.rpcListener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.mutex.Lock()
+	s.servers = append(s.servers, s)
+	s.mutex.Unlock()
+	s.waitGroup.Add(2)
+	go s.accept(s.ipcListener, true)
+	go s.accept(s.rpcListener, false)
+	return s, nil
+}
+
+func (s *Server) InstanceID() common.InstanceID {
+	return s.instanceID
+}
+
+// IPCSocket returns the unix socket for IPCClient.Connect.
+func (s *Server) IPCSocket() string {
+	return s.ipcListener.Addr().String()
+}
+
+// RPCEndpoint returns the "host:port" for RPCClient.Connect.
+func (s *Server) RPCEndpoint() string {
+	return s.rpcListener.Addr().String()
+}
+
+// Inject injects the fault to the following requests of the type, e.g.,
+// common.GET_DATA_REQUEST. Faults injected to the same type apply in order.
+func (s *Server) Inject(requestType string, fault Fault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.faults[requestType] = append(s.faults[requestType], &fault)
+}
+
+// ClearFaults removes the faults that haven't been applied.
+func (s *Server) ClearFaults() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.faults = make(map[string][]*Fault)
+}
+
+// Upgrade changes the version the server reports, and closes the connections
+// of all clients, as if the server has been restarted with the version.
+func (s *Server) Upgrade(version string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.version = version
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// supports returns whether the reported version supports the feature, like
+// vineyardd of the version, the caller must hold the mutex.
+func (s *Server) supports(feature common.Feature) bool {
+	version, err := common.ParseVersion(s.version)
+	return err == nil && common.SupportsFeature(version, feature)
+}
+
+// DisconnectAll closes the connections of all clients, as if the server has
+// restarted, the states are kept.
+func (s *Server) DisconnectAll() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Close stops the server (and the sessions created in it) and closes the
+// connections of clients.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.cond.Broadcast()
+	sessions := s.sessions
+	s.mutex.Unlock()
+	for _, session := range sessions {
+		session.Close()
+	}
+	if s.ipcListener != nil {
+		s.ipcListener.Close()
+	}
+	if s.rpcListener != nil {
+		s.rpcListener.Close()
+	}
+	s.waitGroup.Wait()
+	s.store.close()
+	return os.RemoveAll(s.dir)
+}
+
+func (s *Server) accept(listener net.Listener, ipc bool) {
+	defer s.waitGroup.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			conn.Close()
+			return
+		}
+		c := &session{conn: conn, ipc: ipc, sentFds: make(map[int]bool), refs: make(map[common.ObjectID]bool)}
+		s.conns[conn] = c
+		s.waitGroup.Add(1)
+		s.mutex.Unlock()
+		go s.serve(c)
+	}
+}
+
+// session is the connection of a client.
+type session struct {
+	conn net.Conn
+	ipc  bool
+	// sentFds are the fds of shared memory that have been sent to the client
+	sentFds map[int]bool
+	// refs are the blobs referenced by the client, which are released on
+	// disconnection. The fields are guarded by the mutex of cluster.
+	refs map[common.ObjectID]bool
+}
+
+// response is the reply of a request, followed by the fds (for IPC clients)
+// or the payloads of blobs (for RPC clients).
+type response struct {
+	reply    interface{}
+	fds      []int
+	payloads [][]byte
+	// compress is whether the payloads are compressed
+	compress bool
+	// shutdown closes the server once the reply is sent
+	shutdown bool
+}
+
+func errorReply(code int, format string, args ...interface{}) *response {
+	return &response{reply: map[string]interface{}{
+		"code":    code,
+		"message": fmt.Sprintf(format, args...),
+	}}
+}
+
+func (s *Server) serve(c *session) {
+	defer s.waitGroup.Done()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, c.conn)
+		s.mutex.Unlock()
+		c.conn.Close()
+	}()
+	for {
+		message, err := recvMessage(c.conn)
+		if err != nil {
+			return
+		}
+		var header common.ReplyHeader
+		if err := common.DecodeMsg(message, &header); err != nil {
+			return
+		}
+		fault := s.takeFault(header.Type)
+		if fault != nil && fault.Latency > 0 && !s.sleep(fault.Latency) {
+			return
+		}
+		if fault != nil && fault.Disconnect {
+			return
+		}
+		var resp *response
+		if fault != nil && fault.Code != common.KOK {
+			resp = &response{reply: map[string]interface{}{"code": fault.Code, "message": fault.Message}}
+		} else if resp, err = s.handle(c, header.Type, message); err != nil {
+			return
+		}
+		if err := c.send(resp); err != nil {
+			return
+		}
+		if resp.shutdown {
+			go s.Close()
+			return
+		}
+	}
+}
+
+func (s *Server) takeFault(requestType string) *Fault {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	faults := s.faults[requestType]
+	if len(faults) == 0 {
+		return nil
+	}
+	fault := *faults[0]
+	if faults[0].Times > 0 {
+		if faults[0].Times--; faults[0].Times == 0 {
+			s.faults[requestType] = faults[1:]
+		}
+	}
+	return &fault
+}
+
+// sleep returns false if the server is closed in the meantime.
+func (s *Server) sleep(duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	<-timer.C
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return !s.closed
+}
+
+// wait waits for the cond, and returns an error if the server is closed.
+// The caller must hold the mutex.
+func (s *Server) wait() error {
+	if !s.closed {
+		s.waiters++
+		s.cond.Wait()
+		s.waiters--
+	}
+	if s.closed {
+		return io.ErrClosedPipe
+	}
+	return nil
+}
+
+func (s *Server) handle(c *session, requestType string, message string) (*response, error) {
+	handler, ok := handlers[requestType]
+	if !ok {
+		return errorReply(common.KNotImplemented, "unsupported request: '%s'", requestType), nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return handler(s, c, message)
+}
+
+// handlers handle the requests with the mutex held, an error closes the
+// connection. They are registered in init, as new_session starts another
+// server that refers to them.
+var handlers map[string]func(s *Server, c *session, message string) (*response, error)
+
+func init() {
+	handlers = map[string]func(s *Server, c *session, message string) (*response, error){
+		common.REGISTER_REQUEST:                 (*Server).handleRegister,
+		common.NEW_SESSION_REQUEST:              (*Server).handleNewSession,
+		common.DELETE_SESSION_REQUEST:           (*Server).handleDeleteSession,
+		common.CLUSTER_META_REQUEST:             (*Server).handleClusterMeta,
+		common.INSTANCE_STATUS_REQUEST:          (*Server).handleInstanceStatus,
+		common.PUT_NAME_REQUEST:                 (*Server).handlePutName,
+		common.GET_NAME_REQUEST:                 (*Server).handleGetName,
+		common.DROP_NAME_REQUEST:                (*Server).handleDropName,
+		common.LIST_NAME_REQUEST:                (*Server).handleListName,
+		common.CREAT_DATA_REQUEST:               (*Server).handleCreateData,
+		common.GET_DATA_REQUEST:                 (*Server).handleGetData,
+		common.LIST_DATA_REQUEST:                (*Server).handleListData,
+		common.EXISTS_REQUEST:                   (*Server).handleExists,
+		common.PERSIST_REQUEST:                  (*Server).handlePersist,
+		common.IF_PERSIST_REQUEST:               (*Server).handleIfPersist,
+		common.SHALLOW_COPY_REQUEST:             (*Server).handleShallowCopy,
+		common.MIGRATE_OBJECT_REQUEST:           (*Server).handleMigrateObject,
+		common.LABEL_REQUEST:                    (*Server).handleLabel,
+		common.DEL_DATA_REQUEST:                 (*Server).handleDelData,
+		common.DEL_DATA_WITH_FEEDBACKS_REQUEST:  (*Server).handleDelData,
+		common.CREAT_BUFFER_REQUEST:             (*Server).handleCreateBuffer,
+		common.CREATE_REMOTE_BUFFER_REQUEST:     (*Server).handleCreateRemoteBuffer,
+		common.SEAL_REQUEST:                     (*Server).handleSeal,
+		common.DROP_BUFFER_REQUEST:              (*Server).handleDropBuffer,
+		common.GET_BUFFERS_REQUEST:              (*Server).handleGetBuffers,
+		common.GET_REMOTE_BUFFERS_REQUEST:       (*Server).handleGetBuffers,
+		common.INCREASE_REFERENCE_COUNT_REQUEST: (*Server).handleIncreaseReferenceCount,
+		common.RELEASE_REQUEST:                  (*Server).handleRelease,
+		common.EVICT_REQUEST:                    (*Server).handleEvict,
+		common.LOAD_REQUEST:                     (*Server).handleLoad,
+		common.UNPIN_REQUEST:                    (*Server).handleUnpin,
+		common.IS_SPILLED_REQUEST:               (*Server).handleIsSpilled,
+		common.IS_IN_USE_REQUEST:                (*Server).handleIsInUse,
+		common.CREATE_STREAM_REQUEST:            (*Server).handleCreateStream,
+		common.OPEN_STREAM_REQUEST:              (*Server).handleOpenStream,
+		common.GET_NEXT_STREAM_CHUNK_REQUEST:    (*Server).handleGetNextStreamChunk,
+		common.PUSH_NEXT_STREAM_CHUNK_REQUEST:   (*Server).handlePushNextStreamChunk,
+		common.PULL_NEXT_STREAM_CHUNK_REQUEST:   (*Server).handlePullNextStreamChunk,
+		common.STOP_STREAM_REQUEST:              (*Server).handleStopStream,
+		common.DROP_STREAM_REQUEST:              (*Server).handleDropStream,
+		common.CREATE_BUFFER_BY_PLASMA_REQUEST:  (*Server).handleCreateBufferByPlasma,
+		common.GET_BUFFERS_BY_PLASMA_REQUEST:    (*Server).handleGetBuffersByPlasma,
+		common.PLASMA_SEAL_REQUEST:              (*Server).handlePlasmaSeal,
+		common.PLASMA_RELEASE_REQUEST:           (*Server).handlePlasmaRelease,
+		common.PLASMA_DELETE_DATA_REQUEST:       (*Server).handlePlasmaDeleteData,
+	}
+}
+
+func (c *session) send(resp *response) error {
+	message, err := json.Marshal(resp.reply)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint64(header, uint64(len(message)))
+	if err := sendBytes(c.conn, header); err != nil {
+		return err
+	}
+	if err := sendBytes(c.conn, message); err != nil {
+		return err
+	}
+	for _, fd := range resp.fds {
+		// the fd is sent along with a single byte, like vineyardd
+		if _, _, err := c.conn.(*net.UnixConn).WriteMsgUnix([]byte{0}, unix.UnixRights(fd), nil); err != nil {
+			return err
+		}
+	}
+	if resp.compress {
+		return sendCompressed(c.conn, resp.payloads)
+	}
+	for _, payload := range resp.payloads {
+		if err := sendBytes(c.conn, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendFd returns the fd to send along with the reply, if it hasn't been sent
+// to the client.
+func (c *session) sendFd(fd int) []int {
+	if c.sentFds[fd] {
+		return nil
+	}
+	c.sentFds[fd] = true
+	return []int{fd}
+}
+
+func sendBytes(conn net.Conn, data []byte) error {
+	_, err := conn.Write(data)
+	return err
+}
+
+func recvMessage(conn net.Conn) (string, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	message := make([]byte, binary.LittleEndian.Uint64(header))
+	if _, err := io.ReadFull(conn, message); err != nil {
+		return "", err
+	}
+	return string(message), nil
+}
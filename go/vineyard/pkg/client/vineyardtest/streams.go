@@ -0,0 +1,215 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vineyardtest
+
+import (
+	"github.com/v6d-io/v6d/go/vineyard/pkg/common"
+)
+
+// the modes of open_stream_request
+const (
+	streamOpenRead  = 1
+	streamOpenWrite = 2
+)
+
+// stream is opened by at most a reader and a writer, the chunks pushed by
+// the writer are pulled by the reader in order.
+type stream struct {
+	reader, writer bool
+	// allocated is the chunk returned by the last get_next_stream_chunk,
+	// which is pushed on the next call or once the stream is stopped.
+	allocated common.ObjectID
+	chunks    []common.ObjectID
+	stopped   bool
+	failed    bool
+}
+
+func (s *Server) handleCreateStream(c *session, message string) (*response, error) {
+	var request common.CreateStreamRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid create_stream request: %v", err), nil
+	}
+	if _, ok := s.objects[request.ObjectID]; !ok {
+		return errorReply(common.KObjectNotExists, "stream not exists: %s", common.ObjectIDToString(request.ObjectID)), nil
+	}
+	if _, ok := s.streams[request.ObjectID]; ok {
+		return errorReply(common.KObjectExists, "stream exists: %s", common.ObjectIDToString(request.ObjectID)), nil
+	}
+	s.streams[request.ObjectID] = &stream{allocated: common.InvalidObjectID()}
+	return &response{reply: common.ReplyHeader{Type: common.CREATE_STREAM_REPLY}}, nil
+}
+
+func (s *Server) handleOpenStream(c *session, message string) (*response, error) {
+	var request common.OpenStreamRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid open_stream request: %v", err), nil
+	}
+	st, ok := s.streams[request.ObjectID]
+	if !ok {
+		return errorReply(common.KObjectNotExists, "stream not exists: %s", common.ObjectIDToString(request.ObjectID)), nil
+	}
+	var opened *bool
+	switch request.Mode {
+	case streamOpenRead:
+		opened = &st.reader
+	case streamOpenWrite:
+		opened = &st.writer
+	default:
+		return errorReply(common.KInvalid, "invalid mode to open stream: %d", request.Mode), nil
+	}
+	if *opened {
+		return errorReply(common.KStreamOpened, "stream has been opened: %s", common.ObjectIDToString(request.ObjectID)), nil
+	}
+	*opened = true
+	return &response{reply: common.ReplyHeader{Type: common.OPEN_STREAM_REPLY}}, nil
+}
+
+// writableStream returns the stream that hasn't been stopped, or the reply of
+// failure.
+func (s *Server) writableStream(id common.ObjectID) (*stream, *response) {
+	st, ok := s.streams[id]
+	if !ok {
+		return nil, errorReply(common.KObjectNotExists, "stream not exists: %s", common.ObjectIDToString(id))
+	}
+	if st.stopped {
+		return nil, errorReply(common.KInvalidStreamState, "stream has been stopped: %s", common.ObjectIDToString(id))
+	}
+	return st, nil
+}
+
+// pushAllocated seals and pushes the chunk allocated by the writer.
+func (s *Server) pushAllocated(st *stream) {
+	if st.allocated == common.InvalidObjectID() {
+		return
+	}
+	if b, ok := s.store.blobs[st.allocated]; ok {
+		b.sealed = true
+	}
+	st.chunks = append(st.chunks, st.allocated)
+	st.allocated = common.InvalidObjectID()
+	s.cond.Broadcast()
+}
+
+func (s *Server) handleGetNextStreamChunk(c *session, message string) (*response, error) {
+	var request common.GetNextStreamChunkRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid get_next_stream_chunk request: %v", err), nil
+	}
+	if !c.ipc {
+		return errorReply(common.KInvalid, "get_next_stream_chunk is only supported for ipc clients"), nil
+	}
+	st, failed := s.writableStream(request.ID)
+	if failed != nil {
+		return failed, nil
+	}
+	s.pushAllocated(st)
+	id, b, failed := s.createBlob(request.Size)
+	if failed != nil {
+		return failed, nil
+	}
+	st.allocated = id
+	reply := common.GetNextStreamChunkReply{
+		ReplyHeader: common.ReplyHeader{Type: common.GET_NEXT_STREAM_CHUNK_REPLY},
+		Buffer:      s.store.payload(id, b),
+		Fd:          -1,
+	}
+	resp := &response{}
+	if b != nil {
+		reply.Fd = s.store.fd
+		resp.fds = c.sendFd(s.store.fd)
+	}
+	resp.reply = reply
+	return resp, nil
+}
+
+func (s *Server) handlePushNextStreamChunk(c *session, message string) (*response, error) {
+	var request common.PushNextStreamChunkRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid push_next_stream_chunk request: %v", err), nil
+	}
+	st, failed := s.writableStream(request.ID)
+	if failed != nil {
+		return failed, nil
+	}
+	if !s.exists(request.Chunk) {
+		return errorReply(common.KObjectNotExists, "chunk not exists: %s", common.ObjectIDToString(request.Chunk)), nil
+	}
+	s.pushAllocated(st)
+	st.chunks = append(st.chunks, request.Chunk)
+	s.cond.Broadcast()
+	return &response{reply: common.ReplyHeader{Type: common.PUSH_NEXT_STREAM_CHUNK_REPLY}}, nil
+}
+
+// handlePullNextStreamChunk waits until the next chunk is pushed, or the
+// stream is stopped.
+func (s *Server) handlePullNextStreamChunk(c *session, message string) (*response, error) {
+	var request common.PullNextStreamChunkRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid pull_next_stream_chunk request: %v", err), nil
+	}
+	for {
+		st, ok := s.streams[request.ID]
+		switch {
+		case !ok:
+			return errorReply(common.KStreamFailed, "stream has been dropped: %s", common.ObjectIDToString(request.ID)), nil
+		case len(st.chunks) > 0:
+			chunk := st.chunks[0]
+			st.chunks = st.chunks[1:]
+			return &response{reply: common.PullNextStreamChunkReply{
+				ReplyHeader: common.ReplyHeader{Type: common.PULL_NEXT_STREAM_CHUNK_REPLY},
+				Chunk:       chunk,
+			}}, nil
+		case st.failed:
+			return errorReply(common.KStreamFailed, "stream failed: %s", common.ObjectIDToString(request.ID)), nil
+		case st.stopped:
+			return errorReply(common.KStreamDrained, "stream drained: %s", common.ObjectIDToString(request.ID)), nil
+		}
+		if err := s.wait(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *Server) handleStopStream(c *session, message string) (*response, error) {
+	var request common.StopStreamRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid stop_stream request: %v", err), nil
+	}
+	st, failed := s.writableStream(request.ID)
+	if failed != nil {
+		return failed, nil
+	}
+	if !request.Failed {
+		s.pushAllocated(st)
+	}
+	st.stopped = true
+	st.failed = request.Failed
+	s.cond.Broadcast()
+	return &response{reply: common.ReplyHeader{Type: common.STOP_STREAM_REPLY}}, nil
+}
+
+func (s *Server) handleDropStream(c *session, message string) (*response, error) {
+	var request common.DropStreamRequest
+	if err := common.DecodeMsg(message, &request); err != nil {
+		return errorReply(common.KInvalid, "invalid drop_stream request: %v", err), nil
+	}
+	if _, ok := s.streams[request.ID]; !ok {
+		return errorReply(common.KObjectNotExists, "stream not exists: %s", common.ObjectIDToString(request.ID)), nil
+	}
+	delete(s.streams, request.ID)
+	s.cond.Broadcast()
+	return &response{reply: common.ReplyHeader{Type: common.DROP_STREAM_REPLY}}, nil
+}
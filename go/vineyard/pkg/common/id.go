@@ -16,9 +16,12 @@ limitations under the License.
 package common
 
 import (
+	"encoding/hex"
 	"fmt"
 	"strconv"
 	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 type ObjectID = uint64
@@ -35,6 +38,12 @@ func IsBlob(id ObjectID) bool {
 	return id&0x8000000000000000 != 0
 }
 
+// EmptyBlobID is the id of the blob of size 0, which is shared by all empty
+// buffers and doesn't need to be created in vineyard server.
+func EmptyBlobID() ObjectID {
+	return 0x8000000000000000
+}
+
 func InvalidObjectID() ObjectID {
 	return 0xffffffffffffffff
 }
@@ -53,12 +62,129 @@ func InvalidSignature() Signature {
 	return 0xffffffffffffffff
 }
 
+// PlasmaID is the 20-byte id of buffers in the plasma store of vineyard
+// server, i.e., the object id of Apache Arrow Plasma. vineyard server takes
+// it as an opaque string, thus the raw bytes are sent in messages as other
+// clients do, and the hex string is only used for printing.
+type PlasmaID [20]byte
+
+func (p PlasmaID) String() string {
+	return hex.EncodeToString(p[:])
+}
+
+func PlasmaIDFromString(id string) (PlasmaID, error) {
+	var result PlasmaID
+	decoded, err := hex.DecodeString(id)
+	if err != nil {
+		return result, err
+	}
+	if len(decoded) != len(result) {
+		return result, fmt.Errorf("invalid plasma id '%s': expect %d bytes", id, len(result))
+	}
+	copy(result[:], decoded)
+	return result, nil
+}
+
+// MarshalJSON encodes the raw bytes as a JSON string, only the quote, the
+// backslash and the control characters are escaped, the other bytes are
+// kept as is even if they are not valid UTF-8.
+func (p PlasmaID) MarshalJSON() ([]byte, error) {
+	result := make([]byte, 0, len(p)+2)
+	result = append(result, '"')
+	for _, c := range p {
+		switch {
+		case c == '"' || c == '\\':
+			result = append(result, '\\', c)
+		case c < 0x20:
+			result = append(result, fmt.Sprintf("\\u%04x", c)...)
+		default:
+			result = append(result, c)
+		}
+	}
+	return append(result, '"'), nil
+}
+
+// UnmarshalJSON decodes the raw bytes from a JSON string, the bytes that are
+// not valid UTF-8 are kept as is, rather than being replaced by U+FFFD as
+// encoding/json does for strings.
+func (p *PlasmaID) UnmarshalJSON(data []byte) error {
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return fmt.Errorf("invalid plasma id %s: expect a string", data)
+	}
+	data = data[1 : len(data)-1]
+	decoded := make([]byte, 0, len(p))
+	for index := 0; index < len(data); index++ {
+		c := data[index]
+		if c != '\\' {
+			decoded = append(decoded, c)
+			continue
+		}
+		if index++; index == len(data) {
+			return fmt.Errorf("invalid plasma id: unexpected end of escape")
+		}
+		switch data[index] {
+		case 'b':
+			decoded = append(decoded, '\b')
+		case 'f':
+			decoded = append(decoded, '\f')
+		case 'n':
+			decoded = append(decoded, '\n')
+		case 'r':
+			decoded = append(decoded, '\r')
+		case 't':
+			decoded = append(decoded, '\t')
+		case 'u':
+			r, size := unquoteRune(data[index+1:])
+			if size == 0 {
+				return fmt.Errorf("invalid plasma id: invalid unicode escape")
+			}
+			decoded = utf8.AppendRune(decoded, r)
+			index += size
+		default:
+			decoded = append(decoded, data[index])
+		}
+	}
+	if len(decoded) != len(p) {
+		return fmt.Errorf("invalid plasma id %q: expect %d bytes", decoded, len(p))
+	}
+	copy(p[:], decoded)
+	return nil
+}
+
+// unquoteRune decodes the hex digits that follow "\u" (and the low
+// surrogate if any), and returns the number of bytes consumed.
+func unquoteRune(data []byte) (rune, int) {
+	if len(data) < 4 {
+		return 0, 0
+	}
+	r, err := strconv.ParseUint(string(data[:4]), 16, 16)
+	if err != nil {
+		return 0, 0
+	}
+	if utf16.IsSurrogate(rune(r)) && len(data) >= 10 && data[4] == '\\' && data[5] == 'u' {
+		if low, err := strconv.ParseUint(string(data[6:10]), 16, 16); err == nil {
+			if decoded := utf16.DecodeRune(rune(r), rune(low)); decoded != utf8.RuneError {
+				return decoded, 10
+			}
+		}
+	}
+	return rune(r), 4
+}
+
 type InstanceID = uint64
 
 func UnspecifiedInstanceID() InstanceID {
 	return 0xffffffffffffffff
 }
 
+type SessionID = int64
+
+// RootSessionID is the session of the vineyard server itself, clients are
+// registered to it unless they connect to the socket of another session.
+func RootSessionID() SessionID {
+	return 0
+}
+
 func GenerateObjectID() ObjectID {
 	// TODO: check c++ version's rdtsc instead of time.Now() in golang
 	return ObjectID(0x7FFFFFFFFFFFFFFF & time.Now().Unix())
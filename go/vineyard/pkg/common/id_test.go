@@ -16,6 +16,8 @@ limitations under the License.
 package common
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
@@ -34,3 +36,31 @@ func TestSignature(t *testing.T) {
 	assert.Equal(t, s, "s00000000000004d2")
 	assert.Equal(t, o, uint64(1234))
 }
+
+func TestPlasmaID(t *testing.T) {
+	var p PlasmaID
+	p[0], p[19] = 0x12, 0xab
+	s := p.String()
+	assert.Equal(t, s, "12"+strings.Repeat("00", 18)+"ab")
+	o, err := PlasmaIDFromString(s)
+	assert.NilError(t, err)
+	assert.Equal(t, o, p)
+
+	// the raw bytes are kept, including the escaped and invalid UTF-8 ones
+	p = PlasmaID{'"', '\\', '\n', 0x80, 0xff}
+	copy(p[5:], "vineyard-plasma")
+	encoded, err := json.Marshal(map[string]PlasmaID{"plasma_id": p})
+	assert.NilError(t, err)
+	assert.Equal(t, string(encoded), "{\"plasma_id\":\"\\\"\\\\\\u000a\x80\xffvineyard-plasma\"}")
+	var decoded map[string]PlasmaID
+	assert.NilError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, decoded["plasma_id"], p)
+	assert.NilError(t, json.Unmarshal([]byte(`"\u0061\ud83d\ude00bcdefghijklmnop"`), &p))
+	assert.Equal(t, string(p[:]), "a\U0001F600bcdefghijklmnop")
+	assert.Assert(t, json.Unmarshal([]byte(`"too short"`), &p) != nil)
+
+	_, err = PlasmaIDFromString("12ab")
+	assert.Assert(t, err != nil)
+	_, err = PlasmaIDFromString("not a hex string")
+	assert.Assert(t, err != nil)
+}
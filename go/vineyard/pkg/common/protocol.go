@@ -17,36 +17,132 @@ package common
 
 import (
 	"encoding/json"
-	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 const (
-	REGISTER_REQUEST       = "register_request"
-	REGISTER_REPLY         = "register_reply"
-	EXIT_REQUEST           = "exit_request"
-	PERSIST_REQUEST        = "persist_request"
-	PUT_NAME_REQUEST       = "put_name_request"
-	PUT_NAME_REPLY         = "put_name_reply"
-	GET_NAME_REQUEST       = "get_name_request"
-	GET_NAME_REPLY         = "get_name_reply"
-	DROP_NAME_REQUEST      = "drop_name_request"
-	DROP_NAME_REPLY        = "drop_name_reply"
-	CREAT_BUFFER_REQUEST   = "create_buffer_request"
-	CREAT_DATA_REQUEST     = "create_data_request"
-	DEFAULT_SERVER_VERSION = "0.0.0"
+	REGISTER_REQUEST                 = "register_request"
+	REGISTER_REPLY                   = "register_reply"
+	EXIT_REQUEST                     = "exit_request"
+	PERSIST_REQUEST                  = "persist_request"
+	PERSIST_REPLY                    = "persist_reply"
+	IF_PERSIST_REQUEST               = "if_persist_request"
+	IF_PERSIST_REPLY                 = "if_persist_reply"
+	MIGRATE_OBJECT_REQUEST           = "migrate_object_request"
+	MIGRATE_OBJECT_REPLY             = "migrate_object_reply"
+	CLUSTER_META_REQUEST             = "cluster_meta"
+	CLUSTER_META_REPLY               = "cluster_meta"
+	INSTANCE_STATUS_REQUEST          = "instance_status_request"
+	INSTANCE_STATUS_REPLY            = "instance_status_reply"
+	LABEL_REQUEST                    = "label_request"
+	LABEL_REPLY                      = "label_reply"
+	CREATE_BUFFER_BY_PLASMA_REQUEST  = "create_buffer_by_plasma_request"
+	CREATE_BUFFER_BY_PLASMA_REPLY    = "create_buffer_by_plasma_reply"
+	GET_BUFFERS_BY_PLASMA_REQUEST    = "get_buffers_by_plasma_request"
+	GET_BUFFERS_BY_PLASMA_REPLY      = "get_buffers_by_plasma_reply"
+	PLASMA_SEAL_REQUEST              = "plasma_seal_request"
+	PLASMA_SEAL_REPLY                = "plasma_seal_reply"
+	PLASMA_RELEASE_REQUEST           = "plasma_release_request"
+	PLASMA_RELEASE_REPLY             = "plasma_release_reply"
+	PLASMA_DELETE_DATA_REQUEST       = "plasma_delete_data_request"
+	PLASMA_DELETE_DATA_REPLY         = "plasma_delete_data_reply"
+	CREATE_REMOTE_BUFFER_REQUEST     = "create_remote_buffer_request"
+	GET_REMOTE_BUFFERS_REQUEST       = "get_remote_buffers_request"
+	PUT_NAME_REQUEST                 = "put_name_request"
+	PUT_NAME_REPLY                   = "put_name_reply"
+	GET_NAME_REQUEST                 = "get_name_request"
+	GET_NAME_REPLY                   = "get_name_reply"
+	DROP_NAME_REQUEST                = "drop_name_request"
+	DROP_NAME_REPLY                  = "drop_name_reply"
+	LIST_NAME_REQUEST                = "list_name_request"
+	LIST_NAME_REPLY                  = "list_name_reply"
+	LIST_DATA_REQUEST                = "list_data_request"
+	EXISTS_REQUEST                   = "exists_request"
+	EXISTS_REPLY                     = "exists_reply"
+	DEL_DATA_REQUEST                 = "del_data_request"
+	DEL_DATA_REPLY                   = "del_data_reply"
+	DEL_DATA_WITH_FEEDBACKS_REQUEST  = "del_data_with_feedbacks_request"
+	DEL_DATA_WITH_FEEDBACKS_REPLY    = "del_data_with_feedbacks_reply"
+	SHALLOW_COPY_REQUEST             = "shallow_copy_request"
+	SHALLOW_COPY_REPLY               = "shallow_copy_reply"
+	INCREASE_REFERENCE_COUNT_REQUEST = "increase_reference_count_request"
+	INCREASE_REFERENCE_COUNT_REPLY   = "increase_reference_count_reply"
+	RELEASE_REQUEST                  = "release_request"
+	RELEASE_REPLY                    = "release_reply"
+	NEW_SESSION_REQUEST              = "new_session_request"
+	NEW_SESSION_REPLY                = "new_session_reply"
+	DELETE_SESSION_REQUEST           = "delete_session_request"
+	DELETE_SESSION_REPLY             = "delete_session_reply"
+	EVICT_REQUEST                    = "evict_request"
+	EVICT_REPLY                      = "evict_reply"
+	LOAD_REQUEST                     = "load_request"
+	LOAD_REPLY                       = "load_reply"
+	UNPIN_REQUEST                    = "unpin_request"
+	UNPIN_REPLY                      = "unpin_reply"
+	IS_SPILLED_REQUEST               = "is_spilled_request"
+	IS_SPILLED_REPLY                 = "is_spilled_reply"
+	IS_IN_USE_REQUEST                = "is_in_use_request"
+	IS_IN_USE_REPLY                  = "is_in_use_reply"
+	CREAT_BUFFER_REQUEST             = "create_buffer_request"
+	CREATE_BUFFER_REPLY              = "create_buffer_reply"
+	DROP_BUFFER_REQUEST              = "drop_buffer_request"
+	DROP_BUFFER_REPLY                = "drop_buffer_reply"
+	CREAT_DATA_REQUEST               = "create_data_request"
+	CREATE_DATA_REPLY                = "create_data_reply"
+	SEAL_REQUEST                     = "seal_request"
+	SEAL_REPLY                       = "seal_reply"
+	GET_BUFFERS_REQUEST              = "get_buffers_request"
+	GET_BUFFERS_REPLY                = "get_buffers_reply"
+	GET_DATA_REQUEST                 = "get_data_request"
+	GET_DATA_REPLY                   = "get_data_reply"
+	CREATE_STREAM_REQUEST            = "create_stream_request"
+	CREATE_STREAM_REPLY              = "create_stream_reply"
+	OPEN_STREAM_REQUEST              = "open_stream_request"
+	OPEN_STREAM_REPLY                = "open_stream_reply"
+	GET_NEXT_STREAM_CHUNK_REQUEST    = "get_next_stream_chunk_request"
+	GET_NEXT_STREAM_CHUNK_REPLY      = "get_next_stream_chunk_reply"
+	PUSH_NEXT_STREAM_CHUNK_REQUEST   = "push_next_stream_chunk_request"
+	PUSH_NEXT_STREAM_CHUNK_REPLY     = "push_next_stream_chunk_reply"
+	PULL_NEXT_STREAM_CHUNK_REQUEST   = "pull_next_stream_chunk_request"
+	PULL_NEXT_STREAM_CHUNK_REPLY     = "pull_next_stream_chunk_reply"
+	STOP_STREAM_REQUEST              = "stop_stream_request"
+	STOP_STREAM_REPLY                = "stop_stream_reply"
+	DROP_STREAM_REQUEST              = "drop_stream_request"
+	DROP_STREAM_REPLY                = "drop_stream_reply"
+	DEFAULT_SERVER_VERSION           = "0.0.0"
+	// CLIENT_VERSION is the protocol version of the client sent on register
+	CLIENT_VERSION = "0.2.4"
+	// MIN_SERVER_VERSION is the oldest vineyard server the client works with
+	MIN_SERVER_VERSION = "0.2.0"
+)
+
+// The bulk store types of vineyard server, a session uses one of them.
+const (
+	NormalStore = "Normal"
+	PlasmaStore = "Plasma"
 )
 
 type RegisterRequest struct {
-	Type    string `json:"type"`
-	Version string `json:"version"`
+	Type      string    `json:"type"`
+	Version   string    `json:"version"`
+	StoreType string    `json:"store_type"`
+	SessionID SessionID `json:"session_id"`
+	Username  string    `json:"username"`
+	Password  string    `json:"password"`
 }
 
 type RegisterReply struct {
-	InstanceID  int    `json:"instance_id"`
-	IPCSocket   string `json:"ipc_socket"`
-	RPCEndpoint string `json:"rpc_endpoint"`
-	Type        string `json:"type"`
-	Version     string `json:"version,omitempty"`
+	InstanceID  InstanceID `json:"instance_id"`
+	IPCSocket   string     `json:"ipc_socket"`
+	RPCEndpoint string     `json:"rpc_endpoint"`
+	Type        string     `json:"type"`
+	Version     string     `json:"version,omitempty"`
+	SessionID   SessionID  `json:"session_id"`
+	// StoreMatch is whether the store type of session matches the request,
+	// which is only replied by servers that support FeatureStoreType.
+	StoreMatch bool `json:"store_match"`
 }
 
 type ExitRequest struct {
@@ -103,10 +199,33 @@ type CreateBufferRequest struct {
 
 type CreateBufferReply struct {
 	Type    string        `json:"type"`
+	Code    int           `json:"code"`
+	Message string        `json:"message,omitempty"`
 	ID      ObjectID      `json:"id"`
 	Created CreatedBuffer `json:"created"`
 }
 
+// CreateRemoteBufferRequest is followed by the payload of the buffer, and the
+// server replies with a create_buffer_reply once the payload is received.
+type CreateRemoteBufferRequest struct {
+	Type     string `json:"type"`
+	Size     int    `json:"size"`
+	Compress bool   `json:"compress"`
+}
+
+type DropBufferRequest struct {
+	Type string   `json:"type"`
+	ID   ObjectID `json:"id"`
+}
+
+type DropBufferReply struct {
+	Type    string `json:"type"`
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// CreatedBuffer is the payload of a blob in vineyard server, the blob lives
+// in [DataOffset, DataOffset+DataSize) of the memory mapped from StoreFd.
 type CreatedBuffer struct {
 	DataOffset int      `json:"data_offset"`
 	DataSize   int      `json:"data_size"`
@@ -115,16 +234,30 @@ type CreatedBuffer struct {
 	StoreFd    int      `json:"store_fd"`
 }
 
+type GetBuffersReply struct {
+	Type     string          `json:"type"`
+	Code     int             `json:"code"`
+	Message  string          `json:"message,omitempty"`
+	Payloads []CreatedBuffer `json:"payloads"`
+	Fds      []int           `json:"fds"`
+	Num      int             `json:"num"`
+	Compress bool            `json:"compress"`
+}
+
 type GetDataRequest struct {
-	Type       int      `json:"type"`
-	ID         ObjectID `json:"id"`
-	SyncRemote bool     `json:"sync_remote"`
-	Wait       bool     `json:"wait"`
+	Type       string     `json:"type"`
+	ID         []ObjectID `json:"id"`
+	SyncRemote bool       `json:"sync_remote"`
+	Wait       bool       `json:"wait"`
 }
 
+// GetDataReply carries the metadata trees keyed by the string form of
+// the object ids, see ObjectIDToString.
 type GetDataReply struct {
-	Type    string      `json:"type"`
-	Content interface{} `json:"content"`
+	Type    string                            `json:"type"`
+	Code    int                               `json:"code"`
+	Message string                            `json:"message,omitempty"`
+	Content map[string]map[string]interface{} `json:"content"`
 }
 
 type CreateDataRequest struct {
@@ -133,10 +266,31 @@ type CreateDataRequest struct {
 }
 
 type CreateDataReply struct {
-	Type       string   `json:"type"`
-	ID         ObjectID `json:"id"`
-	Signature  `json:"signature"`
-	InstanceID `json:"instance_id"`
+	Type       string     `json:"type"`
+	Code       int        `json:"code"`
+	Message    string     `json:"message,omitempty"`
+	ID         ObjectID   `json:"id"`
+	Signature  Signature  `json:"signature"`
+	InstanceID InstanceID `json:"instance_id"`
+}
+
+// ReplyHeader is the common part of all replies, a non-zero code means
+// the request failed in vineyard server.
+type ReplyHeader struct {
+	Type    string `json:"type"`
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type SealRequest struct {
+	Type     string   `json:"type"`
+	ObjectID ObjectID `json:"object_id"`
+}
+
+type SealReply struct {
+	Type    string `json:"type"`
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
 }
 
 func encodeMsg(data interface{}, msg *string) error {
@@ -148,94 +302,788 @@ func encodeMsg(data interface{}, msg *string) error {
 	return nil
 }
 
-func WriteRegisterRequest(msg *string) {
+// DecodeMsg decodes the message received from vineyard server. Numbers are
+// kept as json.Number as object ids, signatures and instance ids are
+// 64-bit unsigned integers that cannot be represented as float64.
+func DecodeMsg(msg string, data interface{}) error {
+	decoder := json.NewDecoder(strings.NewReader(msg))
+	decoder.UseNumber()
+	return decoder.Decode(data)
+}
+
+func WriteRegisterRequest(storeType string, sessionID SessionID, username, password string, msg *string) error {
 	var register RegisterRequest
 	register.Type = REGISTER_REQUEST
-	register.Version = "0.2.4"
+	register.Version = CLIENT_VERSION
+	register.StoreType = storeType
+	register.SessionID = sessionID
+	register.Username = username
+	register.Password = password
 
-	if err := encodeMsg(register, msg); err != nil {
-		fmt.Println("WriteRegisterRequest failed: ", err.Error())
-	}
+	return encodeMsg(register, msg)
 }
 
-func WriteExitRequest(msg *string) {
+func WriteExitRequest(msg *string) error {
 	var exit ExitRequest
 	exit.Type = EXIT_REQUEST
 
-	if err := encodeMsg(exit, msg); err != nil {
-		fmt.Println("WriteExitRequest failed: ", err.Error())
-	}
+	return encodeMsg(exit, msg)
 }
 
-func WritePersistRequest(id ObjectID, msg *string) {
+func WritePersistRequest(id ObjectID, msg *string) error {
 	var persist PersistRequest
 	persist.Type = PERSIST_REQUEST
 	persist.ID = id
 
-	if err := encodeMsg(persist, msg); err != nil {
-		fmt.Println("WritePersistRequest failed: ", err.Error())
-	}
+	return encodeMsg(persist, msg)
 }
 
-func WritePutNameRequest(id ObjectID, name string, msg *string) {
+func WritePutNameRequest(id ObjectID, name string, msg *string) error {
 	var putNameReq PutNameRequest
 	putNameReq.Type = PUT_NAME_REQUEST
 	putNameReq.ReqObjectID = id
 	putNameReq.Name = name
 
-	if err := encodeMsg(putNameReq, msg); err != nil {
-		fmt.Println("WritePutNameRequest failed: ", err.Error())
-	}
+	return encodeMsg(putNameReq, msg)
 }
 
-func WriteGetNameRequest(name string, wait bool, msg *string) {
+func WriteGetNameRequest(name string, wait bool, msg *string) error {
 	var getNameReq GetNameRequest
 	getNameReq.Type = GET_NAME_REQUEST
 	getNameReq.Name = name
 	getNameReq.Wait = wait
 
-	if err := encodeMsg(getNameReq, msg); err != nil {
-		fmt.Println("WriteGetNameRequest failed: ", err.Error())
-	}
+	return encodeMsg(getNameReq, msg)
 }
 
-func WriteDropNameRequest(name string, msg *string) {
+func WriteDropNameRequest(name string, msg *string) error {
 	var dropNameReq DropNameRequest
 	dropNameReq.Type = DROP_NAME_REQUEST
 	dropNameReq.Name = name
 
-	if err := encodeMsg(dropNameReq, msg); err != nil {
-		fmt.Println("WriteDropNameRequest failed: ", err.Error())
-	}
+	return encodeMsg(dropNameReq, msg)
 }
 
-func WriteCreateBufferRequest(size int, msg *string) {
+func WriteCreateBufferRequest(size int, msg *string) error {
 	var createBufferReq CreateBufferRequest
 	createBufferReq.Type = CREAT_BUFFER_REQUEST
 	createBufferReq.Size = size
 
-	if err := encodeMsg(createBufferReq, msg); err != nil {
-		fmt.Println("WriteCreateBufferRequest failed: ", err.Error())
-	}
+	return encodeMsg(createBufferReq, msg)
+}
+
+func WriteCreateRemoteBufferRequest(size int, compress bool, msg *string) error {
+	var createRemoteBufferReq CreateRemoteBufferRequest
+	createRemoteBufferReq.Type = CREATE_REMOTE_BUFFER_REQUEST
+	createRemoteBufferReq.Size = size
+	createRemoteBufferReq.Compress = compress
+
+	return encodeMsg(createRemoteBufferReq, msg)
 }
 
-func WriteGetDataRequest(id ObjectID, syncRemote bool, wait bool, msg *string) {
+func WriteGetDataRequest(id ObjectID, syncRemote bool, wait bool, msg *string) error {
 	var getDataReq GetDataRequest
-	getDataReq.ID = id
+	getDataReq.Type = GET_DATA_REQUEST
+	getDataReq.ID = []ObjectID{id}
 	getDataReq.SyncRemote = syncRemote
 	getDataReq.Wait = wait
 
-	if err := encodeMsg(getDataReq, msg); err != nil {
-		fmt.Println("WriteGetDataRequest failed: ", err.Error())
-	}
+	return encodeMsg(getDataReq, msg)
 }
 
-func WriteCreateDataRequest(content interface{}, msg *string) {
+func WriteCreateDataRequest(content interface{}, msg *string) error {
 	var createDataReq CreateDataRequest
 	createDataReq.Type = CREAT_DATA_REQUEST
 	createDataReq.Content = content
 
-	if err := encodeMsg(createDataReq, msg); err != nil {
-		fmt.Println("WriteCreateDataRequest failed: ", err.Error())
+	return encodeMsg(createDataReq, msg)
+}
+
+func WriteDropBufferRequest(id ObjectID, msg *string) error {
+	var dropBufferReq DropBufferRequest
+	dropBufferReq.Type = DROP_BUFFER_REQUEST
+	dropBufferReq.ID = id
+
+	return encodeMsg(dropBufferReq, msg)
+}
+
+func WriteSealRequest(id ObjectID, msg *string) error {
+	var sealReq SealRequest
+	sealReq.Type = SEAL_REQUEST
+	sealReq.ObjectID = id
+
+	return encodeMsg(sealReq, msg)
+}
+
+func WriteGetBuffersRequest(ids []ObjectID, unsafe bool, msg *string) error {
+	// the ids are keyed by their index to keep compatible with legacy servers
+	getBuffersReq := make(map[string]interface{})
+	getBuffersReq["type"] = GET_BUFFERS_REQUEST
+	for index, id := range ids {
+		getBuffersReq[strconv.Itoa(index)] = id
+	}
+	getBuffersReq["num"] = len(ids)
+	getBuffersReq["unsafe"] = unsafe
+
+	return encodeMsg(getBuffersReq, msg)
+}
+
+// WriteGetRemoteBuffersRequest asks the server to send the payloads of blobs
+// over the connection right after the reply, in the order of payloads.
+func WriteGetRemoteBuffersRequest(ids []ObjectID, unsafe bool, compress bool, msg *string) error {
+	getRemoteBuffersReq := make(map[string]interface{})
+	getRemoteBuffersReq["type"] = GET_REMOTE_BUFFERS_REQUEST
+	for index, id := range ids {
+		getRemoteBuffersReq[strconv.Itoa(index)] = id
+	}
+	getRemoteBuffersReq["num"] = len(ids)
+	getRemoteBuffersReq["unsafe"] = unsafe
+	getRemoteBuffersReq["compress"] = compress
+
+	return encodeMsg(getRemoteBuffersReq, msg)
+}
+
+// ReadGetBuffersReply decodes the get_buffers reply. Legacy servers don't
+// have the "payloads" field and the payloads are keyed by their index.
+func ReadGetBuffersReply(msg string, reply *GetBuffersReply) error {
+	if err := DecodeMsg(msg, reply); err != nil {
+		return err
+	}
+	if len(reply.Payloads) != 0 || reply.Num == 0 {
+		return nil
 	}
+	var root map[string]json.RawMessage
+	if err := DecodeMsg(msg, &root); err != nil {
+		return err
+	}
+	reply.Payloads = make([]CreatedBuffer, reply.Num)
+	for index := 0; index < reply.Num; index++ {
+		if err := json.Unmarshal(root[strconv.Itoa(index)], &reply.Payloads[index]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type CreateStreamRequest struct {
+	Type     string   `json:"type"`
+	ObjectID ObjectID `json:"object_id"`
+}
+
+type OpenStreamRequest struct {
+	Type     string   `json:"type"`
+	ObjectID ObjectID `json:"object_id"`
+	Mode     int64    `json:"mode"`
+}
+
+type GetNextStreamChunkRequest struct {
+	Type string   `json:"type"`
+	ID   ObjectID `json:"id"`
+	Size int      `json:"size"`
+}
+
+type GetNextStreamChunkReply struct {
+	ReplyHeader
+	Buffer CreatedBuffer `json:"buffer"`
+	Fd     int           `json:"fd"`
+}
+
+type PushNextStreamChunkRequest struct {
+	Type  string   `json:"type"`
+	ID    ObjectID `json:"id"`
+	Chunk ObjectID `json:"chunk"`
+}
+
+type PullNextStreamChunkRequest struct {
+	Type string   `json:"type"`
+	ID   ObjectID `json:"id"`
+}
+
+type PullNextStreamChunkReply struct {
+	ReplyHeader
+	Chunk ObjectID `json:"chunk"`
+}
+
+type StopStreamRequest struct {
+	Type   string   `json:"type"`
+	ID     ObjectID `json:"id"`
+	Failed bool     `json:"failed"`
+}
+
+type DropStreamRequest struct {
+	Type string   `json:"type"`
+	ID   ObjectID `json:"id"`
+}
+
+func WriteCreateStreamRequest(id ObjectID, msg *string) error {
+	var createStreamReq CreateStreamRequest
+	createStreamReq.Type = CREATE_STREAM_REQUEST
+	createStreamReq.ObjectID = id
+
+	return encodeMsg(createStreamReq, msg)
+}
+
+func WriteOpenStreamRequest(id ObjectID, mode int64, msg *string) error {
+	var openStreamReq OpenStreamRequest
+	openStreamReq.Type = OPEN_STREAM_REQUEST
+	openStreamReq.ObjectID = id
+	openStreamReq.Mode = mode
+
+	return encodeMsg(openStreamReq, msg)
+}
+
+func WriteGetNextStreamChunkRequest(id ObjectID, size int, msg *string) error {
+	var getNextStreamChunkReq GetNextStreamChunkRequest
+	getNextStreamChunkReq.Type = GET_NEXT_STREAM_CHUNK_REQUEST
+	getNextStreamChunkReq.ID = id
+	getNextStreamChunkReq.Size = size
+
+	return encodeMsg(getNextStreamChunkReq, msg)
+}
+
+func WritePushNextStreamChunkRequest(id ObjectID, chunk ObjectID, msg *string) error {
+	var pushNextStreamChunkReq PushNextStreamChunkRequest
+	pushNextStreamChunkReq.Type = PUSH_NEXT_STREAM_CHUNK_REQUEST
+	pushNextStreamChunkReq.ID = id
+	pushNextStreamChunkReq.Chunk = chunk
+
+	return encodeMsg(pushNextStreamChunkReq, msg)
+}
+
+func WritePullNextStreamChunkRequest(id ObjectID, msg *string) error {
+	var pullNextStreamChunkReq PullNextStreamChunkRequest
+	pullNextStreamChunkReq.Type = PULL_NEXT_STREAM_CHUNK_REQUEST
+	pullNextStreamChunkReq.ID = id
+
+	return encodeMsg(pullNextStreamChunkReq, msg)
+}
+
+func WriteStopStreamRequest(id ObjectID, failed bool, msg *string) error {
+	var stopStreamReq StopStreamRequest
+	stopStreamReq.Type = STOP_STREAM_REQUEST
+	stopStreamReq.ID = id
+	stopStreamReq.Failed = failed
+
+	return encodeMsg(stopStreamReq, msg)
+}
+
+func WriteDropStreamRequest(id ObjectID, msg *string) error {
+	var dropStreamReq DropStreamRequest
+	dropStreamReq.Type = DROP_STREAM_REQUEST
+	dropStreamReq.ID = id
+
+	return encodeMsg(dropStreamReq, msg)
+}
+
+type ListNameRequest struct {
+	Type    string `json:"type"`
+	Pattern string `json:"pattern"`
+	Regex   bool   `json:"regex"`
+	Limit   int    `json:"limit"`
+}
+
+type ListNameReply struct {
+	Type  string              `json:"type"`
+	Code  int                 `json:"code"`
+	Names map[string]ObjectID `json:"names"`
+}
+
+// ListDataRequest lists the metadata of objects whose typename matches the
+// pattern, the server replies with a get_data_reply.
+type ListDataRequest struct {
+	Type    string `json:"type"`
+	Pattern string `json:"pattern"`
+	Regex   bool   `json:"regex"`
+	Limit   int    `json:"limit"`
+}
+
+type ExistsRequest struct {
+	Type string   `json:"type"`
+	ID   ObjectID `json:"id"`
+}
+
+type ExistsReply struct {
+	Type   string `json:"type"`
+	Code   int    `json:"code"`
+	Exists bool   `json:"exists"`
+}
+
+func WriteListNameRequest(pattern string, regex bool, limit int, msg *string) error {
+	var listNameReq ListNameRequest
+	listNameReq.Type = LIST_NAME_REQUEST
+	listNameReq.Pattern = pattern
+	listNameReq.Regex = regex
+	listNameReq.Limit = limit
+
+	return encodeMsg(listNameReq, msg)
+}
+
+func WriteListDataRequest(pattern string, regex bool, limit int, msg *string) error {
+	var listDataReq ListDataRequest
+	listDataReq.Type = LIST_DATA_REQUEST
+	listDataReq.Pattern = pattern
+	listDataReq.Regex = regex
+	listDataReq.Limit = limit
+
+	return encodeMsg(listDataReq, msg)
+}
+
+func WriteExistsRequest(id ObjectID, msg *string) error {
+	var existsReq ExistsRequest
+	existsReq.Type = EXISTS_REQUEST
+	existsReq.ID = id
+
+	return encodeMsg(existsReq, msg)
+}
+
+type DelDataRequest struct {
+	Type       string     `json:"type"`
+	ID         []ObjectID `json:"id"`
+	Force      bool       `json:"force"`
+	Deep       bool       `json:"deep"`
+	MemoryTrim bool       `json:"memory_trim"`
+	FastPath   bool       `json:"fastpath"`
+}
+
+type DelDataWithFeedbacksReply struct {
+	Type       string     `json:"type"`
+	Code       int        `json:"code"`
+	DeletedIDs []ObjectID `json:"deleted_bids"`
+}
+
+type ShallowCopyRequest struct {
+	Type  string                 `json:"type"`
+	ID    ObjectID               `json:"id"`
+	Extra map[string]interface{} `json:"extra"`
+}
+
+type ShallowCopyReply struct {
+	Type     string   `json:"type"`
+	Code     int      `json:"code"`
+	TargetID ObjectID `json:"target_id"`
+}
+
+type IncreaseReferenceCountRequest struct {
+	Type string     `json:"type"`
+	IDs  []ObjectID `json:"ids"`
+}
+
+type ReleaseRequest struct {
+	Type string   `json:"type"`
+	ID   ObjectID `json:"object_id"`
+}
+
+func writeDelDataRequest(requestType string, ids []ObjectID, force, deep, memoryTrim, fastPath bool, msg *string) error {
+	var delDataReq DelDataRequest
+	delDataReq.Type = requestType
+	delDataReq.ID = ids
+	delDataReq.Force = force
+	delDataReq.Deep = deep
+	delDataReq.MemoryTrim = memoryTrim
+	delDataReq.FastPath = fastPath
+	return encodeMsg(delDataReq, msg)
+}
+
+func WriteDelDataRequest(ids []ObjectID, force, deep, memoryTrim, fastPath bool, msg *string) error {
+	return writeDelDataRequest(DEL_DATA_REQUEST, ids, force, deep, memoryTrim, fastPath, msg)
+}
+
+func WriteDelDataWithFeedbacksRequest(ids []ObjectID, force, deep, memoryTrim, fastPath bool, msg *string) error {
+	return writeDelDataRequest(DEL_DATA_WITH_FEEDBACKS_REQUEST, ids, force, deep, memoryTrim, fastPath, msg)
+}
+
+func WriteShallowCopyRequest(id ObjectID, extra map[string]interface{}, msg *string) error {
+	var shallowCopyReq ShallowCopyRequest
+	shallowCopyReq.Type = SHALLOW_COPY_REQUEST
+	shallowCopyReq.ID = id
+	shallowCopyReq.Extra = extra
+	if shallowCopyReq.Extra == nil {
+		shallowCopyReq.Extra = map[string]interface{}{}
+	}
+
+	return encodeMsg(shallowCopyReq, msg)
+}
+
+func WriteIncreaseReferenceCountRequest(ids []ObjectID, msg *string) error {
+	var increaseReq IncreaseReferenceCountRequest
+	increaseReq.Type = INCREASE_REFERENCE_COUNT_REQUEST
+	increaseReq.IDs = ids
+
+	return encodeMsg(increaseReq, msg)
+}
+
+func WriteReleaseRequest(id ObjectID, msg *string) error {
+	var releaseReq ReleaseRequest
+	releaseReq.Type = RELEASE_REQUEST
+	releaseReq.ID = id
+
+	return encodeMsg(releaseReq, msg)
+}
+
+type NewSessionRequest struct {
+	Type          string `json:"type"`
+	BulkStoreType string `json:"bulk_store_type"`
+}
+
+type NewSessionReply struct {
+	Type       string `json:"type"`
+	Code       int    `json:"code"`
+	SocketPath string `json:"socket_path"`
+}
+
+type DeleteSessionRequest struct {
+	Type string `json:"type"`
+}
+
+func WriteNewSessionRequest(storeType string, msg *string) error {
+	var newSessionReq NewSessionRequest
+	newSessionReq.Type = NEW_SESSION_REQUEST
+	newSessionReq.BulkStoreType = storeType
+
+	return encodeMsg(newSessionReq, msg)
+}
+
+func WriteDeleteSessionRequest(msg *string) error {
+	var deleteSessionReq DeleteSessionRequest
+	deleteSessionReq.Type = DELETE_SESSION_REQUEST
+
+	return encodeMsg(deleteSessionReq, msg)
+}
+
+type EvictRequest struct {
+	Type string     `json:"type"`
+	IDs  []ObjectID `json:"ids"`
+}
+
+type LoadRequest struct {
+	Type string     `json:"type"`
+	IDs  []ObjectID `json:"ids"`
+	Pin  bool       `json:"pin"`
+}
+
+type UnpinRequest struct {
+	Type string     `json:"type"`
+	IDs  []ObjectID `json:"ids"`
+}
+
+type IsSpilledRequest struct {
+	Type string   `json:"type"`
+	ID   ObjectID `json:"id"`
+}
+
+type IsSpilledReply struct {
+	Type      string `json:"type"`
+	Code      int    `json:"code"`
+	IsSpilled bool   `json:"is_spilled"`
+}
+
+type IsInUseRequest struct {
+	Type string   `json:"type"`
+	ID   ObjectID `json:"id"`
+}
+
+type IsInUseReply struct {
+	Type    string `json:"type"`
+	Code    int    `json:"code"`
+	IsInUse bool   `json:"is_in_use"`
+}
+
+func WriteEvictRequest(ids []ObjectID, msg *string) error {
+	var evictReq EvictRequest
+	evictReq.Type = EVICT_REQUEST
+	evictReq.IDs = ids
+
+	return encodeMsg(evictReq, msg)
+}
+
+func WriteLoadRequest(ids []ObjectID, pin bool, msg *string) error {
+	var loadReq LoadRequest
+	loadReq.Type = LOAD_REQUEST
+	loadReq.IDs = ids
+	loadReq.Pin = pin
+
+	return encodeMsg(loadReq, msg)
+}
+
+func WriteUnpinRequest(ids []ObjectID, msg *string) error {
+	var unpinReq UnpinRequest
+	unpinReq.Type = UNPIN_REQUEST
+	unpinReq.IDs = ids
+
+	return encodeMsg(unpinReq, msg)
+}
+
+func WriteIsSpilledRequest(id ObjectID, msg *string) error {
+	var isSpilledReq IsSpilledRequest
+	isSpilledReq.Type = IS_SPILLED_REQUEST
+	isSpilledReq.ID = id
+
+	return encodeMsg(isSpilledReq, msg)
+}
+
+func WriteIsInUseRequest(id ObjectID, msg *string) error {
+	var isInUseReq IsInUseRequest
+	isInUseReq.Type = IS_IN_USE_REQUEST
+	isInUseReq.ID = id
+
+	return encodeMsg(isInUseReq, msg)
+}
+
+type IfPersistRequest struct {
+	Type string   `json:"type"`
+	ID   ObjectID `json:"id"`
+}
+
+type IfPersistReply struct {
+	Type    string `json:"type"`
+	Code    int    `json:"code"`
+	Persist bool   `json:"persist"`
+}
+
+// MigrateObjectRequest asks the server to migrate the object to the instance
+// the client connects to. The other fields are only used between servers,
+// where peer is the hostname of the peer instance, and the object is
+// migrated from the peer instance if local is true, or to it otherwise.
+type MigrateObjectRequest struct {
+	Type            string   `json:"type"`
+	ObjectID        ObjectID `json:"object_id"`
+	Local           bool     `json:"local,omitempty"`
+	IsStream        bool     `json:"is_stream,omitempty"`
+	Peer            string   `json:"peer,omitempty"`
+	PeerRPCEndpoint string   `json:"peer_rpc_endpoint,omitempty"`
+}
+
+type MigrateObjectReply struct {
+	Type     string   `json:"type"`
+	Code     int      `json:"code"`
+	ObjectID ObjectID `json:"object_id"`
+}
+
+type ClusterMetaRequest struct {
+	Type string `json:"type"`
+}
+
+// ClusterMetaReply carries the status of instances in the cluster, keyed by
+// "i" followed by the instance id, e.g., "i0".
+type ClusterMetaReply struct {
+	Type    string                            `json:"type"`
+	Code    int                               `json:"code"`
+	Message string                            `json:"message,omitempty"`
+	Meta    map[string]map[string]interface{} `json:"meta"`
+}
+
+type InstanceStatusRequest struct {
+	Type string `json:"type"`
+}
+
+// InstanceStatus is the status of the vineyard instance, the memory is in
+// bytes and the connections are counted per client.
+type InstanceStatus struct {
+	InstanceID       InstanceID `json:"instance_id"`
+	Deployment       string     `json:"deployment"`
+	MemoryUsage      int64      `json:"memory_usage"`
+	MemoryLimit      int64      `json:"memory_limit"`
+	DeferredRequests int        `json:"deferred_requests"`
+	IPCConnections   int        `json:"ipc_connections"`
+	RPCConnections   int        `json:"rpc_connections"`
+}
+
+type InstanceStatusReply struct {
+	Type    string         `json:"type"`
+	Code    int            `json:"code"`
+	Message string         `json:"message,omitempty"`
+	Meta    InstanceStatus `json:"meta"`
+}
+
+// LabelRequest attaches the labels to the object, the keys and values are
+// paired by index.
+type LabelRequest struct {
+	Type   string   `json:"type"`
+	ID     ObjectID `json:"id"`
+	Keys   []string `json:"keys"`
+	Values []string `json:"values"`
+}
+
+// PlasmaPayload describes a buffer in the plasma store, which is backed by a
+// blob of vineyard server.
+type PlasmaPayload struct {
+	PlasmaID   PlasmaID `json:"plasma_id"`
+	ID         ObjectID `json:"object_id"`
+	PlasmaSize int      `json:"plasma_size"`
+	StoreFd    int      `json:"store_fd"`
+	DataOffset int      `json:"data_offset"`
+	DataSize   int      `json:"data_size"`
+	MapSize    int      `json:"map_size"`
+	RefCount   int      `json:"ref_cnt"`
+	IsSealed   bool     `json:"is_sealed"`
+}
+
+// Buffer returns the payload of the blob that backs the buffer.
+func (p *PlasmaPayload) Buffer() CreatedBuffer {
+	return CreatedBuffer{
+		DataOffset: p.DataOffset,
+		DataSize:   p.DataSize,
+		MapSize:    p.MapSize,
+		ID:         p.ID,
+		StoreFd:    p.StoreFd,
+	}
+}
+
+type CreateBufferByPlasmaRequest struct {
+	Type       string   `json:"type"`
+	PlasmaID   PlasmaID `json:"plasma_id"`
+	Size       int      `json:"size"`
+	PlasmaSize int      `json:"plasma_size"`
+}
+
+// CreateBufferByPlasmaReply is followed by the store fd if it hasn't been
+// sent to the client.
+type CreateBufferByPlasmaReply struct {
+	Type    string        `json:"type"`
+	Code    int           `json:"code"`
+	Message string        `json:"message,omitempty"`
+	ID      ObjectID      `json:"id"`
+	Created PlasmaPayload `json:"created"`
+	Fd      int           `json:"fd"`
+}
+
+// GetBuffersByPlasmaReply is followed by the store fds that haven't been sent
+// to the client, in the order of payloads.
+type GetBuffersByPlasmaReply struct {
+	Type     string          `json:"type"`
+	Code     int             `json:"code"`
+	Message  string          `json:"message,omitempty"`
+	Payloads []PlasmaPayload `json:"payloads"`
+	Num      int             `json:"num"`
+}
+
+type PlasmaSealRequest struct {
+	Type     string   `json:"type"`
+	PlasmaID PlasmaID `json:"plasma_id"`
+}
+
+type PlasmaReleaseRequest struct {
+	Type     string   `json:"type"`
+	PlasmaID PlasmaID `json:"plasma_id"`
+}
+
+type PlasmaDeleteDataRequest struct {
+	Type     string   `json:"type"`
+	PlasmaID PlasmaID `json:"plasma_id"`
+}
+
+func WriteIfPersistRequest(id ObjectID, msg *string) error {
+	var ifPersistReq IfPersistRequest
+	ifPersistReq.Type = IF_PERSIST_REQUEST
+	ifPersistReq.ID = id
+
+	return encodeMsg(ifPersistReq, msg)
+}
+
+func WriteMigrateObjectRequest(id ObjectID, msg *string) error {
+	var migrateReq MigrateObjectRequest
+	migrateReq.Type = MIGRATE_OBJECT_REQUEST
+	migrateReq.ObjectID = id
+
+	return encodeMsg(migrateReq, msg)
+}
+
+func WriteClusterMetaRequest(msg *string) error {
+	var clusterMetaReq ClusterMetaRequest
+	clusterMetaReq.Type = CLUSTER_META_REQUEST
+
+	return encodeMsg(clusterMetaReq, msg)
+}
+
+func WriteInstanceStatusRequest(msg *string) error {
+	var instanceStatusReq InstanceStatusRequest
+	instanceStatusReq.Type = INSTANCE_STATUS_REQUEST
+
+	return encodeMsg(instanceStatusReq, msg)
+}
+
+func WriteLabelRequest(id ObjectID, labels map[string]string, msg *string) error {
+	var labelReq LabelRequest
+	labelReq.Type = LABEL_REQUEST
+	labelReq.ID = id
+	labelReq.Keys = make([]string, 0, len(labels))
+	for key := range labels {
+		labelReq.Keys = append(labelReq.Keys, key)
+	}
+	sort.Strings(labelReq.Keys)
+	labelReq.Values = make([]string, 0, len(labels))
+	for _, key := range labelReq.Keys {
+		labelReq.Values = append(labelReq.Values, labels[key])
+	}
+
+	return encodeMsg(labelReq, msg)
+}
+
+func WriteCreateBufferByPlasmaRequest(id PlasmaID, size int, msg *string) error {
+	var createBufferByPlasmaReq CreateBufferByPlasmaRequest
+	createBufferByPlasmaReq.Type = CREATE_BUFFER_BY_PLASMA_REQUEST
+	createBufferByPlasmaReq.PlasmaID = id
+	createBufferByPlasmaReq.Size = size
+	createBufferByPlasmaReq.PlasmaSize = size
+
+	return encodeMsg(createBufferByPlasmaReq, msg)
+}
+
+func WriteGetBuffersByPlasmaRequest(ids []PlasmaID, unsafe bool, msg *string) error {
+	// the ids are keyed by their index, the same as get_buffers_request
+	getBuffersByPlasmaReq := make(map[string]interface{})
+	getBuffersByPlasmaReq["type"] = GET_BUFFERS_BY_PLASMA_REQUEST
+	for index, id := range ids {
+		getBuffersByPlasmaReq[strconv.Itoa(index)] = id
+	}
+	getBuffersByPlasmaReq["num"] = len(ids)
+	getBuffersByPlasmaReq["unsafe"] = unsafe
+
+	return encodeMsg(getBuffersByPlasmaReq, msg)
+}
+
+// ReadGetBuffersByPlasmaReply decodes the get_buffers_by_plasma reply, whose
+// payloads might be keyed by their index as well, like ReadGetBuffersReply.
+func ReadGetBuffersByPlasmaReply(msg string, reply *GetBuffersByPlasmaReply) error {
+	if err := DecodeMsg(msg, reply); err != nil {
+		return err
+	}
+	if len(reply.Payloads) != 0 || reply.Num == 0 {
+		return nil
+	}
+	var root map[string]json.RawMessage
+	if err := DecodeMsg(msg, &root); err != nil {
+		return err
+	}
+	reply.Payloads = make([]PlasmaPayload, reply.Num)
+	for index := 0; index < reply.Num; index++ {
+		if err := json.Unmarshal(root[strconv.Itoa(index)], &reply.Payloads[index]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func WritePlasmaSealRequest(id PlasmaID, msg *string) error {
+	var plasmaSealReq PlasmaSealRequest
+	plasmaSealReq.Type = PLASMA_SEAL_REQUEST
+	plasmaSealReq.PlasmaID = id
+
+	return encodeMsg(plasmaSealReq, msg)
+}
+
+func WritePlasmaReleaseRequest(id PlasmaID, msg *string) error {
+	var plasmaReleaseReq PlasmaReleaseRequest
+	plasmaReleaseReq.Type = PLASMA_RELEASE_REQUEST
+	plasmaReleaseReq.PlasmaID = id
+
+	return encodeMsg(plasmaReleaseReq, msg)
+}
+
+func WritePlasmaDeleteDataRequest(id PlasmaID, msg *string) error {
+	var plasmaDeleteDataReq PlasmaDeleteDataRequest
+	plasmaDeleteDataReq.Type = PLASMA_DELETE_DATA_REQUEST
+	plasmaDeleteDataReq.PlasmaID = id
+
+	return encodeMsg(plasmaDeleteDataReq, msg)
 }
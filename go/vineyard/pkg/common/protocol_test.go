@@ -14,3 +14,34 @@ limitations under the License.
 */
 
 package common
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWriteReleaseRequest(t *testing.T) {
+	var msg string
+	assert.NilError(t, WriteReleaseRequest(1234, &msg))
+	assert.Equal(t, msg, `{"type":"release_request","object_id":1234}`)
+}
+
+func TestWritePlasmaSealRequest(t *testing.T) {
+	var id PlasmaID
+	copy(id[:], "abcdefghij0123456789")
+	var msg string
+	assert.NilError(t, WritePlasmaSealRequest(id, &msg))
+	assert.Equal(t, msg, `{"type":"plasma_seal_request","plasma_id":"abcdefghij0123456789"}`)
+
+	var request PlasmaSealRequest
+	assert.NilError(t, DecodeMsg(msg, &request))
+	assert.Equal(t, request.PlasmaID, id)
+}
+
+func TestDelDataWithFeedbacksReply(t *testing.T) {
+	var reply DelDataWithFeedbacksReply
+	msg := `{"type":"del_data_with_feedbacks_reply","deleted_bids":[1,2]}`
+	assert.NilError(t, DecodeMsg(msg, &reply))
+	assert.DeepEqual(t, reply.DeletedIDs, []ObjectID{1, 2})
+}
@@ -59,12 +59,131 @@ const (
 	KUnKnownError = 255
 )
 
-type ReplyError struct {
-	Code int
-	Type string
-	Err  error
+var codeNames = map[int]string{
+	KOK:                       "OK",
+	KInvalid:                  "Invalid",
+	KKeyError:                 "Key error",
+	KTypeError:                "Type error",
+	KIOError:                  "IOError",
+	KEndOfFile:                "End Of File",
+	KNotImplemented:           "Not implemented",
+	KAssertionFailed:          "Assertion failed",
+	KUserInputError:           "User input error",
+	KObjectExists:             "Object exists",
+	KObjectNotExists:          "Object not exists",
+	KObjectSealed:             "Object sealed",
+	KObjectNotSealed:          "Object not sealed",
+	KObjectIsBlob:             "Object not blob",
+	KMetaTreeInvalid:          "Metatree invalid",
+	KMetaTreeTypeInvalid:      "Metatree type invalid",
+	KMetaTreeTypeNotExists:    "Metatree type not exists",
+	KMetaTreeNameInvalid:      "Metatree name invalid",
+	KMetaTreeNameNotExists:    "Metatree name not exists",
+	KMetaTreeLinKInvalid:      "Metatree link invalid",
+	KMetaTreeSubtreeNotExists: "Metatree subtree not exists.",
+	KVineyardServerNotReady:   "Vineyard server not ready",
+	KArrowError:               "Arrow error",
+	KConnectionFailed:         "Connection failed",
+	KConnectionError:          "Connection error",
+	KEtcdError:                "Etcd error",
+	KNotEnoughMemory:          "Not enough memory",
+	KStreamDrained:            "Stream drain",
+	KStreamFailed:             "Stream failed",
+	KInvalidStreamState:       "Invalid stream state",
+	KStreamOpened:             "Stream opened",
+	KGlobalObjectInvalid:      "Global object invalid",
+	KUnKnownError:             "Unknown error",
 }
 
-func (r *ReplyError) Error() string {
-	return "code:" + fmt.Sprintf("%v", r.Code) + " type:" + r.Type + " :" + r.Err.Error()
+// CodeName returns the name of the status code, the same as
+// vineyard::Status::CodeAsString in C++.
+func CodeName(code int) string {
+	if name, ok := codeNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown error(%d)", code)
+}
+
+// Status is the error replied by vineyard server, it matches the sentinel
+// errors of the same code by errors.Is, e.g.,
+//
+//	if errors.Is(err, common.ErrObjectNotExists) { ... }
+type Status struct {
+	Code    int
+	Message string
+	// Request is the type of the request that fails, e.g., "get_data_request"
+	Request string
+}
+
+func (s *Status) Error() string {
+	if s == nil {
+		return CodeName(KOK)
+	}
+	message := CodeName(s.Code)
+	if s.Message != "" {
+		message += ": " + s.Message
+	}
+	if s.Request != "" {
+		message += " (" + s.Request + ")"
+	}
+	return message
+}
+
+func (s *Status) Is(target error) bool {
+	t, ok := target.(*Status)
+	return ok && s != nil && t != nil && s.Code == t.Code
+}
+
+// The sentinel errors of status codes, which are expected to be used with
+// errors.Is rather than compared directly.
+var (
+	ErrInvalid                  = &Status{Code: KInvalid}
+	ErrKeyError                 = &Status{Code: KKeyError}
+	ErrTypeError                = &Status{Code: KTypeError}
+	ErrIOError                  = &Status{Code: KIOError}
+	ErrEndOfFile                = &Status{Code: KEndOfFile}
+	ErrNotImplemented           = &Status{Code: KNotImplemented}
+	ErrAssertionFailed          = &Status{Code: KAssertionFailed}
+	ErrUserInputError           = &Status{Code: KUserInputError}
+	ErrObjectExists             = &Status{Code: KObjectExists}
+	ErrObjectNotExists          = &Status{Code: KObjectNotExists}
+	ErrObjectSealed             = &Status{Code: KObjectSealed}
+	ErrObjectNotSealed          = &Status{Code: KObjectNotSealed}
+	ErrObjectIsBlob             = &Status{Code: KObjectIsBlob}
+	ErrMetaTreeInvalid          = &Status{Code: KMetaTreeInvalid}
+	ErrMetaTreeTypeInvalid      = &Status{Code: KMetaTreeTypeInvalid}
+	ErrMetaTreeTypeNotExists    = &Status{Code: KMetaTreeTypeNotExists}
+	ErrMetaTreeNameInvalid      = &Status{Code: KMetaTreeNameInvalid}
+	ErrMetaTreeNameNotExists    = &Status{Code: KMetaTreeNameNotExists}
+	ErrMetaTreeLinkInvalid      = &Status{Code: KMetaTreeLinKInvalid}
+	ErrMetaTreeSubtreeNotExists = &Status{Code: KMetaTreeSubtreeNotExists}
+	ErrVineyardServerNotReady   = &Status{Code: KVineyardServerNotReady}
+	ErrArrowError               = &Status{Code: KArrowError}
+	ErrConnectionFailed         = &Status{Code: KConnectionFailed}
+	ErrConnectionError          = &Status{Code: KConnectionError}
+	ErrEtcdError                = &Status{Code: KEtcdError}
+	ErrNotEnoughMemory          = &Status{Code: KNotEnoughMemory}
+	ErrStreamDrained            = &Status{Code: KStreamDrained}
+	ErrStreamFailed             = &Status{Code: KStreamFailed}
+	ErrInvalidStreamState       = &Status{Code: KInvalidStreamState}
+	ErrStreamOpened             = &Status{Code: KStreamOpened}
+	ErrGlobalObjectInvalid      = &Status{Code: KGlobalObjectInvalid}
+	ErrUnknownError             = &Status{Code: KUnKnownError}
+)
+
+// CheckReply returns a *Status if the reply carries a non-zero code, or the
+// reply type is not the expected one. The server doesn't fill the type in
+// error replies, thus the type of request is recorded instead.
+func CheckReply(header ReplyHeader, request string, replyType string) error {
+	if header.Code != KOK {
+		return &Status{Code: header.Code, Message: header.Message, Request: request}
+	}
+	if header.Type != replyType {
+		return &Status{
+			Code:    KInvalid,
+			Message: fmt.Sprintf("expect reply type '%s', but got '%s'", replyType, header.Type),
+			Request: request,
+		}
+	}
+	return nil
 }
@@ -0,0 +1,56 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestStatus(t *testing.T) {
+	var nilStatus *Status
+	assert.Equal(t, nilStatus.Error(), "OK")
+	assert.Equal(t, ErrObjectNotExists.Error(), "Object not exists")
+
+	err := fmt.Errorf("get object: %w", &Status{
+		Code:    KObjectNotExists,
+		Message: "o0000000000000001",
+		Request: GET_DATA_REQUEST,
+	})
+	assert.Equal(t, err.Error(), "get object: Object not exists: o0000000000000001 (get_data_request)")
+	assert.Assert(t, errors.Is(err, ErrObjectNotExists))
+	assert.Assert(t, !errors.Is(err, ErrObjectExists))
+
+	var status *Status
+	assert.Assert(t, errors.As(err, &status))
+	assert.Equal(t, status.Code, KObjectNotExists)
+	assert.Equal(t, CodeName(1000), "Unknown error(1000)")
+}
+
+func TestCheckReply(t *testing.T) {
+	assert.NilError(t, CheckReply(ReplyHeader{Type: GET_NAME_REPLY}, GET_NAME_REQUEST, GET_NAME_REPLY))
+
+	err := CheckReply(ReplyHeader{Code: KStreamDrained, Message: "drained"}, PULL_NEXT_STREAM_CHUNK_REQUEST, PULL_NEXT_STREAM_CHUNK_REPLY)
+	assert.Assert(t, errors.Is(err, ErrStreamDrained))
+	assert.Equal(t, err.Error(), "Stream drain: drained (pull_next_stream_chunk_request)")
+
+	err = CheckReply(ReplyHeader{Type: PUT_NAME_REPLY}, GET_NAME_REQUEST, GET_NAME_REPLY)
+	assert.Assert(t, errors.Is(err, ErrInvalid))
+	assert.ErrorContains(t, err, "expect reply type 'get_name_reply', but got 'put_name_reply'")
+}
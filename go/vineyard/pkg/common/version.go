@@ -0,0 +1,216 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is the semantic version of vineyard, e.g., "0.11.4".
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	// PreRelease is the part after "-", e.g., "rc1" of "0.11.0-rc1", which
+	// precedes the release of the same major, minor and patch.
+	PreRelease string
+}
+
+// ParseVersion parses versions like "0.11.4", "v0.11.4" and "0.11.0-rc1",
+// the build metadata after "+" is ignored.
+func ParseVersion(version string) (Version, error) {
+	var v Version
+	s := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if index := strings.IndexByte(s, '+'); index >= 0 {
+		s = s[:index]
+	}
+	if index := strings.IndexByte(s, '-'); index >= 0 {
+		s, v.PreRelease = s[:index], s[index+1:]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version: '%s'", version)
+	}
+	numbers := []*int{&v.Major, &v.Minor, &v.Patch}
+	for index, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return v, fmt.Errorf("invalid version: '%s'", version)
+		}
+		*numbers[index] = number
+	}
+	return v, nil
+}
+
+// MustParseVersion is like ParseVersion but panics if the version is invalid.
+func MustParseVersion(version string) Version {
+	v, err := ParseVersion(version)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Compare returns -1, 0 or 1 if v is less than, equal to, or greater than
+// other.
+func (v Version) Compare(other Version) int {
+	if c := compareInt(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+	switch {
+	case v.PreRelease == other.PreRelease:
+		return 0
+	case v.PreRelease == "":
+		return 1
+	case other.PreRelease == "":
+		return -1
+	}
+	return comparePreRelease(v.PreRelease, other.PreRelease)
+}
+
+func (v Version) Less(other Version) bool {
+	return v.Compare(other) < 0
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		s += "-" + v.PreRelease
+	}
+	return s
+}
+
+// comparePreRelease compares the pre-releases as semantic versioning does,
+// i.e., the identifiers separated by "." are compared in order, numeric
+// identifiers are compared numerically and have lower precedence than
+// alphanumeric ones, and a shorter list of identifiers has lower precedence if
+// the others are equal. Besides, the trailing digits of alphanumeric
+// identifiers are compared numerically, thus "rc2" precedes "rc10".
+func comparePreRelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for index := 0; index < len(as) && index < len(bs); index++ {
+		an, aErr := strconv.ParseUint(as[index], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[index], 10, 64)
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = compareUint(an, bn)
+		case aErr == nil:
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = compareIdentifier(as[index], bs[index])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(as), len(bs))
+}
+
+// compareIdentifier compares alphanumeric identifiers by the part before the
+// trailing digits, then by the trailing digits numerically.
+func compareIdentifier(a, b string) int {
+	aPrefix, aDigits := splitTrailingDigits(a)
+	bPrefix, bDigits := splitTrailingDigits(b)
+	if c := strings.Compare(aPrefix, bPrefix); c != 0 {
+		return c
+	}
+	an, aErr := strconv.ParseUint(aDigits, 10, 64)
+	bn, bErr := strconv.ParseUint(bDigits, 10, 64)
+	if aErr != nil || bErr != nil {
+		return strings.Compare(aDigits, bDigits)
+	}
+	return compareUint(an, bn)
+}
+
+func splitTrailingDigits(s string) (string, string) {
+	index := len(s)
+	for index > 0 && s[index-1] >= '0' && s[index-1] <= '9' {
+		index--
+	}
+	return s[:index], s[index:]
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Feature is a part of the protocol that is only supported by vineyard
+// servers since some version.
+type Feature string
+
+const (
+	// FeatureRemoteBuffers creates and fetches blobs over rpc connections.
+	FeatureRemoteBuffers Feature = "remote_buffers"
+	// FeatureStoreType chooses the normal or plasma store on register.
+	FeatureStoreType Feature = "store_type"
+	// FeatureSessions isolates objects by sessions of vineyard server.
+	FeatureSessions Feature = "sessions"
+	// FeatureRPCCompression compresses the payloads of remote buffers when
+	// the "compress" of requests is set.
+	FeatureRPCCompression Feature = "rpc_compression"
+)
+
+// featureVersions is the first version of vineyard server that supports each
+// feature.
+var featureVersions = map[Feature]Version{
+	FeatureRemoteBuffers: MustParseVersion("0.2.0"),
+	FeatureStoreType:     MustParseVersion("0.2.5"),
+	FeatureSessions:      MustParseVersion("0.3.0"),
+	// the earliest release whose sources the compressed stream is checked
+	// against, i.e., src/server/util/compressor.cc
+	FeatureRPCCompression: MustParseVersion("0.14.6"),
+}
+
+// FeatureVersion returns the first version of vineyard server that supports
+// the feature.
+func FeatureVersion(feature Feature) (Version, bool) {
+	version, ok := featureVersions[feature]
+	return version, ok
+}
+
+// SupportsFeature returns whether vineyard server of the version supports the
+// feature, unknown features are not supported.
+func SupportsFeature(version Version, feature Feature) bool {
+	since, ok := featureVersions[feature]
+	return ok && !version.Less(since)
+}
@@ -0,0 +1,54 @@
+/** Copyright 2020-2023 Alibaba Group Holding Limited.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestVersion(t *testing.T) {
+	v, err := ParseVersion("v0.11.0-rc1+build")
+	assert.NilError(t, err)
+	assert.Equal(t, v, Version{Major: 0, Minor: 11, PreRelease: "rc1"})
+	assert.Equal(t, v.String(), "0.11.0-rc1")
+
+	assert.Assert(t, MustParseVersion("0.2.4").Less(MustParseVersion("0.11.0")))
+	assert.Assert(t, MustParseVersion("0.11.0-rc1").Less(MustParseVersion("0.11.0")))
+	assert.Equal(t, MustParseVersion("1.2").Compare(MustParseVersion("1.2.0")), 0)
+
+	// pre-releases are ordered as semantic versioning does
+	ordered := []string{
+		"1.0.0-1", "1.0.0-2", "1.0.0-10", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc", "1.0.0-rc2", "1.0.0-rc10", "1.0.0",
+	}
+	for index := 1; index < len(ordered); index++ {
+		a, b := MustParseVersion(ordered[index-1]), MustParseVersion(ordered[index])
+		assert.Equal(t, a.Compare(b), -1, "%s < %s", a, b)
+		assert.Equal(t, b.Compare(a), 1, "%s > %s", b, a)
+	}
+	assert.Equal(t, MustParseVersion("1.0.0-rc.1").Compare(MustParseVersion("1.0.0-rc.1")), 0)
+
+	for _, invalid := range []string{"", "1.2.3.4", "1.x", "-1.0"} {
+		_, err := ParseVersion(invalid)
+		assert.ErrorContains(t, err, "invalid version")
+	}
+
+	assert.Assert(t, SupportsFeature(MustParseVersion("0.3.0"), FeatureSessions))
+	assert.Assert(t, !SupportsFeature(MustParseVersion("0.2.4"), FeatureSessions))
+	assert.Assert(t, !SupportsFeature(MustParseVersion("1.0.0"), Feature("unknown")))
+}